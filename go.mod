@@ -17,7 +17,6 @@ require (
 	github.com/joho/godotenv v1.5.1
 	github.com/jwalton/gchalk v1.3.0
 	github.com/lib/pq v1.10.9
-	github.com/lucsky/cuid v1.2.1
 	github.com/mailru/easyjson v0.9.1
 	github.com/riandyrn/otelchi v0.12.3
 	github.com/rung/go-safecast v1.0.1
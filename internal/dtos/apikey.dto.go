@@ -0,0 +1,21 @@
+package dtos
+
+// APIKeyRequest optionally labels a new API key and sets how long it lives.
+type APIKeyRequest struct {
+	Label         string `json:"label"`
+	ExpiresInDays int    `json:"expires_in_days"` // 0 means the default of a year
+}
+
+// APIKey describes a registered API key. The key itself is only returned
+// once, when it is generated or rotated; afterwards only its last characters
+// (Hint) identify it.
+type APIKey struct {
+	ID         string `json:"id"`
+	APIKey     string `json:"api_key,omitempty"`
+	Label      string `json:"label"`
+	Hint       string `json:"hint"`
+	Status     string `json:"status"` // active, expired or revoked
+	CreatedAt  int64  `json:"created_at"`
+	LastUsedAt int64  `json:"last_used_at,omitempty"`
+	ExpiresAt  int64  `json:"expires_at"`
+}
@@ -0,0 +1,35 @@
+package dtos
+
+// AuthTokens is returned by login and token refresh. Token is the short-lived
+// access token for the Authorization header; RefreshToken gets a new pair from
+// /api/auth/refresh.
+type AuthTokens struct {
+	Token            string `json:"token"`
+	Username         string `json:"username"`
+	ExpiresAt        int64  `json:"expires_at"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresAt int64  `json:"refresh_expires_at"`
+	Scope            string `json:"scope,omitempty"` // scopes granted to a third-party app
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// PublicKeySet lists the keys access tokens are signed with, JWKS-style. Keys
+// use the JWK OKP/Ed25519 encoding plus their PASERK form; kid matches the
+// "kid" in a token's footer.
+type PublicKeySet struct {
+	Keys []PublicKey `json:"keys"`
+}
+
+type PublicKey struct {
+	KeyID     string `json:"kid"`
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	PASERK    string `json:"paserk"`
+	Current   bool   `json:"current"` // new tokens are signed with this key
+}
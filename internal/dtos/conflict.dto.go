@@ -0,0 +1,35 @@
+package dtos
+
+type ScheduleConflictSubject struct {
+	ID         string `json:"id"`
+	CourseCode string `json:"course_code"`
+	CourseName string `json:"course_name"`
+	Venue      string `json:"venue"`
+	Start      string `json:"start"`
+	End        string `json:"end"`
+	Section    uint32 `json:"section"`
+}
+
+// ScheduleConflict is a pair of weekly slots that overlap on Day. Start and
+// End ("HHMM") bound the overlap itself.
+type ScheduleConflict struct {
+	Start    string                    `json:"start"`
+	End      string                    `json:"end"`
+	Subjects []ScheduleConflictSubject `json:"subjects"`
+	Day      uint8                     `json:"day"`
+}
+
+// ExamConflict is a pair of final exams that overlap. Start and End (RFC 3339)
+// bound the overlap; they are empty when the clash was detected from identical
+// date and time text that could not be parsed.
+type ExamConflict struct {
+	Start string          `json:"start,omitempty"`
+	End   string          `json:"end,omitempty"`
+	Exams []FinalExamItem `json:"exams"`
+}
+
+type Conflicts struct {
+	SessionName string             `json:"session_name"`
+	Schedule    []ScheduleConflict `json:"schedule"`
+	Exams       []ExamConflict     `json:"exams"`
+}
@@ -1,6 +1,14 @@
 package dtos
 
 type ResponseDTO struct {
-	Data    any    `json:"data"`
-	Message string `json:"message"`
+	Data    any        `json:"data"`
+	Message string     `json:"message"`
+	Stale   *Staleness `json:"stale,omitempty"`
+}
+
+// Staleness marks a response served from the last known-good copy because
+// i-Ma'luum could not be reached.
+type Staleness struct {
+	CachedAt int64 `json:"cached_at"` // Unix time of the scrape being served
+	Age      int64 `json:"age"`       // seconds since CachedAt
 }
@@ -0,0 +1,12 @@
+package dtos
+
+type CalendarFeed struct {
+	ID        string `json:"id"`
+	URL       string `json:"url"`
+	WebcalURL string `json:"webcal_url"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+type RevokeCalendarFeedRequest struct {
+	Token string `json:"token"`
+}
@@ -0,0 +1,34 @@
+package dtos
+
+type FreeSlotsMember struct {
+	Token  string `json:"token"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+// FreeSlotsRequest lists the other members of a group; the caller is always
+// included. From and To are "HHMM" bounds of the search window each day.
+type FreeSlotsRequest struct {
+	From       string            `json:"from,omitempty"`
+	To         string            `json:"to,omitempty"`
+	Members    []FreeSlotsMember `json:"members"`
+	Days       []uint8           `json:"days,omitempty"`
+	MinMinutes int               `json:"min_minutes,omitempty"`
+}
+
+type FreeSlot struct {
+	Start   string `json:"start"`
+	End     string `json:"end"`
+	Minutes int    `json:"minutes"`
+}
+
+type FreeDay struct {
+	Slots []FreeSlot `json:"slots"`
+	Day   uint8      `json:"day"`
+}
+
+type FreeSlots struct {
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Days    []FreeDay `json:"days"`
+	Members int       `json:"members"`
+}
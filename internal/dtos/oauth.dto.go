@@ -0,0 +1,59 @@
+package dtos
+
+// OAuthClientRequest registers a third-party app. Scopes are the most the app
+// may ever request; each student approves them separately.
+type OAuthClientRequest struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+}
+
+// OAuthClient is a registered app. ClientSecret is only returned once, at
+// registration.
+type OAuthClient struct {
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret,omitempty"`
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+	CreatedAt    int64    `json:"created_at"`
+}
+
+// OAuthConsent is what the consent screen shows the student before they
+// approve an app's request.
+type OAuthConsent struct {
+	ClientID    string         `json:"client_id"`
+	ClientName  string         `json:"client_name"`
+	RedirectURI string         `json:"redirect_uri"`
+	Scopes      []ConsentScope `json:"scopes"`
+}
+
+type ConsentScope struct {
+	Scope       string `json:"scope"`
+	Description string `json:"description"`
+}
+
+// AuthorizeRequest is the student's approval of an app's request.
+type AuthorizeRequest struct {
+	ClientID    string `json:"client_id"`
+	RedirectURI string `json:"redirect_uri"`
+	Scope       string `json:"scope"` // space-separated
+	State       string `json:"state"`
+}
+
+// AuthorizeResponse carries the authorization code; send the student's browser
+// to RedirectURI, which already has code and state in its query.
+type AuthorizeResponse struct {
+	Code        string `json:"code"`
+	RedirectURI string `json:"redirect_uri"`
+	ExpiresAt   int64  `json:"expires_at"`
+}
+
+// OAuthTokenRequest exchanges an authorization code for scoped tokens.
+type OAuthTokenRequest struct {
+	GrantType    string `json:"grant_type"` // must be "authorization_code"
+	Code         string `json:"code"`
+	RedirectURI  string `json:"redirect_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
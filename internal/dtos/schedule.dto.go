@@ -25,3 +25,59 @@ type ScheduleResponse struct {
 	SessionQuery string            `json:"session_query"`
 	Schedule     []ScheduleSubject `json:"schedule"`
 }
+
+// ScheduleOccurrence is a single dated meeting of a class. Start and End are
+// RFC 3339 in Asia/Kuala_Lumpur time; Date is its calendar date (YYYY-MM-DD).
+type ScheduleOccurrence struct {
+	ID         string `json:"id"`
+	CourseCode string `json:"course_code"`
+	CourseName string `json:"course_name"`
+	Venue      string `json:"venue"`
+	Lecturer   string `json:"lecturer"`
+	Date       string `json:"date"`
+	Start      string `json:"start"`
+	End        string `json:"end"`
+	StartUnix  int64  `json:"start_unix"`
+	EndUnix    int64  `json:"end_unix"`
+	Section    uint32 `json:"section"`
+	Day        uint8  `json:"day"`
+}
+
+type ScheduleOccurrences struct {
+	SessionName  string               `json:"session_name"`
+	SessionQuery string               `json:"session_query"`
+	From         string               `json:"from"`
+	To           string               `json:"to"`
+	Occurrences  []ScheduleOccurrence `json:"occurrences"`
+}
+
+// ScheduleNowClass is an occurrence with countdowns relative to the request
+// time, in seconds. StartsIn is negative once the class has started.
+type ScheduleNowClass struct {
+	ScheduleOccurrence
+	StartsIn int64 `json:"starts_in"`
+	EndsIn   int64 `json:"ends_in"`
+}
+
+type ScheduleNow struct {
+	SessionName string            `json:"session_name"`
+	Timestamp   int64             `json:"timestamp"`
+	Current     *ScheduleNowClass `json:"current"`
+	NextToday   *ScheduleNowClass `json:"next_today"`
+	Next        *ScheduleNowClass `json:"next"`
+}
+
+// ScheduleChange is one difference between a previously cached schedule and a
+// fresh scrape. Field is "added", "dropped", "section", "venue", "lecturer" or
+// "time"; Before and After are empty for additions and drops respectively.
+type ScheduleChange struct {
+	ID           string `json:"id"`
+	SessionName  string `json:"session_name"`
+	SessionQuery string `json:"session_query"`
+	CourseCode   string `json:"course_code"`
+	CourseName   string `json:"course_name"`
+	Field        string `json:"field"`
+	Before       string `json:"before,omitempty"`
+	After        string `json:"after,omitempty"`
+	DetectedAt   int64  `json:"detected_at"`
+}
@@ -26,3 +26,25 @@ var (
 		StatusCode: 500,
 	}
 )
+
+var (
+	ErrAPIKeyRevoked = &CustomError{
+		Message:    "API key has been revoked",
+		StatusCode: 401,
+	}
+
+	ErrAPIKeyExpired = &CustomError{
+		Message:    "API key has expired",
+		StatusCode: 401,
+	}
+
+	ErrAPIKeyNotFound = &CustomError{
+		Message:    "API key not found",
+		StatusCode: 404,
+	}
+
+	ErrFailedToUpdateAPIKey = &CustomError{
+		Message:    "Failed to update API key",
+		StatusCode: 500,
+	}
+)
@@ -6,6 +6,16 @@ var (
 		StatusCode: 401,
 	}
 
+	ErrTooManyLoginAttempts = &CustomError{
+		Message:    "Too many failed login attempts, please try again later",
+		StatusCode: 429,
+	}
+
+	ErrAuthServiceUnavailable = &CustomError{
+		Message:    "Authentication service is unavailable, please try again later",
+		StatusCode: 503,
+	}
+
 	ErrURLParseFailed = &CustomError{
 		Message:    "Failed to parse URL",
 		StatusCode: 500,
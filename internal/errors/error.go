@@ -1,6 +1,7 @@
 package errors
 
 import (
+	stderrors "errors"
 	"fmt"
 	"net/http"
 
@@ -11,6 +12,11 @@ type CustomError struct {
 	OriginalErr error  `json:"-"`
 	Message     string `json:"message,omitempty"`
 	StatusCode  int    `json:"status,omitempty"`
+
+	// base is the predefined error this one was wrapped from, if any. It is
+	// typed error, not *CustomError, so render doesn't try to render it as a
+	// field.
+	base error
 }
 
 // Error returns the error message
@@ -26,6 +32,17 @@ func (e *CustomError) GetStatusCode() int {
 	return e.StatusCode
 }
 
+// Unwrap returns the original error
+func (e *CustomError) Unwrap() error {
+	return e.OriginalErr
+}
+
+// Is reports whether e was wrapped from target, so Is(Wrap(ErrX, err), ErrX)
+// holds like err == ErrX does for the unwrapped error
+func (e *CustomError) Is(target error) bool {
+	return e.base != nil && e.base == target
+}
+
 func (e *CustomError) Render(w http.ResponseWriter, r *http.Request) error {
 	render.Status(r, e.GetStatusCode())
 	return nil
@@ -33,13 +50,24 @@ func (e *CustomError) Render(w http.ResponseWriter, r *http.Request) error {
 
 // WrapError wraps an original error with a predefined CustomError
 func Wrap(predefError *CustomError, originalErr error) *CustomError {
+	base := error(predefError)
+	if predefError.base != nil {
+		base = predefError.base
+	}
 	return &CustomError{
 		OriginalErr: originalErr,
 		Message:     predefError.Message,
 		StatusCode:  predefError.StatusCode,
+		base:        base,
 	}
 }
 
+// Is reports whether err is target, or wraps it, like the standard library's
+// errors.Is
+func Is(err, target error) bool {
+	return stderrors.Is(err, target)
+}
+
 func Render(w http.ResponseWriter, r *http.Request, err error) {
 	re, ok := err.(*CustomError)
 	if !ok {
@@ -0,0 +1,18 @@
+package errors
+
+var (
+	ErrInvalidFeedToken = &CustomError{
+		Message:    "Calendar feed token is invalid, expired or revoked",
+		StatusCode: 401,
+	}
+
+	ErrFailedToGenerateFeedToken = &CustomError{
+		Message:    "Failed to generate calendar feed token",
+		StatusCode: 500,
+	}
+
+	ErrFailedToRevokeFeedToken = &CustomError{
+		Message:    "Failed to revoke calendar feed token",
+		StatusCode: 500,
+	}
+)
@@ -0,0 +1,13 @@
+package errors
+
+var (
+	ErrInvalidFreeSlotsRequest = &CustomError{
+		Message:    "Invalid free slots request: from and to must be HHMM with from before to, days must be 0-6, and at most 10 members are allowed",
+		StatusCode: 400,
+	}
+
+	ErrInvalidGroupMemberToken = &CustomError{
+		Message:    "One of the group member tokens is invalid",
+		StatusCode: 401,
+	}
+)
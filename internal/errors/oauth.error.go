@@ -0,0 +1,38 @@
+package errors
+
+var (
+	ErrInsufficientScope = &CustomError{
+		Message:    "Token was not granted the scope this route requires",
+		StatusCode: 403,
+	}
+
+	ErrInvalidScope = &CustomError{
+		Message:    "Invalid or unknown scope",
+		StatusCode: 400,
+	}
+
+	ErrInvalidClient = &CustomError{
+		Message:    "Unknown app or wrong client secret",
+		StatusCode: 401,
+	}
+
+	ErrInvalidRedirectURI = &CustomError{
+		Message:    "Redirect URI is not registered for this app",
+		StatusCode: 400,
+	}
+
+	ErrInvalidAuthorizationCode = &CustomError{
+		Message:    "Invalid, expired or used authorization code",
+		StatusCode: 400,
+	}
+
+	ErrUnsupportedGrantType = &CustomError{
+		Message:    "Unsupported grant type",
+		StatusCode: 400,
+	}
+
+	ErrFailedToRegisterClient = &CustomError{
+		Message:    "Failed to register app",
+		StatusCode: 500,
+	}
+)
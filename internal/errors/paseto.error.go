@@ -25,4 +25,34 @@ var (
 		Message:    "Failed to create PASETO private key",
 		StatusCode: 500,
 	}
+
+	ErrAccessTokenExpired = &CustomError{
+		Message:    "Access token expired, use your refresh token to get a new one",
+		StatusCode: 401,
+	}
+
+	ErrInvalidRefreshToken = &CustomError{
+		Message:    "Invalid, expired or revoked refresh token",
+		StatusCode: 401,
+	}
+
+	ErrUnknownPASETOKeyID = &CustomError{
+		Message:    "Token is signed with an unknown key",
+		StatusCode: 401,
+	}
+
+	ErrTokenRevoked = &CustomError{
+		Message:    "Token has been revoked, please log in again",
+		StatusCode: 401,
+	}
+
+	ErrLegacyTokenRetired = &CustomError{
+		Message:    "This token format is no longer accepted, please log in again",
+		StatusCode: 401,
+	}
+
+	ErrFailedToRevokeToken = &CustomError{
+		Message:    "Failed to revoke token",
+		StatusCode: 500,
+	}
 )
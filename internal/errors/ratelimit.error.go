@@ -0,0 +1,13 @@
+package errors
+
+var (
+	ErrRateLimited = &CustomError{
+		Message:    "Too many requests, please slow down",
+		StatusCode: 429,
+	}
+
+	ErrQuotaExceeded = &CustomError{
+		Message:    "Daily request quota exceeded",
+		StatusCode: 429,
+	}
+)
@@ -1,6 +1,18 @@
 package errors
 
-var ErrScheduleIsEmpty = &CustomError{
-	Message:    "Schedule is empty",
-	StatusCode: 404,
-}
+var (
+	ErrScheduleIsEmpty = &CustomError{
+		Message:    "Schedule is empty",
+		StatusCode: 404,
+	}
+
+	ErrNoUpcomingSemester = &CustomError{
+		Message:    "No current or upcoming semester found in the academic calendar",
+		StatusCode: 404,
+	}
+
+	ErrInvalidDateRange = &CustomError{
+		Message:    "Invalid date range: from and to must be YYYY-MM-DD, from must not be after to, and the range must not exceed 186 days",
+		StatusCode: 400,
+	}
+)
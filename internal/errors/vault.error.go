@@ -0,0 +1,18 @@
+package errors
+
+var (
+	ErrInvalidVaultMasterKey = &CustomError{
+		Message:    "Invalid credential vault master key",
+		StatusCode: 500,
+	}
+
+	ErrFailedToSealCredential = &CustomError{
+		Message:    "Failed to store credentials",
+		StatusCode: 500,
+	}
+
+	ErrFailedToOpenCredential = &CustomError{
+		Message:    "Failed to read stored credentials",
+		StatusCode: 500,
+	}
+)
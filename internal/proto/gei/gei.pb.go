@@ -233,6 +233,230 @@ func (x *GetScheduleResponse) GetMessage() string {
 	return ""
 }
 
+type StoreResourceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Resource      string                 `protobuf:"bytes,2,opt,name=resource,proto3" json:"resource,omitempty"`                          // Resource kind, e.g. "result"
+	PayloadJson   string                 `protobuf:"bytes,3,opt,name=payload_json,json=payloadJson,proto3" json:"payload_json,omitempty"` // The resource data as JSON string
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StoreResourceRequest) Reset() {
+	*x = StoreResourceRequest{}
+	mi := &file_internal_proto_gei_gei_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StoreResourceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StoreResourceRequest) ProtoMessage() {}
+
+func (x *StoreResourceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gei_gei_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StoreResourceRequest.ProtoReflect.Descriptor instead.
+func (*StoreResourceRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gei_gei_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *StoreResourceRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *StoreResourceRequest) GetResource() string {
+	if x != nil {
+		return x.Resource
+	}
+	return ""
+}
+
+func (x *StoreResourceRequest) GetPayloadJson() string {
+	if x != nil {
+		return x.PayloadJson
+	}
+	return ""
+}
+
+type StoreResourceResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StoreResourceResponse) Reset() {
+	*x = StoreResourceResponse{}
+	mi := &file_internal_proto_gei_gei_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StoreResourceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StoreResourceResponse) ProtoMessage() {}
+
+func (x *StoreResourceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gei_gei_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StoreResourceResponse.ProtoReflect.Descriptor instead.
+func (*StoreResourceResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gei_gei_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *StoreResourceResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *StoreResourceResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type GetResourceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Resource      string                 `protobuf:"bytes,2,opt,name=resource,proto3" json:"resource,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetResourceRequest) Reset() {
+	*x = GetResourceRequest{}
+	mi := &file_internal_proto_gei_gei_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetResourceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetResourceRequest) ProtoMessage() {}
+
+func (x *GetResourceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gei_gei_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetResourceRequest.ProtoReflect.Descriptor instead.
+func (*GetResourceRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gei_gei_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetResourceRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *GetResourceRequest) GetResource() string {
+	if x != nil {
+		return x.Resource
+	}
+	return ""
+}
+
+type GetResourceResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	PayloadJson   string                 `protobuf:"bytes,2,opt,name=payload_json,json=payloadJson,proto3" json:"payload_json,omitempty"` // The decrypted resource data
+	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetResourceResponse) Reset() {
+	*x = GetResourceResponse{}
+	mi := &file_internal_proto_gei_gei_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetResourceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetResourceResponse) ProtoMessage() {}
+
+func (x *GetResourceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gei_gei_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetResourceResponse.ProtoReflect.Descriptor instead.
+func (*GetResourceResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gei_gei_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetResourceResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *GetResourceResponse) GetPayloadJson() string {
+	if x != nil {
+		return x.PayloadJson
+	}
+	return ""
+}
+
+func (x *GetResourceResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
 var File_internal_proto_gei_gei_proto protoreflect.FileDescriptor
 
 const file_internal_proto_gei_gei_proto_rawDesc = "" +
@@ -249,10 +473,26 @@ const file_internal_proto_gei_gei_proto_rawDesc = "" +
 	"\x13GetScheduleResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12#\n" +
 	"\rschedule_json\x18\x02 \x01(\tR\fscheduleJson\x12\x18\n" +
-	"\amessage\x18\x03 \x01(\tR\amessage2\xaf\x01\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\"q\n" +
+	"\x14StoreResourceRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x1a\n" +
+	"\bresource\x18\x02 \x01(\tR\bresource\x12!\n" +
+	"\fpayload_json\x18\x03 \x01(\tR\vpayloadJson\"K\n" +
+	"\x15StoreResourceResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"L\n" +
+	"\x12GetResourceRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x1a\n" +
+	"\bresource\x18\x02 \x01(\tR\bresource\"l\n" +
+	"\x13GetResourceResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12!\n" +
+	"\fpayload_json\x18\x02 \x01(\tR\vpayloadJson\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage2\xcd\x02\n" +
 	"\x0fScheduleIndexer\x12P\n" +
 	"\rStoreSchedule\x12\x1e.schedule.StoreScheduleRequest\x1a\x1f.schedule.StoreScheduleResponse\x12J\n" +
-	"\vGetSchedule\x12\x1c.schedule.GetScheduleRequest\x1a\x1d.schedule.GetScheduleResponseB1Z/github.com/nrmnqdds/gomaluum/internal/proto/geib\x06proto3"
+	"\vGetSchedule\x12\x1c.schedule.GetScheduleRequest\x1a\x1d.schedule.GetScheduleResponse\x12P\n" +
+	"\rStoreResource\x12\x1e.schedule.StoreResourceRequest\x1a\x1f.schedule.StoreResourceResponse\x12J\n" +
+	"\vGetResource\x12\x1c.schedule.GetResourceRequest\x1a\x1d.schedule.GetResourceResponseB1Z/github.com/nrmnqdds/gomaluum/internal/proto/geib\x06proto3"
 
 var (
 	file_internal_proto_gei_gei_proto_rawDescOnce sync.Once
@@ -266,20 +506,28 @@ func file_internal_proto_gei_gei_proto_rawDescGZIP() []byte {
 	return file_internal_proto_gei_gei_proto_rawDescData
 }
 
-var file_internal_proto_gei_gei_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_internal_proto_gei_gei_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
 var file_internal_proto_gei_gei_proto_goTypes = []any{
 	(*StoreScheduleRequest)(nil),  // 0: schedule.StoreScheduleRequest
 	(*StoreScheduleResponse)(nil), // 1: schedule.StoreScheduleResponse
 	(*GetScheduleRequest)(nil),    // 2: schedule.GetScheduleRequest
 	(*GetScheduleResponse)(nil),   // 3: schedule.GetScheduleResponse
+	(*StoreResourceRequest)(nil),  // 4: schedule.StoreResourceRequest
+	(*StoreResourceResponse)(nil), // 5: schedule.StoreResourceResponse
+	(*GetResourceRequest)(nil),    // 6: schedule.GetResourceRequest
+	(*GetResourceResponse)(nil),   // 7: schedule.GetResourceResponse
 }
 var file_internal_proto_gei_gei_proto_depIdxs = []int32{
 	0, // 0: schedule.ScheduleIndexer.StoreSchedule:input_type -> schedule.StoreScheduleRequest
 	2, // 1: schedule.ScheduleIndexer.GetSchedule:input_type -> schedule.GetScheduleRequest
-	1, // 2: schedule.ScheduleIndexer.StoreSchedule:output_type -> schedule.StoreScheduleResponse
-	3, // 3: schedule.ScheduleIndexer.GetSchedule:output_type -> schedule.GetScheduleResponse
-	2, // [2:4] is the sub-list for method output_type
-	0, // [0:2] is the sub-list for method input_type
+	4, // 2: schedule.ScheduleIndexer.StoreResource:input_type -> schedule.StoreResourceRequest
+	6, // 3: schedule.ScheduleIndexer.GetResource:input_type -> schedule.GetResourceRequest
+	1, // 4: schedule.ScheduleIndexer.StoreSchedule:output_type -> schedule.StoreScheduleResponse
+	3, // 5: schedule.ScheduleIndexer.GetSchedule:output_type -> schedule.GetScheduleResponse
+	5, // 6: schedule.ScheduleIndexer.StoreResource:output_type -> schedule.StoreResourceResponse
+	7, // 7: schedule.ScheduleIndexer.GetResource:output_type -> schedule.GetResourceResponse
+	4, // [4:8] is the sub-list for method output_type
+	0, // [0:4] is the sub-list for method input_type
 	0, // [0:0] is the sub-list for extension type_name
 	0, // [0:0] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
@@ -296,7 +544,7 @@ func file_internal_proto_gei_gei_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_proto_gei_gei_proto_rawDesc), len(file_internal_proto_gei_gei_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   4,
+			NumMessages:   8,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -25,20 +25,28 @@ const _ = grpc.SupportPackageIsVersion9
 const (
 	ScheduleIndexer_StoreSchedule_FullMethodName = "/schedule.ScheduleIndexer/StoreSchedule"
 	ScheduleIndexer_GetSchedule_FullMethodName   = "/schedule.ScheduleIndexer/GetSchedule"
+	ScheduleIndexer_StoreResource_FullMethodName = "/schedule.ScheduleIndexer/StoreResource"
+	ScheduleIndexer_GetResource_FullMethodName   = "/schedule.ScheduleIndexer/GetResource"
 )
 
 // ScheduleIndexerClient is the client API for ScheduleIndexer service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 //
-// ScheduleIndexer stores and retrieves a user's schedule (encrypted at rest by
-// GEI). Used as gomaluum's shared cache so scraped schedules survive across the
-// stateless serverless instances.
+// ScheduleIndexer stores and retrieves a user's scraped data (encrypted at rest
+// by GEI). Used as gomaluum's shared cache so scraped schedules and other
+// resources survive across the stateless serverless instances.
 type ScheduleIndexerClient interface {
 	// Store a user's schedule (will be encrypted). Guarded by an admin-key header.
 	StoreSchedule(ctx context.Context, in *StoreScheduleRequest, opts ...grpc.CallOption) (*StoreScheduleResponse, error)
 	// Retrieve a user's schedule (will be decrypted).
 	GetSchedule(ctx context.Context, in *GetScheduleRequest, opts ...grpc.CallOption) (*GetScheduleResponse, error)
+	// Store a user's resource of the given kind (will be encrypted). Guarded by an
+	// admin-key header. Same contract as StoreSchedule, keyed by (username,
+	// resource).
+	StoreResource(ctx context.Context, in *StoreResourceRequest, opts ...grpc.CallOption) (*StoreResourceResponse, error)
+	// Retrieve a user's resource of the given kind (will be decrypted).
+	GetResource(ctx context.Context, in *GetResourceRequest, opts ...grpc.CallOption) (*GetResourceResponse, error)
 }
 
 type scheduleIndexerClient struct {
@@ -69,18 +77,44 @@ func (c *scheduleIndexerClient) GetSchedule(ctx context.Context, in *GetSchedule
 	return out, nil
 }
 
+func (c *scheduleIndexerClient) StoreResource(ctx context.Context, in *StoreResourceRequest, opts ...grpc.CallOption) (*StoreResourceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StoreResourceResponse)
+	err := c.cc.Invoke(ctx, ScheduleIndexer_StoreResource_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *scheduleIndexerClient) GetResource(ctx context.Context, in *GetResourceRequest, opts ...grpc.CallOption) (*GetResourceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetResourceResponse)
+	err := c.cc.Invoke(ctx, ScheduleIndexer_GetResource_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ScheduleIndexerServer is the server API for ScheduleIndexer service.
 // All implementations must embed UnimplementedScheduleIndexerServer
 // for forward compatibility.
 //
-// ScheduleIndexer stores and retrieves a user's schedule (encrypted at rest by
-// GEI). Used as gomaluum's shared cache so scraped schedules survive across the
-// stateless serverless instances.
+// ScheduleIndexer stores and retrieves a user's scraped data (encrypted at rest
+// by GEI). Used as gomaluum's shared cache so scraped schedules and other
+// resources survive across the stateless serverless instances.
 type ScheduleIndexerServer interface {
 	// Store a user's schedule (will be encrypted). Guarded by an admin-key header.
 	StoreSchedule(context.Context, *StoreScheduleRequest) (*StoreScheduleResponse, error)
 	// Retrieve a user's schedule (will be decrypted).
 	GetSchedule(context.Context, *GetScheduleRequest) (*GetScheduleResponse, error)
+	// Store a user's resource of the given kind (will be encrypted). Guarded by an
+	// admin-key header. Same contract as StoreSchedule, keyed by (username,
+	// resource).
+	StoreResource(context.Context, *StoreResourceRequest) (*StoreResourceResponse, error)
+	// Retrieve a user's resource of the given kind (will be decrypted).
+	GetResource(context.Context, *GetResourceRequest) (*GetResourceResponse, error)
 	mustEmbedUnimplementedScheduleIndexerServer()
 }
 
@@ -97,6 +131,12 @@ func (UnimplementedScheduleIndexerServer) StoreSchedule(context.Context, *StoreS
 func (UnimplementedScheduleIndexerServer) GetSchedule(context.Context, *GetScheduleRequest) (*GetScheduleResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetSchedule not implemented")
 }
+func (UnimplementedScheduleIndexerServer) StoreResource(context.Context, *StoreResourceRequest) (*StoreResourceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StoreResource not implemented")
+}
+func (UnimplementedScheduleIndexerServer) GetResource(context.Context, *GetResourceRequest) (*GetResourceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetResource not implemented")
+}
 func (UnimplementedScheduleIndexerServer) mustEmbedUnimplementedScheduleIndexerServer() {}
 func (UnimplementedScheduleIndexerServer) testEmbeddedByValue()                         {}
 
@@ -154,6 +194,42 @@ func _ScheduleIndexer_GetSchedule_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ScheduleIndexer_StoreResource_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StoreResourceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ScheduleIndexerServer).StoreResource(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ScheduleIndexer_StoreResource_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ScheduleIndexerServer).StoreResource(ctx, req.(*StoreResourceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ScheduleIndexer_GetResource_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetResourceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ScheduleIndexerServer).GetResource(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ScheduleIndexer_GetResource_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ScheduleIndexerServer).GetResource(ctx, req.(*GetResourceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ScheduleIndexer_ServiceDesc is the grpc.ServiceDesc for ScheduleIndexer service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -169,6 +245,14 @@ var ScheduleIndexer_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetSchedule",
 			Handler:    _ScheduleIndexer_GetSchedule_Handler,
 		},
+		{
+			MethodName: "StoreResource",
+			Handler:    _ScheduleIndexer_StoreResource_Handler,
+		},
+		{
+			MethodName: "GetResource",
+			Handler:    _ScheduleIndexer_GetResource_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "internal/proto/gei/gei.proto",
@@ -4,6 +4,7 @@ import (
 	_ "embed"
 	"encoding/json"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/bytedance/sonic"
@@ -14,6 +15,13 @@ import (
 //go:embed academic_calendar_data.json
 var academicCalendarData []byte
 
+// publicHolidaysData lists the public holidays observed at the Gombak campus
+// (federal plus Selangor). Islamic holidays follow the gazetted dates and the
+// file must be extended each year alongside the academic calendar.
+//
+//go:embed public_holidays_data.json
+var publicHolidaysData []byte
+
 type academicCalendarItem struct {
 	Title         string `json:"title"`
 	StartDate     string `json:"start_date"`
@@ -22,12 +30,196 @@ type academicCalendarItem struct {
 	EndDateUnix   int64  `json:"end_date_unix"`
 }
 
+type publicHolidayRaw struct {
+	Name string `json:"name"`
+	Date string `json:"date"`
+}
+
 type academicCalendarRaw struct {
 	Title     string `json:"title"`
 	StartDate string `json:"start_date"`
 	EndDate   string `json:"end_date"`
 }
 
+// Calendar entry titles that shape a semester. Every other entry (mid-semester
+// break, revision, examination) falls outside the teaching weeks.
+const (
+	calendarLectures      = "LECTURES"
+	calendarInterSemester = "INTER SEMESTER VACATION"
+)
+
+// klTimezone is i-Ma'luum's timezone. Class times and calendar dates are
+// local to it.
+var klTimezone = func() *time.Location {
+	loc, err := time.LoadLocation("Asia/Kuala_Lumpur")
+	if err != nil {
+		return time.FixedZone("MYT", 8*60*60)
+	}
+	return loc
+}()
+
+// calendarPeriod is a parsed academic calendar entry. Start and End are
+// midnight Kuala Lumpur time on the first and last day; End is inclusive.
+type calendarPeriod struct {
+	Title string
+	Start time.Time
+	End   time.Time
+}
+
+// semester is the run of lecture periods that sits between two inter-semester
+// vacations. Gaps between its lecture periods are breaks. Holidays maps the
+// public holidays, keyed by time.DateOnly date.
+type semester struct {
+	Lectures []calendarPeriod
+	Holidays map[string]string
+}
+
+// Start is the first day of lectures.
+func (s semester) Start() time.Time {
+	return s.Lectures[0].Start
+}
+
+// End is the last day of lectures (inclusive, at midnight).
+func (s semester) End() time.Time {
+	return s.Lectures[len(s.Lectures)-1].End
+}
+
+// HasLectures reports whether day (any time on it) falls inside one of the
+// semester's lecture periods.
+func (s semester) HasLectures(day time.Time) bool {
+	d := calendarDate(day.In(klTimezone))
+	for _, p := range s.Lectures {
+		if !d.Before(p.Start) && !d.After(p.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasClasses reports whether classes run on day: it is a lecture day and not a
+// public holiday.
+func (s semester) HasClasses(day time.Time) bool {
+	if !s.HasLectures(day) {
+		return false
+	}
+	_, holiday := s.Holidays[day.In(klTimezone).Format(time.DateOnly)]
+	return !holiday
+}
+
+// academicCalendar parses the embedded calendar once. The stored timestamps
+// are calendar dates, so only their year/month/day are kept and re-anchored
+// to Kuala Lumpur midnight.
+var academicCalendar = sync.OnceValues(func() ([]calendarPeriod, error) {
+	var raw []academicCalendarRaw
+	if err := json.Unmarshal(academicCalendarData, &raw); err != nil {
+		return nil, err
+	}
+
+	periods := make([]calendarPeriod, 0, len(raw))
+	for _, entry := range raw {
+		start, err := time.Parse(time.RFC3339, entry.StartDate)
+		if err != nil {
+			return nil, err
+		}
+		end, err := time.Parse(time.RFC3339, entry.EndDate)
+		if err != nil {
+			return nil, err
+		}
+		periods = append(periods, calendarPeriod{
+			Title: entry.Title,
+			Start: calendarDate(start),
+			End:   calendarDate(end),
+		})
+	}
+	return periods, nil
+})
+
+// publicHolidays parses the embedded holiday list once, keyed by
+// time.DateOnly date.
+var publicHolidays = sync.OnceValues(func() (map[string]string, error) {
+	var raw []publicHolidayRaw
+	if err := json.Unmarshal(publicHolidaysData, &raw); err != nil {
+		return nil, err
+	}
+
+	holidays := make(map[string]string, len(raw))
+	for _, entry := range raw {
+		if _, err := time.Parse(time.DateOnly, entry.Date); err != nil {
+			return nil, err
+		}
+		holidays[entry.Date] = entry.Name
+	}
+	return holidays, nil
+})
+
+// calendarDate returns midnight Kuala Lumpur time on t's calendar date.
+func calendarDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, klTimezone)
+}
+
+// groupSemesters splits calendar periods into semesters, closing one at every
+// inter-semester vacation. Semesters without lecture weeks are dropped.
+func groupSemesters(periods []calendarPeriod) []semester {
+	var (
+		semesters []semester
+		current   semester
+	)
+	flush := func() {
+		if len(current.Lectures) > 0 {
+			semesters = append(semesters, current)
+		}
+		current = semester{}
+	}
+
+	for _, p := range periods {
+		switch p.Title {
+		case calendarLectures:
+			current.Lectures = append(current.Lectures, p)
+		case calendarInterSemester:
+			flush()
+		}
+	}
+	flush()
+
+	return semesters
+}
+
+// semesters returns every semester in the embedded academic calendar, with
+// the embedded public holidays attached.
+func semesters() ([]semester, error) {
+	periods, err := academicCalendar()
+	if err != nil {
+		return nil, err
+	}
+	holidays, err := publicHolidays()
+	if err != nil {
+		return nil, err
+	}
+
+	sems := groupSemesters(periods)
+	for i := range sems {
+		sems[i].Holidays = holidays
+	}
+	return sems, nil
+}
+
+// semesterFor returns the semester in progress at now, or the next one to
+// start. ok is false when the embedded calendar has nothing current or ahead.
+func semesterFor(now time.Time) (sem semester, ok bool, err error) {
+	sems, err := semesters()
+	if err != nil {
+		return semester{}, false, err
+	}
+
+	today := calendarDate(now.In(klTimezone))
+	for _, s := range sems {
+		if !s.End().Before(today) {
+			return s, true, nil
+		}
+	}
+	return semester{}, false, nil
+}
+
 // @Title AcademicCalendarHandler
 // @Description Get IIUM academic calendar
 // @Tags academic
@@ -6,10 +6,10 @@ import (
 
 	"github.com/bytedance/sonic"
 	"github.com/gocolly/colly/v2"
-	"github.com/lucsky/cuid"
 	"github.com/nrmnqdds/gomaluum/internal/constants"
 	"github.com/nrmnqdds/gomaluum/internal/dtos"
 	"github.com/nrmnqdds/gomaluum/internal/errors"
+	"github.com/nrmnqdds/gomaluum/pkg/utils"
 )
 
 // @Title AdsHandler
@@ -38,7 +38,7 @@ func (s *Server) AdsHandler(w http.ResponseWriter, r *http.Request) {
 			Title:    strings.TrimSpace(e.ChildText("a")),
 			ImageURL: strings.TrimSpace(e.ChildAttr("img", "src")),
 			Link:     strings.TrimSpace(e.ChildAttr("a", "href")),
-			ID:       cuid.New(),
+			ID:       utils.StableID("ad", strings.TrimSpace(e.ChildAttr("a", "href")), strings.TrimSpace(e.ChildText("a"))),
 		})
 	})
 
@@ -1,54 +1,638 @@
 package server
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
 	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/bytedance/sonic"
+	"github.com/go-chi/chi/v5"
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
 	"github.com/nrmnqdds/gomaluum/internal/errors"
 	"github.com/nrmnqdds/gomaluum/pkg/apikey"
 )
 
+const (
+	// apiKeyDefaultTTL and apiKeyMaxTTL bound how long a key lives.
+	apiKeyDefaultTTL = 365 * 24 * time.Hour
+	apiKeyMaxTTL     = 2 * apiKeyDefaultTTL
+
+	// apiKeyRotationGrace is how long a rotated key keeps working, so
+	// sessions opened with it can move to the new key.
+	apiKeyRotationGrace = 24 * time.Hour
+
+	// apiKeyTouchInterval limits last-used writes to one per key per
+	// interval.
+	apiKeyTouchInterval = 5 * time.Minute
+)
+
 type APIKeyResponse struct {
+	ID        string `json:"id"`
 	APIKey    string `json:"api_key"`
+	Label     string `json:"label,omitempty"`
 	Message   string `json:"message"`
 	CreatedAt string `json:"created_at"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// apiKeyRecord is a registered API key. Only the key's hash is stored.
+type apiKeyRecord struct {
+	id         string
+	hash       string // hex SHA-256 of the key
+	owner      string // username of the student who generated it; "" if anonymous
+	label      string
+	tier       string // rate limit tier, see rateLimitTiers
+	hint       string // last characters of the key, to tell keys apart
+	createdAt  time.Time
+	lastUsedAt time.Time // zero until first used
+	expiresAt  time.Time
+	revoked    bool
+}
+
+// status is how the key's owner sees it.
+func (k *apiKeyRecord) status(now time.Time) string {
+	switch {
+	case k.revoked:
+		return "revoked"
+	case !now.Before(k.expiresAt):
+		return "expired"
+	}
+	return "active"
+}
+
+func (k *apiKeyRecord) dto(now time.Time) dtos.APIKey {
+	key := dtos.APIKey{
+		ID:        k.id,
+		Label:     k.label,
+		Hint:      k.hint,
+		Status:    k.status(now),
+		CreatedAt: k.createdAt.Unix(),
+		ExpiresAt: k.expiresAt.Unix(),
+	}
+	if !k.lastUsedAt.IsZero() {
+		key.LastUsedAt = k.lastUsedAt.Unix()
+	}
+	return key
+}
+
+type apiKeyStore interface {
+	Create(ctx context.Context, key *apiKeyRecord) error
+	// Lookup finds a key by its hash.
+	Lookup(ctx context.Context, hash string) (key *apiKeyRecord, found bool, err error)
+	Get(ctx context.Context, id string) (key *apiKeyRecord, found bool, err error)
+	List(ctx context.Context, owner string) ([]*apiKeyRecord, error)
+	Revoke(ctx context.Context, id string) error
+	// Expire brings a key's expiry forward to at, if at is sooner.
+	Expire(ctx context.Context, id string, at time.Time) error
+	Touch(ctx context.Context, id string, at time.Time) error
+}
+
+// apiKeyRegistry is the set of API keys the server accepts. Besides the
+// default key, a key must have been generated here, and must be neither
+// revoked nor expired.
+type apiKeyRegistry struct {
+	store apiKeyStore
+
+	// allowUnregistered accepts well-formed keys missing from the registry,
+	// such as keys generated before it existed. It is a migration aid.
+	allowUnregistered bool
+}
+
+// newAPIKeyRegistry returns a Postgres-backed registry when a database is
+// configured, otherwise an in-memory one that does not survive a restart.
+func newAPIKeyRegistry(db *sql.DB, allowUnregistered bool) *apiKeyRegistry {
+	var store apiKeyStore = &memoryAPIKeys{keys: make(map[string]*apiKeyRecord)}
+	if db != nil {
+		store = &postgresAPIKeys{db: db}
+	}
+	return &apiKeyRegistry{store: store, allowUnregistered: allowUnregistered}
+}
+
+// allowUnregisteredAPIKeys reads API_KEY_ALLOW_UNREGISTERED.
+func allowUnregisteredAPIKeys() bool {
+	return os.Getenv("API_KEY_ALLOW_UNREGISTERED") == "true"
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// register generates and stores a new key for owner.
+func (a *apiKeyRegistry) register(ctx context.Context, owner, label, tier string, ttl time.Duration) (string, *apiKeyRecord, error) {
+	key, err := apikey.GenerateTimestampedAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := newTokenID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	record := &apiKeyRecord{
+		id:        "key_" + id[:16],
+		hash:      hashAPIKey(key),
+		owner:     owner,
+		label:     label,
+		tier:      tier,
+		hint:      key[len(key)-4:],
+		createdAt: now,
+		expiresAt: now.Add(ttl),
+	}
+	if err := a.store.Create(ctx, record); err != nil {
+		return "", nil, err
+	}
+	return key, record, nil
+}
+
+// apiKeyUse is the API key a request is made with.
+type apiKeyUse struct {
+	key  string
+	id   string // registry ID; "" for the default key and unregistered keys
+	tier string
+}
+
+// defaultAPIKeyUse is a request made without a key of its own.
+var defaultAPIKeyUse = apiKeyUse{key: apikey.DefaultAPIKey, tier: rateLimitDefaultTier}
+
+// isDefault reports whether the request was made without a key of its own.
+func (u apiKeyUse) isDefault() bool {
+	return u.key == apikey.DefaultAPIKey
+}
+
+// lookupAPIKey checks the key a request sent in its x-gomaluum-key header:
+// the default key when none was sent, otherwise key itself once the registry
+// accepts it.
+func (s *Server) lookupAPIKey(ctx context.Context, key string) (apiKeyUse, error) {
+	if key == "" || key == apikey.DefaultAPIKey {
+		return defaultAPIKeyUse, nil
+	}
+	if !apikey.ValidateAPIKey(key) {
+		return apiKeyUse{}, errors.ErrInvalidAPIKey
+	}
+
+	record, found, err := s.apiKeys.store.Lookup(ctx, hashAPIKey(key))
+	if err != nil {
+		return apiKeyUse{}, err
+	}
+	if !found {
+		if s.apiKeys.allowUnregistered {
+			s.log.WarnContext(ctx, "Accepting unregistered API key", "key_prefix", key[:10]+"...")
+			return apiKeyUse{key: key, tier: rateLimitStandardTier}, nil
+		}
+		return apiKeyUse{}, errors.ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	switch record.status(now) {
+	case "revoked":
+		return apiKeyUse{}, errors.ErrAPIKeyRevoked
+	case "expired":
+		return apiKeyUse{}, errors.ErrAPIKeyExpired
+	}
+
+	if now.Sub(record.lastUsedAt) >= apiKeyTouchInterval {
+		if err := s.apiKeys.store.Touch(ctx, record.id, now); err != nil {
+			s.log.WarnContext(ctx, "Failed to record API key use", "key_id", record.id, "error", err)
+		}
+	}
+	return apiKeyUse{key: key, id: record.id, tier: record.tier}, nil
+}
+
+// resolveAPIKey returns the API key to use for key, see lookupAPIKey.
+func (s *Server) resolveAPIKey(ctx context.Context, key string) (string, error) {
+	use, err := s.lookupAPIKey(ctx, key)
+	return use.key, err
+}
+
+// requestAPIKeyUse checks the x-gomaluum-key header of r, unless a middleware
+// already did.
+func (s *Server) requestAPIKeyUse(r *http.Request) (apiKeyUse, error) {
+	if use, ok := r.Context().Value(ctxAPIKey).(apiKeyUse); ok {
+		return use, nil
+	}
+	return s.lookupAPIKey(r.Context(), r.Header.Get("x-gomaluum-key"))
+}
+
+// requestAPIKey returns the API key to use for r.
+func (s *Server) requestAPIKey(r *http.Request) (string, error) {
+	use, err := s.requestAPIKeyUse(r)
+	return use.key, err
+}
+
+// apiKeyOwner returns the student generating a key: the bearer of the
+// request's first-party token, or "" when the request carries no token.
+func (s *Server) apiKeyOwner(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", nil
+	}
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return "", errors.ErrInvalidToken
+	}
+
+	userAPIKey, err := s.requestAPIKey(r)
+	if err != nil {
+		return "", err
+	}
+	sess, err := s.DecodePasetoToken(r.Context(), token, userAPIKey)
+	if err == errors.ErrAccessTokenExpired || err == errors.ErrTokenRevoked || err == errors.ErrLegacyTokenRetired {
+		return "", err
+	}
+	if err != nil || sess == nil {
+		return "", errors.ErrInvalidToken
+	}
+	if !sess.hasScopes(scopeAccount) {
+		return "", errors.ErrInsufficientScope
+	}
+	return sess.username, nil
+}
+
+// ownedAPIKey returns the key {id} of the authenticated student.
+func (s *Server) ownedAPIKey(r *http.Request) (*apiKeyRecord, error) {
+	ctx := r.Context()
+	record, found, err := s.apiKeys.store.Get(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		return nil, err
+	}
+	if !found || record.owner != requestUsername(ctx) {
+		return nil, errors.ErrAPIKeyNotFound
+	}
+	return record, nil
 }
 
 // GenerateAPIKeyHandler generates a new API key for authentication
 // @Summary Generate API Key
-// @Description Generate a new API key for additional authentication layer
+// @Description Generate a new API key for additional authentication layer. Keys expire after expires_in_days (default 365, at most 730). Send an access token to own the key, so it can later be listed, rotated and revoked under /api/key; anonymous keys can't be managed.
 // @Tags key
 // @Accept json
 // @Produce json
 // @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string false "Access token of the key's owner" default(Bearer <Add access token here>)
+// @Param request body dtos.APIKeyRequest false "Label and lifetime of the key"
 // @Success 200 {object} APIKeyResponse
+// @Failure 400 {object} errors.CustomError "Invalid label or lifetime"
 // @Failure 500 {object} errors.CustomError
 // @Router /api/key/generate [post]
 func (s *Server) GenerateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx := r.Context()
 	logger := s.log
 
-	// Generate a new API key
-	newAPIKey, err := apikey.GenerateTimestampedAPIKey()
+	var req dtos.APIKeyRequest
+	if err := sonic.ConfigFastest.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		logger.ErrorContext(ctx, "Failed to decode request body", "error", err)
+		errors.Render(w, r, errors.ErrInvalidRequest)
+		return
+	}
+	ttl := apiKeyDefaultTTL
+	if req.ExpiresInDays != 0 {
+		ttl = time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	}
+	req.Label = strings.TrimSpace(req.Label)
+	if ttl <= 0 || ttl > apiKeyMaxTTL || len(req.Label) > 64 {
+		errors.Render(w, r, errors.ErrInvalidRequest)
+		return
+	}
+
+	owner, err := s.apiKeyOwner(r)
+	if err != nil {
+		logger.WarnContext(ctx, "Failed to authenticate key owner", "error", err)
+		errors.Render(w, r, err)
+		return
+	}
+
+	newAPIKey, record, err := s.apiKeys.register(ctx, owner, req.Label, rateLimitStandardTier, ttl)
 	if err != nil {
-		logger.ErrorContext(r.Context(), "Failed to generate API key", "error", err)
+		logger.ErrorContext(ctx, "Failed to generate API key", "error", err)
 		errors.Render(w, r, errors.ErrFailedToGenerateAPIKey)
 		return
 	}
 
 	response := APIKeyResponse{
+		ID:        record.id,
 		APIKey:    newAPIKey,
-		Message:   "API key generated successfully. Include this key in the 'x-gomaluum-key' header for enhanced security.",
-		CreatedAt: "now", // You might want to use actual timestamp
+		Label:     record.label,
+		Message:   "API key generated successfully. Include this key in the 'x-gomaluum-key' header for enhanced security. It will not be shown again.",
+		CreatedAt: record.createdAt.UTC().Format(time.RFC3339),
+		ExpiresAt: record.expiresAt.UTC().Format(time.RFC3339),
+	}
+
+	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
+		logger.ErrorContext(ctx, "Failed to encode response", "error", err)
+		errors.Render(w, r, errors.ErrFailedToGenerateAPIKey)
+		return
+	}
+
+	logger.InfoContext(ctx, "Generated new API key", "key_id", record.id, "owner", owner)
+}
+
+// @Title ListAPIKeysHandler
+// @Description List the API keys generated with your access token, including revoked and expired ones. Keys themselves are never shown again; tell them apart by label and hint.
+// @Tags key
+// @Produce json
+// @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Success 200 {object} dtos.ResponseDTO{data=[]dtos.APIKey}
+// @Router /api/key [get]
+func (s *Server) ListAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx := r.Context()
+	logger := s.log
+
+	records, err := s.apiKeys.store.List(ctx, requestUsername(ctx))
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to list API keys", "error", err)
+		errors.Render(w, r, errors.ErrFailedToQueryDB)
+		return
+	}
+
+	now := time.Now()
+	keys := make([]dtos.APIKey, 0, len(records))
+	for _, record := range records {
+		keys = append(keys, record.dto(now))
+	}
+
+	response := &dtos.ResponseDTO{
+		Message: "Successfully fetched API keys",
+		Data:    keys,
 	}
 
+	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
+		logger.ErrorContext(ctx, "Failed to encode response", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+	}
+}
+
+// @Title RotateAPIKeyHandler
+// @Description Replace an API key with a new one with the same label and lifetime. The old key keeps working for 24 hours so sessions opened with it can log in again with the new key.
+// @Tags key
+// @Produce json
+// @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Param id path string true "API key ID"
+// @Success 200 {object} dtos.ResponseDTO{data=dtos.APIKey}
+// @Failure 404 {object} errors.CustomError "No such key"
+// @Router /api/key/{id}/rotate [post]
+func (s *Server) RotateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
 
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		logger.ErrorContext(r.Context(), "Failed to encode response", "error", err)
+	ctx := r.Context()
+	logger := s.log
+
+	old, err := s.ownedAPIKey(r)
+	if err != nil {
+		errors.Render(w, r, err)
+		return
+	}
+	now := time.Now()
+	if old.status(now) != "active" {
+		errors.Render(w, r, errors.ErrAPIKeyNotFound)
+		return
+	}
+
+	newAPIKey, record, err := s.apiKeys.register(ctx, old.owner, old.label, old.tier, old.expiresAt.Sub(old.createdAt))
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to generate API key", "error", err)
 		errors.Render(w, r, errors.ErrFailedToGenerateAPIKey)
 		return
 	}
+	if err := s.apiKeys.store.Expire(ctx, old.id, now.Add(apiKeyRotationGrace)); err != nil {
+		logger.ErrorContext(ctx, "Failed to expire rotated API key", "key_id", old.id, "error", err)
+		errors.Render(w, r, errors.ErrFailedToUpdateAPIKey)
+		return
+	}
+
+	key := record.dto(now)
+	key.APIKey = newAPIKey
+	response := &dtos.ResponseDTO{
+		Message: "Successfully rotated API key. Save the new key: it will not be shown again.",
+		Data:    key,
+	}
+
+	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
+		logger.ErrorContext(ctx, "Failed to encode response", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+		return
+	}
+
+	logger.InfoContext(ctx, "Rotated API key", "old_key_id", old.id, "key_id", record.id)
+}
+
+// @Title RevokeAPIKeyHandler
+// @Description Revoke an API key immediately. Requests sent with it, including with tokens issued under it, are refused from then on.
+// @Tags key
+// @Produce json
+// @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Param id path string true "API key ID"
+// @Success 200 {object} dtos.ResponseDTO{data=dtos.APIKey}
+// @Failure 404 {object} errors.CustomError "No such key"
+// @Router /api/key/{id}/revoke [post]
+func (s *Server) RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx := r.Context()
+	logger := s.log
+
+	record, err := s.ownedAPIKey(r)
+	if err != nil {
+		errors.Render(w, r, err)
+		return
+	}
+	if err := s.apiKeys.store.Revoke(ctx, record.id); err != nil {
+		logger.ErrorContext(ctx, "Failed to revoke API key", "key_id", record.id, "error", err)
+		errors.Render(w, r, errors.ErrFailedToUpdateAPIKey)
+		return
+	}
+	record.revoked = true
+
+	response := &dtos.ResponseDTO{
+		Message: "Successfully revoked API key",
+		Data:    record.dto(time.Now()),
+	}
+
+	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
+		logger.ErrorContext(ctx, "Failed to encode response", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+		return
+	}
+
+	logger.InfoContext(ctx, "Revoked API key", "key_id", record.id)
+}
+
+type memoryAPIKeys struct {
+	mu   sync.RWMutex
+	keys map[string]*apiKeyRecord // by ID
+}
+
+func (m *memoryAPIKeys) Create(_ context.Context, key *apiKeyRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *key
+	m.keys[key.id] = &stored
+	return nil
+}
+
+func (m *memoryAPIKeys) Lookup(_ context.Context, hash string) (*apiKeyRecord, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, key := range m.keys {
+		if key.hash == hash {
+			found := *key
+			return &found, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func (m *memoryAPIKeys) Get(_ context.Context, id string) (*apiKeyRecord, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.keys[id]
+	if !ok {
+		return nil, false, nil
+	}
+	found := *key
+	return &found, true, nil
+}
+
+func (m *memoryAPIKeys) List(_ context.Context, owner string) ([]*apiKeyRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var keys []*apiKeyRecord
+	for _, key := range m.keys {
+		if key.owner == owner {
+			found := *key
+			keys = append(keys, &found)
+		}
+	}
+	slices.SortFunc(keys, func(a, b *apiKeyRecord) int { return b.createdAt.Compare(a.createdAt) })
+	return keys, nil
+}
+
+func (m *memoryAPIKeys) Revoke(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if key, ok := m.keys[id]; ok {
+		key.revoked = true
+	}
+	return nil
+}
+
+func (m *memoryAPIKeys) Expire(_ context.Context, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if key, ok := m.keys[id]; ok && at.Before(key.expiresAt) {
+		key.expiresAt = at
+	}
+	return nil
+}
+
+func (m *memoryAPIKeys) Touch(_ context.Context, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if key, ok := m.keys[id]; ok {
+		key.lastUsedAt = at
+	}
+	return nil
+}
+
+type postgresAPIKeys struct {
+	db *sql.DB
+}
+
+const apiKeyColumns = `id, key_hash, owner, label, tier, hint, created_at, last_used_at, expires_at, revoked`
+
+func scanAPIKey(row interface{ Scan(...any) error }) (*apiKeyRecord, error) {
+	var key apiKeyRecord
+	var lastUsedAt sql.NullTime
+	if err := row.Scan(&key.id, &key.hash, &key.owner, &key.label, &key.tier, &key.hint, &key.createdAt, &lastUsedAt, &key.expiresAt, &key.revoked); err != nil {
+		return nil, err
+	}
+	key.lastUsedAt = lastUsedAt.Time
+	return &key, nil
+}
+
+func (p *postgresAPIKeys) Create(ctx context.Context, key *apiKeyRecord) error {
+	_, err := p.db.ExecContext(ctx, `
+			INSERT INTO api_keys (id, key_hash, owner, label, tier, hint, created_at, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, key.id, key.hash, key.owner, key.label, key.tier, key.hint, key.createdAt, key.expiresAt)
+	return err
+}
+
+func (p *postgresAPIKeys) lookup(ctx context.Context, column, value string) (*apiKeyRecord, bool, error) {
+	key, err := scanAPIKey(p.db.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE `+column+` = $1`, value))
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return key, true, nil
+}
+
+func (p *postgresAPIKeys) Lookup(ctx context.Context, hash string) (*apiKeyRecord, bool, error) {
+	return p.lookup(ctx, "key_hash", hash)
+}
+
+func (p *postgresAPIKeys) Get(ctx context.Context, id string) (*apiKeyRecord, bool, error) {
+	return p.lookup(ctx, "id", id)
+}
+
+func (p *postgresAPIKeys) List(ctx context.Context, owner string) ([]*apiKeyRecord, error) {
+	rows, err := p.db.QueryContext(ctx, `
+			SELECT `+apiKeyColumns+` FROM api_keys
+			WHERE owner = $1
+			ORDER BY created_at DESC
+		`, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*apiKeyRecord
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (p *postgresAPIKeys) Revoke(ctx context.Context, id string) error {
+	_, err := p.db.ExecContext(ctx, `UPDATE api_keys SET revoked = TRUE WHERE id = $1`, id)
+	return err
+}
+
+func (p *postgresAPIKeys) Expire(ctx context.Context, id string, at time.Time) error {
+	_, err := p.db.ExecContext(ctx, `UPDATE api_keys SET expires_at = LEAST(expires_at, $2) WHERE id = $1`, id, at)
+	return err
+}
 
-	logger.InfoContext(r.Context(), "Generated new API key", "key_prefix", newAPIKey[:10]+"...") // Log only first 10 chars for security
+func (p *postgresAPIKeys) Touch(ctx context.Context, id string, at time.Time) error {
+	_, err := p.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, at)
+	return err
 }
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/go-chi/chi/v5"
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+	"github.com/nrmnqdds/gomaluum/pkg/apikey"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyRegistry(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+
+	tokens, err := s.issueTokens(ctx, TokenPayload{username: "2110000", password: "p@ss", imaluumCookie: "cookie", apiKey: apikey.DefaultAPIKey})
+	require.NoError(t, err)
+
+	generate := func(body, token string) (int, APIKeyResponse) {
+		r := httptest.NewRequest(http.MethodPost, "/api/key/generate", strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		s.GenerateAPIKeyHandler(w, r)
+		var resp APIKeyResponse
+		if w.Code == http.StatusOK {
+			require.NoError(t, sonic.ConfigFastest.Unmarshal(w.Body.Bytes(), &resp))
+		}
+		return w.Code, resp
+	}
+
+	code, anonymous := generate("", "")
+	require.Equal(t, http.StatusOK, code, "keys can still be generated without logging in")
+	code, owned := generate(`{"label":"ProReg","expires_in_days":30}`, tokens.Token)
+	require.Equal(t, http.StatusOK, code)
+	code, _ = generate(`{"expires_in_days":1000}`, tokens.Token)
+	require.Equal(t, http.StatusBadRequest, code)
+
+	for _, key := range []string{"", apikey.DefaultAPIKey, anonymous.APIKey, owned.APIKey} {
+		_, err := s.resolveAPIKey(ctx, key)
+		require.NoError(t, err)
+	}
+	_, err = s.resolveAPIKey(ctx, "gml_1700000000_forged")
+	require.Equal(t, errors.ErrInvalidAPIKey, err, "well-formed keys must still be registered")
+	s.apiKeys.allowUnregistered = true
+	_, err = s.resolveAPIKey(ctx, "gml_1700000000_forged")
+	require.NoError(t, err)
+	s.apiKeys.allowUnregistered = false
+
+	sess, err := s.DecodePasetoToken(ctx, tokens.Token, apikey.DefaultAPIKey)
+	require.NoError(t, err)
+	manage := func(handler http.HandlerFunc, method, target, id string, data any) int {
+		r := httptest.NewRequest(method, target, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		r = r.WithContext(context.WithValue(context.WithValue(r.Context(), chi.RouteCtxKey, rctx), ctxSession, sess))
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code == http.StatusOK && data != nil {
+			require.NoError(t, sonic.ConfigFastest.Unmarshal(w.Body.Bytes(), &dtos.ResponseDTO{Data: data}))
+		}
+		return w.Code
+	}
+
+	var keys []dtos.APIKey
+	require.Equal(t, http.StatusOK, manage(s.ListAPIKeysHandler, http.MethodGet, "/api/key", "", &keys))
+	require.Len(t, keys, 1, "anonymous keys belong to nobody")
+	require.Equal(t, owned.ID, keys[0].ID)
+	require.Equal(t, "ProReg", keys[0].Label)
+	require.Equal(t, owned.APIKey[len(owned.APIKey)-4:], keys[0].Hint)
+	require.NotZero(t, keys[0].LastUsedAt)
+	require.Empty(t, keys[0].APIKey)
+
+	require.Equal(t, http.StatusNotFound, manage(s.RevokeAPIKeyHandler, http.MethodPost, "/api/key/x/revoke", anonymous.ID, nil),
+		"students can only manage their own keys")
+
+	var rotated dtos.APIKey
+	require.Equal(t, http.StatusOK, manage(s.RotateAPIKeyHandler, http.MethodPost, "/api/key/x/rotate", owned.ID, &rotated))
+	require.NotEqual(t, owned.APIKey, rotated.APIKey)
+	require.Equal(t, "ProReg", rotated.Label)
+	require.Equal(t, 30*24*time.Hour, time.Unix(rotated.ExpiresAt, 0).Sub(time.Unix(rotated.CreatedAt, 0)))
+	_, err = s.resolveAPIKey(ctx, owned.APIKey)
+	require.NoError(t, err, "rotated keys keep working for a grace period")
+	old, _, err := s.apiKeys.store.Get(ctx, owned.ID)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(apiKeyRotationGrace), old.expiresAt, time.Minute)
+
+	require.Equal(t, http.StatusOK, manage(s.RevokeAPIKeyHandler, http.MethodPost, "/api/key/x/revoke", rotated.ID, nil))
+	_, err = s.resolveAPIKey(ctx, rotated.APIKey)
+	require.Equal(t, errors.ErrAPIKeyRevoked, err)
+
+	require.NoError(t, s.apiKeys.store.Expire(ctx, owned.ID, time.Now()))
+	_, err = s.resolveAPIKey(ctx, owned.APIKey)
+	require.Equal(t, errors.ErrAPIKeyExpired, err)
+}
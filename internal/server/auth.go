@@ -1,9 +1,11 @@
 package server
 
 import (
+	"context"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"time"
 
 	"github.com/bytedance/sonic"
 	"github.com/mailru/easyjson"
@@ -11,18 +13,18 @@ import (
 	"github.com/nrmnqdds/gomaluum/internal/dtos"
 	"github.com/nrmnqdds/gomaluum/internal/errors"
 	pb "github.com/nrmnqdds/gomaluum/internal/proto"
-	"github.com/nrmnqdds/gomaluum/pkg/apikey"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 // @Title LoginHandler
-// @Description Logs in the user. Save the token and use it in the Authorization header for future requests.
+// @Description Logs in the user. Use the token in the Authorization header for future requests; it expires after 15 minutes. Save the refresh token and exchange it at /api/auth/refresh for a new pair.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param x-gomaluum-key header string false "API key for additional security layer"
 // @Param body body pb.LoginRequest true "Login properties"
-// @Success 200 {object} dtos.ResponseDTO
+// @Success 200 {object} dtos.ResponseDTO{data=dtos.AuthTokens}
+// @Failure 429 {object} errors.CustomError "Too many failed attempts for this username or IP; see Retry-After"
 // @Router /api/auth/login [post]
 func (s *Server) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -40,9 +42,28 @@ func (s *Server) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Check the API key against the registry before anything reaches GAS:
+	// the tokens couldn't be issued with a rejected key anyway.
+	userAPIKey, err := s.requestAPIKey(r)
+	if err != nil {
+		logger.WarnContext(ctx, "API key rejected in login", "error", err)
+		errors.Render(w, r, err)
+		return
+	}
+
+	// Refuse attempts while the username or IP is backing off, before they
+	// reach GAS. Allowed attempts are reserved until they resolve.
+	ip := s.clientIP(r)
+	subjects := userLoginSubjects(user.Username, ip)
+	if wait := s.loginThrottle.reserve(subjects); wait > 0 {
+		logger.WarnContext(ctx, "Login throttled", "username", user.Username, "ip", ip, "retry_after", wait)
+		w.Header().Set("Retry-After", seconds(wait))
+		errors.Render(w, r, errors.ErrTooManyLoginAttempts)
+		return
+	}
+
 	// Intercept fake user for local debugging
 	var resp *pb.LoginResponse
-	var err error
 
 	if user.Username == constants.DebugUsername && user.Password == constants.DebugPassword {
 		logger.InfoContext(ctx, "Using fake user for debugging")
@@ -53,26 +74,22 @@ func (s *Server) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	} else {
 		// Call the Login method from the external GRPC service
-		resp, err = s.grpc.client.Login(ctx, user)
+		resp, err = s.auth.Login(ctx, user)
 		if err != nil {
 			logger.ErrorContext(ctx, "Login failed", "error", err)
-			errors.Render(w, r, errors.ErrLoginFailed)
-			return
-		}
-	}
-
-	// Get API key from header
-	userAPIKey := r.Header.Get("x-gomaluum-key")
-	if userAPIKey == "" {
-		userAPIKey = apikey.DefaultAPIKey
-	} else {
-		// Validate the provided API key format
-		if !apikey.ValidateAPIKey(userAPIKey) {
-			logger.WarnContext(ctx, "Invalid API key format provided in login")
-			errors.Render(w, r, errors.ErrInvalidAPIKey)
+			if isUpstreamFailure(err) {
+				s.loginThrottle.release(subjects)
+			} else {
+				s.recordLoginFailure(ctx, subjects, user.Username, ip)
+			}
+			s.renderLoginError(w, r, err)
 			return
 		}
 	}
+	s.loginThrottle.succeed(subjects)
+	// Sessions with the password that just worked must not inherit stale
+	// stored passwords' failures.
+	s.loginThrottle.forget(storedLoginSubjects(user.Username))
 
 	payload := TokenPayload{
 		username:      resp.Username,
@@ -81,22 +98,17 @@ func (s *Server) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		apiKey:        userAPIKey,
 	}
 
-	// Generate a new PASETO token
-	newCookie, _, err := s.GeneratePasetoToken(payload)
+	// Generate a new access token and refresh token
+	tokens, err := s.issueTokens(r.Context(), payload)
 	if err != nil {
 		logger.ErrorContext(ctx, "Failed to generate PASETO token", "error", err)
-		errors.Render(w, r, errors.ErrFailedToDecodePASETO)
+		errors.Render(w, r, errors.ErrFailedToGeneratePASETO)
 		return
 	}
 
-	result := &pb.LoginResponse{
-		Token:    newCookie,
-		Username: resp.Username,
-	}
-
 	response := &dtos.ResponseDTO{
-		Message: "Login successful! Please use the token in the Authorization header for future requests.",
-		Data:    result,
+		Message: "Login successful! Please use the token in the Authorization header for future requests, and the refresh token to renew it at /api/auth/refresh.",
+		Data:    tokens,
 	}
 
 	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
@@ -106,7 +118,7 @@ func (s *Server) LoginHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Title LogoutHandler
-// @Description Logs out the user. Clears the token from IIUM's CAS. PASETO token is still valid.
+// @Description Logs out the user. Revokes the access token and the refresh token issued with it, and clears the session from IIUM's CAS. Other sessions stay logged in; see /api/auth/logout-all.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -119,6 +131,16 @@ func (s *Server) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 
 	logger := s.log
 
+	sess := r.Context().Value(ctxSession).(*TokenPayload)
+
+	// Revoke first: the tokens must die even if CAS is unreachable.
+	if err := s.revokeSession(r.Context(), sess); err != nil {
+		logger.ErrorContext(r.Context(), "Failed to revoke token", "error", err)
+		errors.Render(w, r, errors.ErrFailedToRevokeToken)
+		return
+	}
+	s.tokenManager.Invalidate(sess.username)
+
 	jar, _ := cookiejar.New(nil)
 
 	cookie := r.Context().Value(ctxToken).(string)
@@ -169,6 +191,80 @@ func (s *Server) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// revokeSession revokes the access token in sess and its refresh token, and
+// drops its vaulted credentials. Legacy tokens have no ID and can only be
+// revoked with revokeAllSessions.
+func (s *Server) revokeSession(ctx context.Context, sess *TokenPayload) error {
+	if sess.tokenID != "" {
+		if err := s.revocations.Revoke(ctx, sess.tokenID, sess.username, sess.expiresAt); err != nil {
+			return err
+		}
+	}
+	if sess.refreshID != "" {
+		// The refresh token's own expiry is not in the access token; it is at
+		// most refreshTokenTTL after the pair was issued.
+		if err := s.revocations.Revoke(ctx, sess.refreshID, sess.username, sess.issuedAt.Add(refreshTokenTTL)); err != nil {
+			return err
+		}
+	}
+	if sess.credential != "" {
+		if err := s.credentials.Forget(ctx, sess.credential); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeAllSessions logs username out everywhere: every access, refresh and
+// feed token issued until now is revoked, the cached i-Ma'luum session and the
+// vaulted credentials are dropped, and the user's cached data is purged.
+// Purge failures are logged only, since the tokens are already dead.
+func (s *Server) revokeAllSessions(ctx context.Context, username string) error {
+	if err := s.revocations.RevokeAll(ctx, username, time.Now()); err != nil {
+		return err
+	}
+	s.tokenManager.Invalidate(username)
+	if err := s.credentials.ForgetUser(ctx, username); err != nil {
+		s.log.WarnContext(ctx, "Failed to forget credentials", "username", username, "error", err)
+	}
+	if err := s.purgeUserData(ctx, username); err != nil {
+		s.log.WarnContext(ctx, "Failed to purge cached data", "username", username, "error", err)
+	}
+	return nil
+}
+
+// @Title LogoutAllHandler
+// @Description Logs the user out everywhere. Revokes every access, refresh and calendar feed token issued to the user so far, and purges their cached data. Log in again to get a new token.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Success 200 {object} dtos.ResponseDTO
+// @Router /api/auth/logout-all [post]
+func (s *Server) LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	logger := s.log
+	sess := r.Context().Value(ctxSession).(*TokenPayload)
+
+	if err := s.revokeAllSessions(r.Context(), sess.username); err != nil {
+		logger.ErrorContext(r.Context(), "Failed to revoke all sessions", "error", err)
+		errors.Render(w, r, errors.ErrFailedToRevokeToken)
+		return
+	}
+
+	response := &dtos.ResponseDTO{
+		Message: "Logged out of all sessions",
+		Data:    nil,
+	}
+
+	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
+		logger.ErrorContext(r.Context(), "Failed to encode response", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+	}
+}
+
 // Function to set headers for a request.
 func setHeaders(req *http.Request) {
 	req.Header.Set("Connection", "Keep-Alive")
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+	pb "github.com/nrmnqdds/gomaluum/internal/proto"
+	"github.com/nrmnqdds/gomaluum/pkg/breaker"
+)
+
+// Logins go through a circuit breaker with bounded retries, so a slow or down
+// auth service fails fast with ErrAuthServiceUnavailable instead of holding
+// every request for a full deadline.
+const (
+	authAttempts       = 3
+	authAttemptTimeout = 5 * time.Second
+	// authBudget bounds a login with all its retries, well inside the HTTP
+	// server's WriteTimeout so the handler still gets to answer.
+	authBudget = 12 * time.Second
+	// authBackoff caps the wait before the first retry; it doubles for each
+	// retry after that, and the actual wait is a random fraction of the cap.
+	authBackoff = 250 * time.Millisecond
+
+	authBreakerThreshold = 5 // consecutive failed attempts that open the circuit
+	authBreakerCooldown  = 30 * time.Second
+)
+
+// guardedAuthenticator retries and circuit-breaks another Authenticator. Only
+// upstream failures (see isUpstreamFailure) are retried or count against the
+// service; a wrong password means it is up.
+type guardedAuthenticator struct {
+	Authenticator
+
+	breaker        *breaker.Breaker
+	attempts       int
+	attemptTimeout time.Duration
+	budget         time.Duration
+	backoff        time.Duration
+}
+
+func newGuardedAuthenticator(next Authenticator) *guardedAuthenticator {
+	return &guardedAuthenticator{
+		Authenticator:  next,
+		breaker:        breaker.New(authBreakerThreshold, authBreakerCooldown),
+		attempts:       authAttempts,
+		attemptTimeout: authAttemptTimeout,
+		budget:         authBudget,
+		backoff:        authBackoff,
+	}
+}
+
+func (g *guardedAuthenticator) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	budget, cancel := context.WithTimeout(ctx, g.budget)
+	defer cancel()
+
+	var err error
+	for attempt := range g.attempts {
+		if attempt > 0 {
+			// Full jitter keeps instances from retrying in lockstep.
+			select {
+			case <-budget.Done():
+				return nil, err
+			case <-time.After(rand.N(g.backoff << (attempt - 1))):
+			}
+		}
+		if !g.breaker.Allow() {
+			return nil, errors.ErrAuthServiceUnavailable
+		}
+
+		var resp *pb.LoginResponse
+		resp, err = g.login(budget, req)
+		switch {
+		case err == nil || !isUpstreamFailure(err):
+			g.breaker.Success()
+			return resp, err
+		case ctx.Err() != nil:
+			// The caller gave up, which says nothing about the service. Running
+			// out of budget does, and ends the loop at the next backoff.
+			return nil, err
+		}
+		g.breaker.Failure()
+	}
+	return nil, err
+}
+
+// login makes one attempt, bounded by its own deadline.
+func (g *guardedAuthenticator) login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, g.attemptTimeout)
+	defer cancel()
+	return g.Authenticator.Login(ctx, req)
+}
+
+// renderLoginError renders the error of a login made for the client. The
+// auth service being unavailable and the student being throttled are passed
+// through so the client knows to wait; anything else is a failed login.
+func (s *Server) renderLoginError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errors.ErrAuthServiceUnavailable):
+		if s.authBreaker != nil {
+			if wait := time.Until(s.authBreaker.Snapshot().Until); wait > 0 {
+				w.Header().Set("Retry-After", seconds(wait))
+			}
+		}
+		errors.Render(w, r, err)
+	case errors.Is(err, errors.ErrTooManyLoginAttempts):
+		errors.Render(w, r, err)
+	default:
+		errors.Render(w, r, errors.ErrLoginFailed)
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+	pb "github.com/nrmnqdds/gomaluum/internal/proto"
+	"github.com/nrmnqdds/gomaluum/pkg/breaker"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeAuthenticator answers logins with login and counts the calls.
+type fakeAuthenticator struct {
+	mu    sync.Mutex
+	calls int
+	login func(ctx context.Context) error
+}
+
+func (f *fakeAuthenticator) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+	if err := f.login(ctx); err != nil {
+		return nil, err
+	}
+	return &pb.LoginResponse{Username: req.Username, Password: req.Password, Token: "cookie"}, nil
+}
+
+func (f *fakeAuthenticator) Close() error { return nil }
+
+func TestGuardedAuthenticator(t *testing.T) {
+	fake := &fakeAuthenticator{}
+	g := newGuardedAuthenticator(fake)
+	g.backoff = time.Millisecond
+	g.attemptTimeout = 20 * time.Millisecond
+	req := &pb.LoginRequest{Username: "2110001", Password: "secret"}
+
+	fake.login = func(context.Context) error { return errors.ErrLoginFailed }
+	_, err := g.Login(t.Context(), req)
+	require.Equal(t, errors.ErrLoginFailed, err)
+	require.Equal(t, 1, fake.calls, "wrong passwords are not retried")
+	require.Equal(t, breaker.Snapshot{State: breaker.Closed}, g.breaker.Snapshot())
+
+	// Each attempt gets its own deadline, rather than hanging on a slow GAS.
+	fake.calls = 0
+	fake.login = func(ctx context.Context) error {
+		<-ctx.Done()
+		return status.Error(codes.DeadlineExceeded, "slow")
+	}
+	start := time.Now()
+	_, err = g.Login(t.Context(), req)
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	require.Equal(t, authAttempts, fake.calls)
+	require.Less(t, time.Since(start), time.Second)
+	require.Equal(t, authAttempts, g.breaker.Snapshot().Failures)
+
+	// Retries stop when the login as a whole runs out of time.
+	slow := newGuardedAuthenticator(fake)
+	slow.backoff = time.Millisecond
+	slow.attemptTimeout = time.Second
+	slow.budget = 50 * time.Millisecond
+	fake.calls = 0
+	start = time.Now()
+	_, err = slow.Login(t.Context(), req)
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	require.Equal(t, 1, fake.calls)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+	require.Equal(t, 1, slow.breaker.Snapshot().Failures, "a slow service is a failing one")
+
+	// The circuit opens mid-retry, and then refuses without calling GAS.
+	fake.calls = 0
+	fake.login = func(context.Context) error { return status.Error(codes.Unavailable, "down") }
+	_, err = g.Login(t.Context(), req)
+	require.Equal(t, errors.ErrAuthServiceUnavailable, err)
+	require.Equal(t, authBreakerThreshold-authAttempts, fake.calls)
+	_, err = g.Login(t.Context(), req)
+	require.Equal(t, errors.ErrAuthServiceUnavailable, err)
+	require.Equal(t, authBreakerThreshold-authAttempts, fake.calls)
+	require.True(t, isUpstreamFailure(err), "an open circuit is an outage, not a failed login")
+
+	// Clients are told to come back when the circuit lets a probe through.
+	s := newTestServer()
+	s.auth, s.authBreaker = g, g.breaker
+	r := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(`{"username":"2110001","password":"secret"}`))
+	w := httptest.NewRecorder()
+	s.LoginHandler(w, r)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "30", w.Header().Get("Retry-After"))
+	require.Zero(t, s.loginThrottle.wait(userLoginSubjects("2110001", "192.0.2.1")), "outages don't count as failed logins")
+
+	// Wrapped errors are recognised too.
+	w = httptest.NewRecorder()
+	s.renderLoginError(w, r, errors.Wrap(errors.ErrAuthServiceUnavailable, context.DeadlineExceeded))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.True(t, isBadCredentials(errors.Wrap(errors.ErrLoginFailed, context.Canceled)))
+}
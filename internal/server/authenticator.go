@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	pb "github.com/nrmnqdds/gomaluum/internal/proto"
+)
+
+// Authenticator logs students into i-Ma'luum through IIUM's CAS and returns
+// the session cookie as the response's Token.
+type Authenticator interface {
+	Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error)
+	Close() error
+}
+
+var (
+	_ Authenticator = (*GRPCClient)(nil)
+	_ Authenticator = (*casAuthenticator)(nil)
+)
+
+// NewAuthenticator returns the authenticator chosen by AUTHENTICATOR:
+//
+//	gas    the external GAS service at GAS_SERVICE_URL (see NewGRPCClient)
+//	local  the built-in CAS login, so gomaluum runs as a single binary
+//
+// Unset picks gas when GAS_SERVICE_URL is set, otherwise local.
+func NewAuthenticator() (Authenticator, error) {
+	gasServiceURL := os.Getenv("GAS_SERVICE_URL")
+
+	mode := os.Getenv("AUTHENTICATOR")
+	if mode == "" {
+		mode = "local"
+		if gasServiceURL != "" {
+			mode = "gas"
+		}
+	}
+
+	switch mode {
+	case "gas":
+		if gasServiceURL == "" {
+			return nil, fmt.Errorf("AUTHENTICATOR=gas requires GAS_SERVICE_URL")
+		}
+		return NewGRPCClient(gasServiceURL)
+	case "local":
+		return newCASAuthenticator()
+	}
+	return nil, fmt.Errorf("AUTHENTICATOR must be gas or local, got %q", mode)
+}
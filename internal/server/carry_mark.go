@@ -1,7 +1,7 @@
 package server
 
 import (
-	"fmt"
+	"context"
 	"net/http"
 	"strings"
 	"sync"
@@ -10,10 +10,10 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/bytedance/sonic"
 	"github.com/gocolly/colly/v2"
-	"github.com/lucsky/cuid"
 	"github.com/nrmnqdds/gomaluum/internal/constants"
 	"github.com/nrmnqdds/gomaluum/internal/dtos"
 	"github.com/nrmnqdds/gomaluum/internal/errors"
+	"github.com/nrmnqdds/gomaluum/pkg/utils"
 )
 
 var carryMarkTdPool = sync.Pool{
@@ -36,20 +36,10 @@ func extractSession(scriptContent string) string {
 	return rest[:end]
 }
 
-// @Title CarryMarkHandler
-// @Description Get continuous assessment marks from i-Ma'luum
-// @Tags scraper
-// @Produce json
-// @Param x-gomaluum-key header string false "API key for additional security layer"
-// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
-// @Success 200 {object} dtos.ResponseDTO
-// @Failure 404 {object} errors.CustomError "No carry mark data found"
-// @Router /api/carry-mark [get]
-func (s *Server) CarryMarkHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
+// fetchCarryMarks scrapes the request user's continuous assessment marks for
+// the current session. It returns ErrNoCarryMark when i-Ma'luum lists none.
+func (s *Server) fetchCarryMarks(ctx context.Context) (*dtos.CarryMark, error) {
 	var (
-		logger         = s.log
 		mu             sync.Mutex
 		subjects       []dtos.CarryMarkSubject
 		currentSubject *dtos.CarryMarkSubject
@@ -58,7 +48,7 @@ func (s *Server) CarryMarkHandler(w http.ResponseWriter, r *http.Request) {
 
 	// NOTE: currentSubject pointer tracking relies on synchronous callback execution.
 	// Do NOT add colly.Async() — it would invalidate the pointer after slice reallocation.
-	if err := s.scrapeWithRetry(r.Context(), func(cookie string) (bool, error) {
+	if err := s.scrapeWithRetry(ctx, func(cookie string) (bool, error) {
 		// Reset accumulators so a retry starts clean.
 		mu.Lock()
 		subjects = subjects[:0]
@@ -67,7 +57,7 @@ func (s *Server) CarryMarkHandler(w http.ResponseWriter, r *http.Request) {
 		mu.Unlock()
 
 		var stale atomic.Bool
-		c := s.newImaluumCollector(r.Context(), cookie, &stale)
+		c := s.newImaluumCollector(ctx, cookie, &stale)
 
 		c.OnHTML("script", func(e *colly.HTMLElement) {
 			content := strings.TrimSpace(e.Text)
@@ -98,7 +88,7 @@ func (s *Server) CarryMarkHandler(w http.ResponseWriter, r *http.Request) {
 
 			if code != "" {
 				subject := dtos.CarryMarkSubject{
-					ID:             fmt.Sprintf("gomaluum:carry-mark-subject:%s", cuid.Slug()),
+					ID:             utils.StableID("carry-mark-subject", session, code, tds[1]),
 					Code:           code,
 					Section:        tds[1],
 					Course:         tds[2],
@@ -112,7 +102,7 @@ func (s *Server) CarryMarkHandler(w http.ResponseWriter, r *http.Request) {
 				mu.Unlock()
 			} else if name != "" && currentSubject != nil {
 				component := dtos.CarryMarkComponent{
-					ID:           fmt.Sprintf("gomaluum:carry-mark-component:%s", cuid.Slug()),
+					ID:           utils.StableID("carry-mark-component", session, currentSubject.Code, currentSubject.Section, name),
 					Name:         name,
 					MarkingScore: tds[3],
 					ActualScore:  tds[4],
@@ -130,26 +120,50 @@ func (s *Server) CarryMarkHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return stale.Load(), nil
 	}); err != nil {
-		logger.ErrorContext(r.Context(), "Failed to scrape carry marks", "error", err)
-		errors.Render(w, r, err)
-		return
+		return nil, err
 	}
 
 	if len(subjects) == 0 {
-		logger.ErrorContext(r.Context(), "Carry mark data is empty")
-		errors.Render(w, r, errors.ErrNoCarryMark)
-		return
+		return nil, errors.ErrNoCarryMark
 	}
 
-	carryMark := &dtos.CarryMark{
-		ID:       fmt.Sprintf("gomaluum:carry-mark:%s", cuid.Slug()),
+	return &dtos.CarryMark{
+		ID:       utils.StableID("carry-mark", requestUsername(ctx), session),
 		Session:  session,
 		Subjects: subjects,
+	}, nil
+}
+
+// @Title CarryMarkHandler
+// @Description Get continuous assessment marks from i-Ma'luum. Served from cache for up to 30 minutes unless refresh is set.
+// @Tags scraper
+// @Produce json
+// @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Param refresh query bool false "Bypass the cache and re-scrape"
+// @Success 200 {object} dtos.ResponseDTO
+// @Failure 404 {object} errors.CustomError "No carry mark data found"
+// @Router /api/carry-mark [get]
+func (s *Server) CarryMarkHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	logger := s.log
+
+	carryMark, err := cachedResource(r.Context(), s, carryMarkResource, r.URL.Query().Has("refresh"), s.fetchCarryMarks)
+	if err != nil {
+		if err == errors.ErrNoCarryMark {
+			logger.ErrorContext(r.Context(), "Carry mark data is empty")
+		} else {
+			logger.ErrorContext(r.Context(), "Failed to scrape carry marks", "error", err)
+		}
+		errors.Render(w, r, err)
+		return
 	}
 
 	response := &dtos.ResponseDTO{
 		Message: "Successfully fetched carry marks",
 		Data:    carryMark,
+		Stale:   staleness(r.Context(), w),
 	}
 
 	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
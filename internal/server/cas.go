@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/nrmnqdds/gomaluum/internal/constants"
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+	pb "github.com/nrmnqdds/gomaluum/internal/proto"
+)
+
+// casAuthenticator logs in in-process, the way a browser would: it loads the
+// CAS login form, submits the student's credentials with the form's hidden
+// fields, and follows the redirects back to i-Ma'luum, which sets the
+// MOD_AUTH_CAS session cookie.
+type casAuthenticator struct {
+	transport http.RoundTripper
+
+	// loginURL is the CAS login page with i-Ma'luum as the service, and
+	// serviceURL is i-Ma'luum, whose cookie is the session.
+	loginURL   string
+	serviceURL string
+}
+
+func newCASAuthenticator() (*casAuthenticator, error) {
+	client, err := createHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	return &casAuthenticator{
+		transport:  client.Transport,
+		loginURL:   constants.ImaluumCasPage,
+		serviceURL: constants.ImaluumPage,
+	}, nil
+}
+
+func (c *casAuthenticator) String() string {
+	return "the built-in CAS login"
+}
+
+func (c *casAuthenticator) Close() error {
+	return nil
+}
+
+// Login returns the i-Ma'luum session cookie for the student. Wrong
+// credentials give errors.ErrLoginFailed; CAS or i-Ma'luum being unreachable
+// gives errors.ErrFailedToGoToURL.
+func (c *casAuthenticator) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrCookieJarCreationFailed, err)
+	}
+	client := &http.Client{
+		Transport: c.transport,
+		Jar:       jar,
+		Timeout:   30 * time.Second,
+	}
+
+	action, form, err := c.loginForm(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	form.Set("username", req.Username)
+	form.Set("password", req.Password)
+
+	post, err := http.NewRequestWithContext(ctx, http.MethodPost, action, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrURLParseFailed, err)
+	}
+	setHeaders(post)
+	post.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(post)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrFailedToGoToURL, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, errors.ErrFailedToGoToURL
+	}
+
+	service, err := url.Parse(c.serviceURL)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrURLParseFailed, err)
+	}
+	for _, cookie := range jar.Cookies(service) {
+		if cookie.Name == "MOD_AUTH_CAS" && cookie.Value != "" {
+			return &pb.LoginResponse{
+				Username: req.Username,
+				Password: req.Password,
+				Token:    cookie.Value,
+			}, nil
+		}
+	}
+	// CAS re-renders the form instead of redirecting on bad credentials.
+	return nil, errors.ErrLoginFailed
+}
+
+// loginForm loads the CAS login page and returns where its form posts to and
+// the form's hidden fields (such as the execution token CAS expects back).
+func (c *casAuthenticator) loginForm(ctx context.Context, client *http.Client) (string, url.Values, error) {
+	get, err := http.NewRequestWithContext(ctx, http.MethodGet, c.loginURL, nil)
+	if err != nil {
+		return "", nil, errors.Wrap(errors.ErrURLParseFailed, err)
+	}
+	setHeaders(get)
+
+	resp, err := client.Do(get)
+	if err != nil {
+		return "", nil, errors.Wrap(errors.ErrFailedToGoToURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, errors.ErrFailedToGoToURL
+	}
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return "", nil, errors.Wrap(errors.ErrFailedToGoToURL, err)
+	}
+	form := doc.Find(`form:has(input[name="password"])`).First()
+	if form.Length() == 0 {
+		return "", nil, errors.ErrFailedToGoToURL
+	}
+
+	// The form posts back to the page it came from unless it says otherwise.
+	action := resp.Request.URL
+	if raw, ok := form.Attr("action"); ok && raw != "" {
+		ref, err := url.Parse(raw)
+		if err != nil {
+			return "", nil, errors.Wrap(errors.ErrURLParseFailed, err)
+		}
+		action = action.ResolveReference(ref)
+	}
+
+	fields := url.Values{}
+	form.Find(`input[type="hidden"]`).Each(func(_ int, input *goquery.Selection) {
+		if name, ok := input.Attr("name"); ok && name != "" {
+			fields.Set(name, input.AttrOr("value", ""))
+		}
+	})
+	if fields.Get("_eventId") == "" {
+		fields.Set("_eventId", "submit")
+	}
+	return action.String(), fields, nil
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+	pb "github.com/nrmnqdds/gomaluum/internal/proto"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCAS mimics IIUM's CAS in front of i-Ma'luum: the login form carries an
+// execution token, good credentials redirect to the service with a ticket, and
+// the service trades the ticket for the MOD_AUTH_CAS cookie.
+func fakeCAS(t *testing.T, down *bool) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cas/login", func(w http.ResponseWriter, r *http.Request) {
+		if *down {
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`<html><body>
+<form id="fm1" method="post" action="login?service=%2Fhome">
+  <input id="username" name="username" type="text">
+  <input id="password" name="password" type="password">
+  <input type="hidden" name="execution" value="e1s1-token">
+  <input type="hidden" name="_eventId" value="submit">
+</form></body></html>`))
+	})
+	mux.HandleFunc("POST /cas/login", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "/home", r.URL.Query().Get("service"), "the form action is resolved against the login page")
+		require.Equal(t, "e1s1-token", r.PostForm.Get("execution"))
+		if r.PostForm.Get("username") != "2110001" || r.PostForm.Get("password") != "secret" {
+			w.Write([]byte(`<form method="post"><input name="password" type="password"></form>`))
+			return
+		}
+		http.Redirect(w, r, "/home?ticket=ST-1", http.StatusFound)
+	})
+	mux.HandleFunc("GET /home", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "ST-1", r.URL.Query().Get("ticket"))
+		http.SetCookie(w, &http.Cookie{Name: "MOD_AUTH_CAS", Value: "session-cookie", Path: "/"})
+		w.Write([]byte("home"))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCASAuthenticator(t *testing.T) {
+	down := false
+	srv := fakeCAS(t, &down)
+	auth := &casAuthenticator{
+		transport:  srv.Client().Transport,
+		loginURL:   srv.URL + "/cas/login",
+		serviceURL: srv.URL + "/home",
+	}
+
+	resp, err := auth.Login(t.Context(), &pb.LoginRequest{Username: "2110001", Password: "secret"})
+	require.NoError(t, err)
+	require.Equal(t, "session-cookie", resp.Token)
+	require.Equal(t, "2110001", resp.Username)
+
+	_, err = auth.Login(t.Context(), &pb.LoginRequest{Username: "2110001", Password: "wrong"})
+	require.ErrorIs(t, err, errors.ErrLoginFailed)
+	require.False(t, isUpstreamFailure(err), "wrong passwords count against the login throttle")
+
+	down = true
+	_, err = auth.Login(t.Context(), &pb.LoginRequest{Username: "2110001", Password: "secret"})
+	require.Error(t, err)
+	require.True(t, isUpstreamFailure(err), "CAS being down is not the student's fault")
+	require.ErrorIs(t, err, errors.ErrFailedToGoToURL)
+	require.False(t, isUpstreamFailure(&errors.CustomError{Message: errors.ErrFailedToGoToURL.Message}), "errors are told apart by identity, not message")
+
+	// Wrapped errors still render as their predefined error.
+	w := httptest.NewRecorder()
+	errors.Render(w, httptest.NewRequest(http.MethodGet, "/", nil), err)
+	require.Equal(t, errors.ErrFailedToGoToURL.StatusCode, w.Code)
+	require.Contains(t, w.Body.String(), errors.ErrFailedToGoToURL.Message)
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	t.Setenv("GAS_SERVICE_URL", "")
+	t.Setenv("AUTHENTICATOR", "")
+	auth, err := NewAuthenticator()
+	require.NoError(t, err)
+	require.IsType(t, &casAuthenticator{}, auth, "without GAS the built-in login is used")
+
+	t.Setenv("AUTHENTICATOR", "gas")
+	_, err = NewAuthenticator()
+	require.Error(t, err, "gas requires GAS_SERVICE_URL")
+
+	t.Setenv("GAS_SERVICE_URL", "localhost:50051")
+	t.Setenv("AUTHENTICATOR", "")
+	auth, err = NewAuthenticator()
+	require.NoError(t, err)
+	require.IsType(t, &GRPCClient{}, auth)
+	require.NoError(t, auth.Close())
+
+	t.Setenv("AUTHENTICATOR", "local")
+	auth, err = NewAuthenticator()
+	require.NoError(t, err)
+	require.IsType(t, &casAuthenticator{}, auth)
+
+	t.Setenv("AUTHENTICATOR", "ldap")
+	_, err = NewAuthenticator()
+	require.Error(t, err)
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+)
+
+// scheduleConflicts reports every pair of weekly slots in session that
+// overlap on the same day. Slots of the same course and section never clash
+// with each other, and since i-Ma'luum repeats them across merged rows, a
+// clash between two sections at the same time is reported once. Slots with an
+// unknown day or malformed time are ignored.
+func scheduleConflicts(session dtos.ScheduleResponse) []dtos.ScheduleConflict {
+	type slot struct {
+		subject dtos.ScheduleSubject
+		time    dtos.WeekTime
+	}
+	type section struct {
+		code    string
+		section uint32
+	}
+	type clash struct {
+		a, b       section
+		day        uint8
+		start, end string
+	}
+
+	var slots []slot
+	for _, subject := range session.Schedule {
+		for _, t := range subject.Timestamps {
+			if t.Day > uint8(time.Saturday) || len(t.Start) != 4 || len(t.End) != 4 || t.Start >= t.End {
+				continue
+			}
+			slots = append(slots, slot{subject: subject, time: t})
+		}
+	}
+	slices.SortStableFunc(slots, func(a, b slot) int {
+		return cmp.Or(cmp.Compare(a.time.Day, b.time.Day), strings.Compare(a.time.Start, b.time.Start))
+	})
+
+	conflicts := []dtos.ScheduleConflict{}
+	seen := map[clash]bool{}
+	for i, a := range slots {
+		for _, b := range slots[i+1:] {
+			if b.time.Day != a.time.Day || b.time.Start >= a.time.End {
+				// Sorted by day and start: nothing later can overlap a.
+				break
+			}
+			if a.subject.CourseCode == b.subject.CourseCode && a.subject.Section == b.subject.Section {
+				continue
+			}
+
+			key := clash{
+				a:     section{a.subject.CourseCode, a.subject.Section},
+				b:     section{b.subject.CourseCode, b.subject.Section},
+				day:   a.time.Day,
+				start: b.time.Start,
+				end:   min(a.time.End, b.time.End),
+			}
+			if cmp.Or(strings.Compare(key.a.code, key.b.code), cmp.Compare(key.a.section, key.b.section)) > 0 {
+				key.a, key.b = key.b, key.a
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			conflicts = append(conflicts, dtos.ScheduleConflict{
+				Day:      key.day,
+				Start:    key.start,
+				End:      key.end,
+				Subjects: []dtos.ScheduleConflictSubject{conflictSubject(a.subject, a.time), conflictSubject(b.subject, b.time)},
+			})
+		}
+	}
+	return conflicts
+}
+
+func conflictSubject(subject dtos.ScheduleSubject, t dtos.WeekTime) dtos.ScheduleConflictSubject {
+	return dtos.ScheduleConflictSubject{
+		ID:         subject.ID,
+		CourseCode: subject.CourseCode,
+		CourseName: subject.CourseName,
+		Venue:      subject.Venue,
+		Section:    subject.Section,
+		Start:      t.Start,
+		End:        t.End,
+	}
+}
+
+// examConflicts reports every pair of final exams whose time windows overlap.
+// Exams whose date or time cannot be parsed are compared by their raw text
+// instead, so an identical unparsable slot is still flagged; placeholders
+// such as "TBA" are not a slot and never clash.
+func examConflicts(exams []dtos.FinalExamItem) []dtos.ExamConflict {
+	conflicts := []dtos.ExamConflict{}
+	for i, a := range exams {
+		aStart, aEnd, aOK := examWindow(a)
+		for _, b := range exams[i+1:] {
+			bStart, bEnd, bOK := examWindow(b)
+
+			switch {
+			case aOK && bOK:
+				if !aStart.Before(bEnd) || !bStart.Before(aEnd) {
+					continue
+				}
+				start, end := aStart, aEnd
+				if bStart.After(start) {
+					start = bStart
+				}
+				if bEnd.Before(end) {
+					end = bEnd
+				}
+				conflicts = append(conflicts, dtos.ExamConflict{
+					Start: start.Format(time.RFC3339),
+					End:   end.Format(time.RFC3339),
+					Exams: []dtos.FinalExamItem{a, b},
+				})
+			case sameExamText(a, b):
+				conflicts = append(conflicts, dtos.ExamConflict{Exams: []dtos.FinalExamItem{a, b}})
+			}
+		}
+	}
+	return conflicts
+}
+
+func sameExamText(a, b dtos.FinalExamItem) bool {
+	norm := func(s string) string { return strings.ToUpper(strings.Join(strings.Fields(s), " ")) }
+	date, tm := norm(a.Date), norm(a.Time)
+	if examPlaceholder(date) || examPlaceholder(tm) {
+		return false
+	}
+	return date == norm(b.Date) && tm == norm(b.Time)
+}
+
+// examPlaceholder reports whether a normalised exam date or time is a
+// stand-in for one that hasn't been scheduled yet.
+func examPlaceholder(s string) bool {
+	switch strings.Trim(s, ".-/ ") {
+	case "", "TBA", "TBC", "TBD", "N/A", "NA":
+		return true
+	}
+	return false
+}
+
+// @Title ConflictsHandler
+// @Description Report timetable clashes: overlapping weekly class slots in the latest session, and final exams whose date and time windows overlap.
+// @Tags scraper
+// @Produce json
+// @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Param refresh query bool false "Bypass the schedule and exam caches and re-scrape"
+// @Success 200 {object} dtos.ResponseDTO{data=dtos.Conflicts}
+// @Router /api/conflicts [get]
+func (s *Server) ConflictsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		logger  = s.log
+		refresh = r.URL.Query().Has("refresh")
+	)
+
+	schedules, err := s.fetchSchedules(r.Context(), refresh)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Failed to scrape schedule", "error", err)
+		errors.Render(w, r, err)
+		return
+	}
+
+	// No exam timetable is the usual case outside exam season, not an error.
+	exams, err := cachedResource(r.Context(), s, finalExamResource, refresh, s.fetchFinalExams)
+	if err != nil && err != errors.ErrNoFinalExam {
+		logger.ErrorContext(r.Context(), "Failed to scrape final exam timetable", "error", err)
+		errors.Render(w, r, err)
+		return
+	}
+
+	// fetchSchedules sorts newest first.
+	latest := schedules[0]
+	response := &dtos.ResponseDTO{
+		Message: "Successfully checked timetable conflicts",
+		Data: &dtos.Conflicts{
+			SessionName: latest.SessionName,
+			Schedule:    scheduleConflicts(latest),
+			Exams:       examConflicts(exams),
+		},
+		Stale: staleness(r.Context(), w),
+	}
+
+	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
+		logger.ErrorContext(r.Context(), "Failed to encode response", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+	}
+}
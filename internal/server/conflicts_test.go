@@ -0,0 +1,61 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleConflicts(t *testing.T) {
+	session := dtos.ScheduleResponse{Schedule: []dtos.ScheduleSubject{
+		{CourseCode: "INFO4335", Section: 1, Timestamps: []dtos.WeekTime{
+			{Start: "0830", End: "0950", Day: 1},
+			{Start: "0830", End: "0950", Day: 3},
+		}},
+		// Repeated slot of the same section (merged row): not a clash.
+		{CourseCode: "INFO4335", Section: 1, Timestamps: []dtos.WeekTime{{Start: "0830", End: "0950", Day: 1}}},
+		{CourseCode: "INFO3150", Section: 2, Timestamps: []dtos.WeekTime{
+			{Start: "0900", End: "1020", Day: 1},
+			{Start: "0950", End: "1110", Day: 3}, // back-to-back, not a clash
+		}},
+		{CourseCode: "LE4000", Section: 5, Timestamps: []dtos.WeekTime{{Start: "0900", End: "1020", Day: 7}}},
+	}}
+
+	conflicts := scheduleConflicts(session)
+	require.Len(t, conflicts, 1, "the merged row repeats the clash, it doesn't add one")
+	c := conflicts[0]
+	require.Equal(t, uint8(1), c.Day)
+	require.Equal(t, "0900", c.Start)
+	require.Equal(t, "0950", c.End)
+	require.Equal(t, "INFO4335", c.Subjects[0].CourseCode)
+	require.Equal(t, "INFO3150", c.Subjects[1].CourseCode)
+
+	require.Empty(t, scheduleConflicts(dtos.ScheduleResponse{}))
+}
+
+func TestExamConflicts(t *testing.T) {
+	exams := []dtos.FinalExamItem{
+		{SubjectCode: "A", Date: "15-01-2026", Time: "9:00 AM - 12:00 PM"},
+		{SubjectCode: "B", Date: "15-01-2026", Time: "11:00 AM - 1:00 PM"},
+		{SubjectCode: "C", Date: "15-01-2026", Time: "2:30 PM - 5:30 PM"},
+		{SubjectCode: "D", Date: "TBA", Time: "TBA"},
+		{SubjectCode: "E", Date: "tba", Time: "TBA"}, // not scheduled yet, not a clash
+		{SubjectCode: "F", Date: "", Time: ""},
+		{SubjectCode: "G", Date: "", Time: ""},
+		{SubjectCode: "H", Date: "Week 15", Time: "Morning"},
+		{SubjectCode: "I", Date: "WEEK 15", Time: "morning"},
+	}
+
+	conflicts := examConflicts(exams)
+	require.Len(t, conflicts, 2)
+
+	require.Equal(t, "A", conflicts[0].Exams[0].SubjectCode)
+	require.Equal(t, "B", conflicts[0].Exams[1].SubjectCode)
+	require.Equal(t, "2026-01-15T11:00:00+08:00", conflicts[0].Start)
+	require.Equal(t, "2026-01-15T12:00:00+08:00", conflicts[0].End)
+
+	require.Equal(t, "H", conflicts[1].Exams[0].SubjectCode)
+	require.Equal(t, "I", conflicts[1].Exams[1].SubjectCode)
+	require.Empty(t, conflicts[1].Start)
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nrmnqdds/gomaluum/pkg/vault"
+)
+
+// credentialVault keeps users' i-Ma'luum passwords server-side, so access
+// tokens only carry an opaque handle instead of the password. Records are
+// envelope-encrypted (see pkg/vault), bound to their handle and username, and
+// live as long as the token that references them.
+type credentialVault struct {
+	vault *vault.Vault
+	store credentialStore
+}
+
+// credentialStore persists sealed credentials by handle.
+type credentialStore interface {
+	Put(ctx context.Context, handle, username string, sealed vault.Sealed, expiresAt time.Time) error
+	Get(ctx context.Context, handle string) (sealed vault.Sealed, found bool, err error)
+	Delete(ctx context.Context, handle string) error
+	DeleteUser(ctx context.Context, username string) error
+}
+
+// newCredentialVault returns a vault backed by Postgres when a database is
+// configured, otherwise by memory. In-memory records are lost on restart;
+// clients then get ErrAccessTokenExpired and use their refresh token.
+func newCredentialVault(masterKey []byte, db *sql.DB) (*credentialVault, error) {
+	v, err := vault.New(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	var store credentialStore = &memoryCredentialStore{records: make(map[string]memoryCredential)}
+	if db != nil {
+		store = &postgresCredentialStore{db: db}
+	}
+	return &credentialVault{vault: v, store: store}, nil
+}
+
+// credentialMasterKey returns the vault master key, CREDENTIAL_MASTER_KEY (hex).
+func credentialMasterKey() ([]byte, error) {
+	return masterKey("CREDENTIAL_MASTER_KEY")
+}
+
+// masterKey returns the required hex key in the env variable. Master keys are
+// configured on their own rather than derived from the PASETO keys, so
+// rotating those never makes sealed records unreadable.
+func masterKey(env string) ([]byte, error) {
+	configured := os.Getenv(env)
+	if configured == "" {
+		return nil, fmt.Errorf("%s is required", env)
+	}
+	return hex.DecodeString(configured)
+}
+
+// credentialAAD binds a sealed record to its handle and owner.
+func credentialAAD(handle, username string) []byte {
+	return []byte(handle + "\x00" + username)
+}
+
+// Store seals password for username until expiresAt and returns the handle to
+// put in the token.
+func (c *credentialVault) Store(ctx context.Context, username, password string, expiresAt time.Time) (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+	handle := hex.EncodeToString(id[:])
+
+	sealed, err := c.vault.Seal([]byte(password), credentialAAD(handle, username))
+	if err != nil {
+		return "", err
+	}
+	if err := c.store.Put(ctx, handle, username, sealed, expiresAt); err != nil {
+		return "", err
+	}
+	return handle, nil
+}
+
+// Password returns username's password behind handle. found is false when the
+// record is gone: expired, revoked, or lost with an in-memory store.
+func (c *credentialVault) Password(ctx context.Context, handle, username string) (password string, found bool, err error) {
+	sealed, found, err := c.store.Get(ctx, handle)
+	if err != nil || !found {
+		return "", false, err
+	}
+	plaintext, err := c.vault.Open(sealed, credentialAAD(handle, username))
+	if err != nil {
+		return "", false, err
+	}
+	return string(plaintext), true, nil
+}
+
+// Forget deletes the record behind handle.
+func (c *credentialVault) Forget(ctx context.Context, handle string) error {
+	return c.store.Delete(ctx, handle)
+}
+
+// ForgetUser deletes every record of username.
+func (c *credentialVault) ForgetUser(ctx context.Context, username string) error {
+	return c.store.DeleteUser(ctx, username)
+}
+
+type memoryCredential struct {
+	username  string
+	sealed    vault.Sealed
+	expiresAt time.Time
+}
+
+type memoryCredentialStore struct {
+	mu      sync.RWMutex
+	records map[string]memoryCredential
+}
+
+func (m *memoryCredentialStore) Put(_ context.Context, handle, username string, sealed vault.Sealed, expiresAt time.Time) error {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for h, rec := range m.records {
+		if now.After(rec.expiresAt) {
+			delete(m.records, h)
+		}
+	}
+	m.records[handle] = memoryCredential{username: username, sealed: sealed, expiresAt: expiresAt}
+	return nil
+}
+
+func (m *memoryCredentialStore) Get(_ context.Context, handle string) (vault.Sealed, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rec, ok := m.records[handle]
+	if !ok || time.Now().After(rec.expiresAt) {
+		return vault.Sealed{}, false, nil
+	}
+	return rec.sealed, true, nil
+}
+
+func (m *memoryCredentialStore) Delete(_ context.Context, handle string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.records, handle)
+	return nil
+}
+
+func (m *memoryCredentialStore) DeleteUser(_ context.Context, username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for h, rec := range m.records {
+		if rec.username == username {
+			delete(m.records, h)
+		}
+	}
+	return nil
+}
+
+type postgresCredentialStore struct {
+	db *sql.DB
+}
+
+func (p *postgresCredentialStore) Put(ctx context.Context, handle, username string, sealed vault.Sealed, expiresAt time.Time) error {
+	if _, err := p.db.ExecContext(ctx, `DELETE FROM credentials WHERE expires_at < CURRENT_TIMESTAMP`); err != nil {
+		return err
+	}
+	_, err := p.db.ExecContext(ctx, `
+			INSERT INTO credentials (handle, username, wrapped_key, ciphertext, expires_at)
+			VALUES ($1, $2, $3, $4, $5)
+		`, handle, username, sealed.WrappedKey, sealed.Ciphertext, expiresAt)
+	return err
+}
+
+func (p *postgresCredentialStore) Get(ctx context.Context, handle string) (vault.Sealed, bool, error) {
+	var sealed vault.Sealed
+	err := p.db.QueryRowContext(ctx, `
+			SELECT wrapped_key, ciphertext FROM credentials
+			WHERE handle = $1 AND expires_at > CURRENT_TIMESTAMP
+		`, handle).Scan(&sealed.WrappedKey, &sealed.Ciphertext)
+	if err == sql.ErrNoRows {
+		return vault.Sealed{}, false, nil
+	}
+	if err != nil {
+		return vault.Sealed{}, false, err
+	}
+	return sealed, true, nil
+}
+
+func (p *postgresCredentialStore) Delete(ctx context.Context, handle string) error {
+	_, err := p.db.ExecContext(ctx, `DELETE FROM credentials WHERE handle = $1`, handle)
+	return err
+}
+
+func (p *postgresCredentialStore) DeleteUser(ctx context.Context, username string) error {
+	_, err := p.db.ExecContext(ctx, `DELETE FROM credentials WHERE username = $1`, username)
+	return err
+}
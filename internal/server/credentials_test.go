@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	gopaseto "aidanwoods.dev/go-paseto"
+	"github.com/cristalhq/base64"
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+	"github.com/nrmnqdds/gomaluum/pkg/apikey"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessTokenCarriesNoPassword(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+	payload := TokenPayload{username: "2110000", password: "p@ss", imaluumCookie: "cookie", apiKey: apikey.DefaultAPIKey}
+
+	token, _, err := s.GeneratePasetoToken(ctx, payload)
+	require.NoError(t, err)
+
+	// Anyone can read v4.public claims; with the default API key they used to
+	// be able to decrypt the password too.
+	claims, err := gopaseto.NewParser().ParseV4Public(*s.paseto.PublicKey, token, nil)
+	require.NoError(t, err)
+	_, err = claims.GetString("password")
+	require.Error(t, err)
+	handle, err := claims.GetString("cred")
+	require.NoError(t, err)
+	require.False(t, strings.Contains(string(claims.ClaimsJSON()), base64.StdEncoding.EncodeToString([]byte("p@ss"))))
+
+	sess, err := s.DecodePasetoToken(ctx, token, apikey.DefaultAPIKey)
+	require.NoError(t, err)
+	require.Equal(t, "p@ss", sess.password, "password is read back from the vault")
+
+	// The handle is bound to its owner.
+	_, found, err := s.credentials.Password(ctx, handle, "2110001")
+	require.Error(t, err)
+	require.False(t, found)
+
+	// A lost record (e.g. an in-memory vault after a restart) sends the client
+	// to its refresh token.
+	require.NoError(t, s.credentials.Forget(ctx, handle))
+	_, err = s.DecodePasetoToken(ctx, token, apikey.DefaultAPIKey)
+	require.Equal(t, errors.ErrAccessTokenExpired, err)
+}
+
+func TestLegacyTokenPassword(t *testing.T) {
+	s := newTestServer()
+
+	encrypt := func(v string) string {
+		encrypted, err := apikey.EncryptWithAPIKey(v, "key")
+		require.NoError(t, err)
+		return encrypted
+	}
+	token := gopaseto.NewToken()
+	token.SetIssuer("gomaluum")
+	token.SetIssuedAt(time.Now())
+	token.SetExpiration(time.Now().Add(time.Hour))
+	token.SetString("imaluumCookie", encrypt("cookie"))
+	token.SetString("username", encrypt("2110000"))
+	token.SetString("password", encrypt(base64.StdEncoding.EncodeToString([]byte("p@ss"))))
+
+	sess, err := s.DecodePasetoToken(context.Background(), token.V4Sign(*s.paseto.PrivateKey, nil), "key")
+	require.NoError(t, err)
+	require.Equal(t, "p@ss", sess.password)
+	require.Equal(t, "cookie", sess.imaluumCookie)
+
+	// Legacy tokens sunset: they live at most a refresh token's lifetime, and
+	// not at all if issued before the configured cutoff.
+	token.SetIssuedAt(time.Now().Add(-refreshTokenTTL - time.Hour))
+	_, err = s.DecodePasetoToken(context.Background(), token.V4Sign(*s.paseto.PrivateKey, nil), "key")
+	require.Equal(t, errors.ErrLegacyTokenRetired, err)
+
+	token.SetIssuedAt(time.Now().Add(-time.Hour))
+	s.legacyCutoff = time.Now()
+	_, err = s.DecodePasetoToken(context.Background(), token.V4Sign(*s.paseto.PrivateKey, nil), "key")
+	require.Equal(t, errors.ErrLegacyTokenRetired, err)
+}
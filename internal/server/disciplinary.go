@@ -1,7 +1,7 @@
 package server
 
 import (
-	"fmt"
+	"context"
 	"net/http"
 	"strings"
 	"sync"
@@ -10,10 +10,10 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/bytedance/sonic"
 	"github.com/gocolly/colly/v2"
-	"github.com/lucsky/cuid"
 	"github.com/nrmnqdds/gomaluum/internal/constants"
 	"github.com/nrmnqdds/gomaluum/internal/dtos"
 	"github.com/nrmnqdds/gomaluum/internal/errors"
+	"github.com/nrmnqdds/gomaluum/pkg/utils"
 )
 
 var disciplinaryTdPool = sync.Pool{
@@ -52,7 +52,7 @@ func parseDisciplinaryRow(tds []string, actionCell *goquery.Selection, compounds
 	}
 
 	compound := dtos.DisciplinaryCompound{
-		ID:          fmt.Sprintf("gomaluum:compound:%s", cuid.Slug()),
+		ID:          utils.StableID("compound", trimmed[0], trimmed[1], trimmed[2]),
 		Session:     trimmed[0],
 		OffenceDate: trimmed[1],
 		CompoundNo:  trimmed[2],
@@ -69,32 +69,22 @@ func parseDisciplinaryRow(tds []string, actionCell *goquery.Selection, compounds
 	mu.Unlock()
 }
 
-// @Title DisciplinaryHandler
-// @Description Get compound and traffic summon records from i-Ma'luum
-// @Tags scraper
-// @Produce json
-// @Param x-gomaluum-key header string false "API key for additional security layer"
-// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
-// @Success 200 {object} dtos.ResponseDTO
-// @Failure 404 {object} errors.CustomError "No disciplinary or compound records found"
-// @Router /api/disciplinary [get]
-func (s *Server) DisciplinaryHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
+// fetchDisciplinary scrapes the request user's compound and summon records.
+// It returns ErrNoDisciplinaryRecord when i-Ma'luum lists none.
+func (s *Server) fetchDisciplinary(ctx context.Context) (*dtos.Disciplinary, error) {
 	var (
-		logger    = s.log
 		mu        sync.Mutex
 		compounds []dtos.DisciplinaryCompound
 	)
 
-	if err := s.scrapeWithRetry(r.Context(), func(cookie string) (bool, error) {
+	if err := s.scrapeWithRetry(ctx, func(cookie string) (bool, error) {
 		// Reset accumulators so a retry starts clean.
 		mu.Lock()
 		compounds = compounds[:0]
 		mu.Unlock()
 
 		var stale atomic.Bool
-		c := s.newImaluumCollector(r.Context(), cookie, &stale)
+		c := s.newImaluumCollector(ctx, cookie, &stale)
 
 		c.OnHTML("table.table.table-hover tbody tr", func(e *colly.HTMLElement) {
 			cells := e.DOM.Find("td")
@@ -123,25 +113,49 @@ func (s *Server) DisciplinaryHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return stale.Load(), nil
 	}); err != nil {
-		logger.ErrorContext(r.Context(), "Failed to scrape disciplinary records", "error", err)
-		errors.Render(w, r, err)
-		return
+		return nil, err
 	}
 
 	if len(compounds) == 0 {
-		logger.ErrorContext(r.Context(), "No disciplinary or compound records found")
-		errors.Render(w, r, errors.ErrNoDisciplinaryRecord)
-		return
+		return nil, errors.ErrNoDisciplinaryRecord
 	}
 
-	disciplinary := &dtos.Disciplinary{
-		ID:        fmt.Sprintf("gomaluum:disciplinary:%s", cuid.Slug()),
+	return &dtos.Disciplinary{
+		ID:        utils.StableID("disciplinary", requestUsername(ctx)),
 		Compounds: compounds,
+	}, nil
+}
+
+// @Title DisciplinaryHandler
+// @Description Get compound and traffic summon records from i-Ma'luum. Served from cache for up to 6 hours unless refresh is set.
+// @Tags scraper
+// @Produce json
+// @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Param refresh query bool false "Bypass the cache and re-scrape"
+// @Success 200 {object} dtos.ResponseDTO
+// @Failure 404 {object} errors.CustomError "No disciplinary or compound records found"
+// @Router /api/disciplinary [get]
+func (s *Server) DisciplinaryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	logger := s.log
+
+	disciplinary, err := cachedResource(r.Context(), s, disciplinaryResource, r.URL.Query().Has("refresh"), s.fetchDisciplinary)
+	if err != nil {
+		if err == errors.ErrNoDisciplinaryRecord {
+			logger.ErrorContext(r.Context(), "No disciplinary or compound records found")
+		} else {
+			logger.ErrorContext(r.Context(), "Failed to scrape disciplinary records", "error", err)
+		}
+		errors.Render(w, r, err)
+		return
 	}
 
 	response := &dtos.ResponseDTO{
 		Message: "Successfully fetched disciplinary records",
 		Data:    disciplinary,
+		Stale:   staleness(r.Context(), w),
 	}
 
 	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
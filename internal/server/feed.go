@@ -0,0 +1,350 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"aidanwoods.dev/go-paseto"
+	"github.com/bytedance/sonic"
+	"github.com/go-chi/chi/v5"
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+	"github.com/nrmnqdds/gomaluum/pkg/ics"
+)
+
+const (
+	// feedTokenType is the "typ" claim that keeps feed tokens from being
+	// accepted anywhere else, and other tokens from being accepted as feeds.
+	feedTokenType = "feed"
+
+	// feedTokenTTL is how long a subscription URL stays valid. Users revoke
+	// leaked URLs explicitly rather than relying on expiry.
+	feedTokenTTL = 365 * 24 * time.Hour
+
+	// feedRefreshInterval is the polling interval suggested to calendar apps.
+	// The schedule rarely changes within a semester.
+	feedRefreshInterval = 6 * time.Hour
+)
+
+// feedClaims is what a calendar feed token carries. Feed URLs end up in
+// calendar apps, proxies and logs, so the token holds only a credential vault
+// handle; the password stays server-side and dies with the feed's revocation.
+type feedClaims struct {
+	id         string
+	username   string
+	credential string // credential vault handle
+	issuedAt   time.Time
+	expiresAt  time.Time
+}
+
+// generateFeedToken mints an encrypted, read-only feed token for the given
+// credentials, sealing password in s.credentials for the token's lifetime.
+// password must be plaintext.
+func (s *Server) generateFeedToken(ctx context.Context, username, password string) (string, *feedClaims, error) {
+	id, err := newTokenID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	claims := &feedClaims{
+		id:        id,
+		username:  username,
+		issuedAt:  now,
+		expiresAt: now.Add(feedTokenTTL),
+	}
+	claims.credential, err = s.credentials.Store(ctx, username, password, claims.expiresAt)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token := paseto.NewToken()
+	token.SetIssuer("gomaluum")
+	token.SetIssuedAt(now)
+	token.SetNotBefore(now)
+	token.SetExpiration(claims.expiresAt)
+	token.SetJti(claims.id)
+	token.SetSubject(username)
+	token.SetString("typ", feedTokenType)
+	token.SetString("cred", claims.credential)
+
+	return token.V4Encrypt(*s.paseto.LocalKey, nil), claims, nil
+}
+
+// parseFeedToken decrypts and validates a feed token. It does not consult the
+// revocation list.
+func (s *Server) parseFeedToken(token string) (*feedClaims, error) {
+	parser := paseto.NewParser() // checks expiry and not-before
+	parser.AddRule(paseto.IssuedBy("gomaluum"))
+
+	decoded, err := parser.ParseV4Local(*s.paseto.LocalKey, token, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ, err := decoded.GetString("typ"); err != nil || typ != feedTokenType {
+		return nil, errors.ErrInvalidFeedToken
+	}
+
+	id, err := decoded.GetJti()
+	if err != nil {
+		return nil, err
+	}
+	username, err := decoded.GetSubject()
+	if err != nil {
+		return nil, err
+	}
+	credential, err := decoded.GetString("cred")
+	if err != nil {
+		return nil, err
+	}
+	issuedAt, err := decoded.GetIssuedAt()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt, err := decoded.GetExpiration()
+	if err != nil {
+		return nil, err
+	}
+
+	return &feedClaims{
+		id:         id,
+		username:   username,
+		credential: credential,
+		issuedAt:   issuedAt,
+		expiresAt:  expiresAt,
+	}, nil
+}
+
+// feedURLs returns the https:// and webcal:// subscription URLs for token on
+// the host the request came in on. X-Forwarded-Proto is only honoured behind
+// a trusted proxy, like the forwarded client IP (see clientIP).
+func (s *Server) feedURLs(r *http.Request, token string) (httpURL, webcalURL string) {
+	scheme := "http"
+	if r.TLS != nil || (s.trustProxy && r.Header.Get("X-Forwarded-Proto") == "https") {
+		scheme = "https"
+	}
+	path := fmt.Sprintf("%s/api/feed/%s.ics", r.Host, token)
+	return scheme + "://" + path, "webcal://" + path
+}
+
+// examEvents turns the final exam timetable into one-off VEVENTs. Exams whose
+// date or time cannot be parsed are skipped.
+func examEvents(exams []dtos.FinalExamItem) []ics.Event {
+	var events []ics.Event
+	for _, exam := range exams {
+		start, end, ok := examWindow(exam)
+		if !ok {
+			continue
+		}
+		sum := sha1.Sum(fmt.Appendf(nil, "exam|%s|%s|%s", exam.SubjectCode, exam.SubjectSection, start.Format(time.RFC3339)))
+		events = append(events, ics.Event{
+			UID:         hex.EncodeToString(sum[:]) + "@gomaluum",
+			Summary:     fmt.Sprintf("Final exam: %s %s", exam.SubjectCode, exam.SubjectName),
+			Location:    exam.Venue,
+			Description: fmt.Sprintf("Section %s\nSeat: %s", exam.SubjectSection, exam.Seat),
+			Start:       start,
+			End:         end,
+		})
+	}
+	return events
+}
+
+// @Title CreateFeedHandler
+// @Description Create a calendar subscription URL for the schedule and final exam timetable. The URL embeds a long-lived, read-only token that calendar apps can poll without an Authorization header; revoke it with /api/schedule/feed/revoke.
+// @Tags scraper
+// @Produce json
+// @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Success 200 {object} dtos.ResponseDTO{data=dtos.CalendarFeed}
+// @Router /api/schedule/feed [post]
+func (s *Server) CreateFeedHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	logger := s.log
+	sess := r.Context().Value(ctxSession).(*TokenPayload)
+
+	token, claims, err := s.generateFeedToken(r.Context(), sess.username, sess.password)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Failed to generate feed token", "error", err)
+		errors.Render(w, r, errors.ErrFailedToGenerateFeedToken)
+		return
+	}
+
+	httpURL, webcalURL := s.feedURLs(r, token)
+	response := &dtos.ResponseDTO{
+		Message: "Successfully created calendar feed",
+		Data: &dtos.CalendarFeed{
+			ID:        claims.id,
+			URL:       httpURL,
+			WebcalURL: webcalURL,
+			ExpiresAt: claims.expiresAt.Unix(),
+		},
+	}
+
+	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
+		logger.ErrorContext(r.Context(), "Failed to encode response", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+	}
+}
+
+// @Title RevokeFeedHandler
+// @Description Revoke a calendar subscription URL created by /api/schedule/feed. Only the token's owner can revoke it.
+// @Tags scraper
+// @Accept json
+// @Produce json
+// @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Param request body dtos.RevokeCalendarFeedRequest true "Feed token (the last path segment of the feed URL)"
+// @Success 200 {object} dtos.ResponseDTO
+// @Failure 401 {object} errors.CustomError "Invalid feed token"
+// @Router /api/schedule/feed/revoke [post]
+func (s *Server) RevokeFeedHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	logger := s.log
+	sess := r.Context().Value(ctxSession).(*TokenPayload)
+
+	var req dtos.RevokeCalendarFeedRequest
+	if err := sonic.ConfigFastest.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.ErrorContext(r.Context(), "Failed to decode request body", "error", err)
+		errors.Render(w, r, errors.ErrInvalidRequest)
+		return
+	}
+
+	claims, err := s.parseFeedToken(strings.TrimSuffix(req.Token, ".ics"))
+	if err != nil || claims.username != sess.username {
+		logger.WarnContext(r.Context(), "Refusing to revoke feed token", "error", err)
+		errors.Render(w, r, errors.ErrInvalidFeedToken)
+		return
+	}
+
+	if err := s.revocations.Revoke(r.Context(), claims.id, claims.username, claims.expiresAt); err != nil {
+		logger.ErrorContext(r.Context(), "Failed to revoke feed token", "error", err)
+		errors.Render(w, r, errors.ErrFailedToRevokeFeedToken)
+		return
+	}
+	if err := s.credentials.Forget(r.Context(), claims.credential); err != nil {
+		logger.WarnContext(r.Context(), "Failed to forget feed token credentials", "error", err)
+	}
+
+	response := &dtos.ResponseDTO{
+		Message: "Successfully revoked calendar feed",
+		Data:    nil,
+	}
+
+	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
+		logger.ErrorContext(r.Context(), "Failed to encode response", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+	}
+}
+
+// @Title FeedHandler
+// @Description Serve a calendar subscription feed with the latest session's classes and the final exam timetable. Authenticated by the token in the URL; schedules are served from the GEI cache when available.
+// @Tags scraper
+// @Produce text/calendar
+// @Param token path string true "Feed token, optionally suffixed with .ics"
+// @Success 200 {string} string "iCalendar feed"
+// @Failure 401 {object} errors.CustomError "Invalid, expired or revoked feed token"
+// @Router /api/feed/{token} [get]
+func (s *Server) FeedHandler(w http.ResponseWriter, r *http.Request) {
+	logger := s.log
+
+	claims, err := s.parseFeedToken(strings.TrimSuffix(chi.URLParam(r, "token"), ".ics"))
+	if err != nil {
+		logger.WarnContext(r.Context(), "Invalid feed token", "error", err)
+		errors.Render(w, r, errors.ErrInvalidFeedToken)
+		return
+	}
+
+	// The feed sends no API key; it counts against the student, like their
+	// default-key requests, before a cache miss can log them in.
+	if !s.allowRequest(w, r, s.rateLimitSubject(r, defaultAPIKeyUse, claims.username), defaultAPIKeyUse.tier) {
+		return
+	}
+
+	revoked, err := s.revocations.IsRevoked(r.Context(), claims.id, claims.username, claims.issuedAt)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Failed to check feed token revocation", "error", err)
+		errors.Render(w, r, err)
+		return
+	}
+	if revoked {
+		errors.Render(w, r, errors.ErrInvalidFeedToken)
+		return
+	}
+
+	// A revoked feed's credentials are forgotten, so a missing record means
+	// the same as a revoked token.
+	password, found, err := s.credentials.Password(r.Context(), claims.credential, claims.username)
+	if err != nil || !found {
+		logger.WarnContext(r.Context(), "Feed token credentials are gone", "username", claims.username, "error", err)
+		errors.Render(w, r, errors.ErrInvalidFeedToken)
+		return
+	}
+
+	cookie, err := s.tokenManager.GetToken(claims.username, s.loginFunc(r.Context(), claims.username, password, claims.credential))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Failed to log in for feed", "error", err)
+		s.renderLoginError(w, r, err)
+		return
+	}
+
+	// No API key: the feed doesn't know the student's, and sealing their
+	// cached data under another would evict the entries their key can read.
+	sess := &TokenPayload{
+		username:      claims.username,
+		password:      password,
+		imaluumCookie: cookie,
+	}
+	ctx := context.WithValue(r.Context(), ctxToken, cookie)
+	ctx = context.WithValue(ctx, ctxSession, sess)
+	ctx = withFreshness(ctx)
+
+	cal := &ics.Calendar{
+		ProdID:          icsProdID,
+		Name:            "i-Ma'luum",
+		RefreshInterval: feedRefreshInterval,
+	}
+
+	schedules, err := s.cachedSchedules(ctx)
+	if err != nil && err != errors.ErrScheduleIsEmpty {
+		logger.ErrorContext(ctx, "Failed to load schedule for feed", "error", err)
+		errors.Render(w, r, err)
+		return
+	}
+	if len(schedules) > 0 {
+		sem, ok, err := semesterFor(time.Now())
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to parse academic calendar data", "error", err)
+		} else if ok {
+			cal.Name = "i-Ma'luum " + schedules[0].SessionName
+			cal.Events = scheduleEvents(schedules[0], sem)
+		}
+	}
+
+	// The exam timetable is best-effort: most of the semester it is empty.
+	exams, err := cachedResource(ctx, s, finalExamResource, false, s.fetchFinalExams)
+	if err != nil && err != errors.ErrNoFinalExam {
+		logger.WarnContext(ctx, "Failed to load final exams for feed", "error", err)
+	}
+	cal.Events = append(cal.Events, examEvents(exams)...)
+
+	var buf bytes.Buffer
+	if err := cal.Encode(&buf); err != nil {
+		logger.ErrorContext(ctx, "Failed to encode calendar", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+		return
+	}
+
+	staleness(ctx, w)
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	_, _ = w.Write(buf.Bytes())
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gopaseto "aidanwoods.dev/go-paseto"
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/nrmnqdds/gomaluum/pkg/paseto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeedToken(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+
+	token, claims, err := s.generateFeedToken(ctx, "2110000", "p@ss")
+	require.NoError(t, err)
+
+	parsed, err := s.parseFeedToken(token)
+	require.NoError(t, err)
+	require.Equal(t, claims.id, parsed.id)
+	require.Equal(t, "2110000", parsed.username)
+	password, found, err := s.credentials.Password(ctx, parsed.credential, parsed.username)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "p@ss", password)
+
+	// The URL carries no password, even to someone holding the local key.
+	raw, err := gopaseto.NewParser().ParseV4Local(*s.paseto.LocalKey, token, nil)
+	require.NoError(t, err)
+	_, err = raw.GetString("password")
+	require.Error(t, err)
+
+	revoked, err := s.revocations.IsRevoked(ctx, parsed.id, parsed.username, parsed.issuedAt)
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	require.NoError(t, s.revocations.Revoke(ctx, parsed.id, parsed.username, parsed.expiresAt))
+	revoked, err = s.revocations.IsRevoked(ctx, parsed.id, parsed.username, parsed.issuedAt)
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	other := gopaseto.NewV4SymmetricKey()
+	s.paseto = &paseto.AppPaseto{LocalKey: &other}
+	_, err = s.parseFeedToken(token)
+	require.Error(t, err)
+}
+
+func TestFeedURLs(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/api/schedule/feed", nil)
+	r.Host = "api.example.com"
+	r.Header.Set("X-Forwarded-Proto", "https")
+
+	s := &Server{}
+	httpURL, webcalURL := s.feedURLs(r, "tok")
+	require.Equal(t, "http://api.example.com/api/feed/tok.ics", httpURL, "untrusted X-Forwarded-Proto is ignored")
+	require.Equal(t, "webcal://api.example.com/api/feed/tok.ics", webcalURL)
+
+	s.trustProxy = true
+	httpURL, _ = s.feedURLs(r, "tok")
+	require.Equal(t, "https://api.example.com/api/feed/tok.ics", httpURL)
+}
+
+func TestExamWindow(t *testing.T) {
+	tests := []struct {
+		date, time string
+		start, end string
+	}{
+		{"15-01-2026", "09:00 AM - 12:00 PM", "2026-01-15 09:00", "2026-01-15 12:00"},
+		{"15 Jan 2026", "2:30 - 5:30 PM", "2026-01-15 14:30", "2026-01-15 17:30"},
+		{"15/01/2026", "9.00 - 12.00 PM", "2026-01-15 09:00", "2026-01-15 12:00"},
+	}
+	for _, tt := range tests {
+		start, end, ok := examWindow(dtos.FinalExamItem{Date: tt.date, Time: tt.time})
+		require.True(t, ok, "%s %s", tt.date, tt.time)
+		require.Equal(t, tt.start, start.In(klTimezone).Format("2006-01-02 15:04"))
+		require.Equal(t, tt.end, end.In(klTimezone).Format("2006-01-02 15:04"))
+	}
+
+	_, _, ok := examWindow(dtos.FinalExamItem{Date: "TBA", Time: "TBA"})
+	require.False(t, ok)
+}
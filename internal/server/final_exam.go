@@ -1,19 +1,20 @@
 package server
 
 import (
-	"fmt"
+	"context"
 	"net/http"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/bytedance/sonic"
 	"github.com/gocolly/colly/v2"
-	"github.com/lucsky/cuid"
 	"github.com/nrmnqdds/gomaluum/internal/constants"
 	"github.com/nrmnqdds/gomaluum/internal/dtos"
 	"github.com/nrmnqdds/gomaluum/internal/errors"
+	"github.com/nrmnqdds/gomaluum/pkg/utils"
 )
 
 var finalExamItemPool = sync.Pool{
@@ -28,7 +29,9 @@ var finalExamTdPool = sync.Pool{
 	},
 }
 
-func parseFinalExamRow(tds []string, exams *[]dtos.FinalExamItem, mu *sync.Mutex) {
+// parseFinalExamRow appends the exam in tds to exams. session is the session
+// the timetable is for, as read from the page, or "" if it couldn't be.
+func parseFinalExamRow(session string, tds []string, exams *[]dtos.FinalExamItem, mu *sync.Mutex) {
 	if len(tds) < 7 {
 		return
 	}
@@ -42,9 +45,15 @@ func parseFinalExamRow(tds []string, exams *[]dtos.FinalExamItem, mu *sync.Mutex
 		return
 	}
 
+	// A course and section recur every session it is offered; without the
+	// session, the exam date at least keeps them apart.
+	if session == "" {
+		session = trimmed[3]
+	}
+
 	item := finalExamItemPool.Get().(*dtos.FinalExamItem)
 	*item = dtos.FinalExamItem{
-		ID:             fmt.Sprintf("gomaluum:exam:%s", cuid.Slug()),
+		ID:             utils.StableID("exam", session, trimmed[0], trimmed[2]),
 		SubjectCode:    trimmed[0],
 		SubjectName:    trimmed[1],
 		SubjectSection: trimmed[2],
@@ -61,32 +70,36 @@ func parseFinalExamRow(tds []string, exams *[]dtos.FinalExamItem, mu *sync.Mutex
 	finalExamItemPool.Put(item)
 }
 
-// @Title FinalExamHandler
-// @Description Get final exam timetable from i-Ma'luum
-// @Tags scraper
-// @Produce json
-// @Param x-gomaluum-key header string false "API key for additional security layer"
-// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
-// @Success 200 {object} dtos.ResponseDTO
-// @Failure 404 {object} errors.CustomError "No final exam timetable found"
-// @Router /api/exam-timetable [get]
-func (s *Server) FinalExamHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
+// fetchFinalExams scrapes the request user's final exam timetable. It
+// returns ErrNoFinalExam when i-Ma'luum lists no exams.
+func (s *Server) fetchFinalExams(ctx context.Context) ([]dtos.FinalExamItem, error) {
 	var (
-		logger = s.log
-		mu     sync.Mutex
-		exams  []dtos.FinalExamItem
+		mu      sync.Mutex
+		exams   []dtos.FinalExamItem
+		session string
 	)
 
-	if err := s.scrapeWithRetry(r.Context(), func(cookie string) (bool, error) {
+	if err := s.scrapeWithRetry(ctx, func(cookie string) (bool, error) {
 		// Reset accumulators so a retry starts clean.
 		mu.Lock()
 		exams = exams[:0]
+		session = ""
 		mu.Unlock()
 
 		var stale atomic.Bool
-		c := s.newImaluumCollector(r.Context(), cookie, &stale)
+		c := s.newImaluumCollector(ctx, cookie, &stale)
+
+		// Registered first, so the session is known before the rows are parsed.
+		c.OnHTML("script", func(e *colly.HTMLElement) {
+			content := strings.TrimSpace(e.Text)
+			if strings.Contains(content, "console.log") {
+				mu.Lock()
+				if session == "" {
+					session = extractSession(content)
+				}
+				mu.Unlock()
+			}
+		})
 
 		c.OnHTML("table.table.table-hover tbody tr", func(e *colly.HTMLElement) {
 			cells := e.DOM.Find("td")
@@ -101,7 +114,10 @@ func (s *Server) FinalExamHandler(w http.ResponseWriter, r *http.Request) {
 				tds = append(tds, s.Text())
 			})
 
-			parseFinalExamRow(tds, &exams, &mu)
+			mu.Lock()
+			examSession := session
+			mu.Unlock()
+			parseFinalExamRow(examSession, tds, &exams, &mu)
 
 			finalExamTdPool.Put(tds)
 		})
@@ -111,25 +127,141 @@ func (s *Server) FinalExamHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return stale.Load(), nil
 	}); err != nil {
-		logger.ErrorContext(r.Context(), "Failed to scrape final exam timetable", "error", err)
-		errors.Render(w, r, err)
-		return
+		return nil, err
 	}
 
 	if len(exams) == 0 {
-		logger.ErrorContext(r.Context(), "Final exam timetable is empty")
-		errors.Render(w, r, errors.ErrNoFinalExam)
+		return nil, errors.ErrNoFinalExam
+	}
+	return exams, nil
+}
+
+// Layouts seen (or plausible) in the exam timetable's date and time columns.
+var (
+	examDateLayouts = []string{
+		"02-01-2006",
+		"02/01/2006",
+		"2006-01-02",
+		"02.01.2006",
+		"02-Jan-2006",
+		"02 Jan 2006",
+		"2 Jan 2006",
+		"02 January 2006",
+		"2 January 2006",
+		"Monday, 02 January 2006",
+		"Monday, 2 January 2006",
+		"Mon, 02 Jan 2006",
+	}
+	examClockLayouts = []string{"3:04PM", "03:04PM", "3PM", "15:04", "1504"}
+)
+
+// examWindow parses an exam's Date and Time columns (e.g. "09:00 AM - 12:00 PM")
+// into Kuala Lumpur start and end times. ok is false when either column is in
+// a format we do not recognise.
+func examWindow(exam dtos.FinalExamItem) (start, end time.Time, ok bool) {
+	var date time.Time
+	dateText := strings.Join(strings.Fields(exam.Date), " ")
+	for _, layout := range examDateLayouts {
+		if d, err := time.ParseInLocation(layout, dateText, klTimezone); err == nil {
+			date = d
+			break
+		}
+	}
+	if date.IsZero() {
+		return time.Time{}, time.Time{}, false
+	}
+
+	timeText := strings.ToUpper(exam.Time)
+	parts := strings.SplitN(strings.ReplaceAll(timeText, " TO ", "-"), "-", 2)
+	if len(parts) != 2 {
+		return time.Time{}, time.Time{}, false
+	}
+	startText, endText := normalizeClock(parts[0]), normalizeClock(parts[1])
+
+	// "9:00 - 12:00 PM": the start inherits the end's meridiem.
+	if !hasMeridiem(startText) && hasMeridiem(endText) {
+		startText += endText[len(endText)-2:]
+		if s, ok := parseClock(startText); ok {
+			if e, ok := parseClock(endText); ok && s.After(e) {
+				startText = startText[:len(startText)-2] + "AM"
+			}
+		}
+	}
+
+	s, ok := parseClock(startText)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	e, ok := parseClock(endText)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+
+	at := func(clock time.Time) time.Time {
+		return time.Date(date.Year(), date.Month(), date.Day(), clock.Hour(), clock.Minute(), 0, 0, klTimezone)
+	}
+	start, end = at(s), at(e)
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, false
+	}
+	return start, end, true
+}
+
+// normalizeClock strips spaces and turns "9.00" into "9:00".
+func normalizeClock(s string) string {
+	s = strings.ReplaceAll(s, " ", "")
+	s = strings.ReplaceAll(s, ".", ":")
+	return strings.ReplaceAll(s, "\u00a0", "")
+}
+
+func hasMeridiem(s string) bool {
+	return strings.HasSuffix(s, "AM") || strings.HasSuffix(s, "PM")
+}
+
+func parseClock(s string) (time.Time, bool) {
+	for _, layout := range examClockLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// @Title FinalExamHandler
+// @Description Get final exam timetable from i-Ma'luum. Served from cache for up to 6 hours unless refresh is set.
+// @Tags scraper
+// @Produce json
+// @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Param refresh query bool false "Bypass the cache and re-scrape"
+// @Success 200 {object} dtos.ResponseDTO
+// @Failure 404 {object} errors.CustomError "No final exam timetable found"
+// @Router /api/exam-timetable [get]
+func (s *Server) FinalExamHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	logger := s.log
+
+	exams, err := cachedResource(r.Context(), s, finalExamResource, r.URL.Query().Has("refresh"), s.fetchFinalExams)
+	if err != nil {
+		if err == errors.ErrNoFinalExam {
+			logger.ErrorContext(r.Context(), "Final exam timetable is empty")
+		} else {
+			logger.ErrorContext(r.Context(), "Failed to scrape final exam timetable", "error", err)
+		}
+		errors.Render(w, r, err)
 		return
 	}
 
 	finalExam := &dtos.FinalExam{
-		ID:    fmt.Sprintf("gomaluum:final-exam:%s", cuid.Slug()),
+		ID:    utils.StableID("final-exam", requestUsername(r.Context())),
 		Exams: exams,
 	}
 
 	response := &dtos.ResponseDTO{
 		Message: "Successfully fetched final exam timetable",
 		Data:    finalExam,
+		Stale:   staleness(r.Context(), w),
 	}
 
 	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
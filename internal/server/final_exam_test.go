@@ -0,0 +1,29 @@
+package server
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFinalExamRowStableIDs(t *testing.T) {
+	row := []string{"INFO4335", "Software Engineering", "1", "15-01-2026", "9:00 AM - 12:00 PM", "KAED", "42"}
+	parse := func(session string, row ...string) dtos.FinalExamItem {
+		var (
+			mu    sync.Mutex
+			exams []dtos.FinalExamItem
+		)
+		parseFinalExamRow(session, row, &exams, &mu)
+		require.Len(t, exams, 1)
+		return exams[0]
+	}
+
+	first := parse("Semester 1, 2025/2026", row...)
+	require.Equal(t, first.ID, parse("Semester 1, 2025/2026", row...).ID)
+	require.NotEqual(t, first.ID, parse("Semester 1, 2026/2027", row...).ID, "the same course and section next session is another exam")
+
+	// Without the session, the date stands in for it.
+	require.NotEqual(t, parse("", row...).ID, parse("", "INFO4335", "Software Engineering", "1", "14-01-2027", "9:00 AM - 12:00 PM", "KAED", "42").ID)
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// maxGroupMembers bounds how many timetables one request may load,
+	// including the caller's.
+	maxGroupMembers = 10
+
+	defaultFreeFrom       = "0800"
+	defaultFreeTo         = "1800"
+	defaultFreeMinMinutes = 30
+)
+
+var defaultFreeDays = []uint8{1, 2, 3, 4, 5} // Monday to Friday
+
+func minutesClock(m int) string {
+	return fmt.Sprintf("%02d%02d", m/60, m%60)
+}
+
+// freeSlots intersects the given sessions' weekly timetables and returns, for
+// each of days, the windows between from and to ("HHMM") in which nobody has
+// a class and that last at least minMinutes. Slots with a malformed time are
+// treated as not blocking anything.
+func freeSlots(sessions []dtos.ScheduleResponse, days []uint8, from, to, minMinutes int) []dtos.FreeDay {
+	type busy struct{ start, end int }
+	busyByDay := make(map[uint8][]busy)
+	for _, session := range sessions {
+		for _, subject := range session.Schedule {
+			for _, slot := range subject.Timestamps {
+				start, ok := clockMinutes(slot.Start)
+				if !ok {
+					continue
+				}
+				end, ok := clockMinutes(slot.End)
+				if !ok || end <= start {
+					continue
+				}
+				busyByDay[slot.Day] = append(busyByDay[slot.Day], busy{start, end})
+			}
+		}
+	}
+
+	result := make([]dtos.FreeDay, 0, len(days))
+	for _, day := range days {
+		blocks := busyByDay[day]
+		slices.SortFunc(blocks, func(a, b busy) int { return a.start - b.start })
+
+		free := []dtos.FreeSlot{}
+		emit := func(start, end int) {
+			if end-start >= minMinutes {
+				free = append(free, dtos.FreeSlot{Start: minutesClock(start), End: minutesClock(end), Minutes: end - start})
+			}
+		}
+
+		cursor := from
+		for _, b := range blocks {
+			if b.end <= cursor {
+				continue
+			}
+			if b.start >= to {
+				break
+			}
+			emit(cursor, min(b.start, to))
+			cursor = max(cursor, b.end)
+		}
+		if cursor < to {
+			emit(cursor, to)
+		}
+
+		result = append(result, dtos.FreeDay{Day: day, Slots: free})
+	}
+	return result
+}
+
+// memberSchedule loads the latest session of the member authenticated by
+// token, through the schedule cache. Each lookup counts against the member's
+// own rate limit, as if they had made the request, before it may log them in.
+func (s *Server) memberSchedule(ctx context.Context, member dtos.FreeSlotsMember) (dtos.ScheduleResponse, error) {
+	use, err := s.lookupAPIKey(ctx, member.APIKey)
+	if err != nil {
+		return dtos.ScheduleResponse{}, errors.ErrInvalidGroupMemberToken
+	}
+
+	verified, err := s.verifyPasetoToken(ctx, member.Token, use.key)
+	if err != nil {
+		return dtos.ScheduleResponse{}, errors.ErrInvalidGroupMemberToken
+	}
+	if _, res := s.allow(apiKeySubject(use, verified.username), use.tier); !res.Allowed {
+		if res.Quota {
+			return dtos.ScheduleResponse{}, errors.ErrQuotaExceeded
+		}
+		return dtos.ScheduleResponse{}, errors.ErrRateLimited
+	}
+
+	sess, err := s.tokenSession(ctx, verified)
+	if err != nil || sess == nil || !sess.hasScopes(scopeScheduleRead) {
+		return dtos.ScheduleResponse{}, errors.ErrInvalidGroupMemberToken
+	}
+
+	ctx = context.WithValue(ctx, ctxToken, sess.imaluumCookie)
+	ctx = context.WithValue(ctx, ctxSession, sess)
+
+	schedules, err := s.cachedSchedules(ctx)
+	if err != nil {
+		return dtos.ScheduleResponse{}, err
+	}
+	return schedules[0], nil
+}
+
+// @Title FreeSlotsHandler
+// @Description Find common free windows across a group's latest-session timetables. Pass the gomaluum tokens (and API keys, if used) of the other consenting members; the caller is always included. Schedules are served from the schedule cache when possible.
+// @Tags scraper
+// @Accept json
+// @Produce json
+// @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Param request body dtos.FreeSlotsRequest true "Group members and search window (defaults: 0800-1800, Monday to Friday, 30 minutes)"
+// @Success 200 {object} dtos.ResponseDTO{data=dtos.FreeSlots}
+// @Failure 400 {object} errors.CustomError "Invalid request"
+// @Failure 401 {object} errors.CustomError "Invalid member token"
+// @Router /api/schedule/free-slots [post]
+func (s *Server) FreeSlotsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	logger := s.log
+
+	var req dtos.FreeSlotsRequest
+	if err := sonic.ConfigFastest.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.ErrorContext(r.Context(), "Failed to decode request body", "error", err)
+		errors.Render(w, r, errors.ErrInvalidRequest)
+		return
+	}
+
+	req.From = cmp.Or(req.From, defaultFreeFrom)
+	req.To = cmp.Or(req.To, defaultFreeTo)
+	if len(req.Days) == 0 {
+		req.Days = defaultFreeDays
+	}
+	if req.MinMinutes <= 0 {
+		req.MinMinutes = defaultFreeMinMinutes
+	}
+
+	from, fromOK := clockMinutes(req.From)
+	to, toOK := clockMinutes(req.To)
+	if !fromOK || !toOK || from >= to || len(req.Members)+1 > maxGroupMembers ||
+		slices.ContainsFunc(req.Days, func(d uint8) bool { return d > uint8(time.Saturday) }) {
+		errors.Render(w, r, errors.ErrInvalidFreeSlotsRequest)
+		return
+	}
+
+	sessions := make([]dtos.ScheduleResponse, len(req.Members)+1)
+
+	g, ctx := errgroup.WithContext(r.Context())
+	g.Go(func() error {
+		schedules, err := s.cachedSchedules(ctx)
+		if err != nil {
+			return err
+		}
+		sessions[0] = schedules[0]
+		return nil
+	})
+	for i, member := range req.Members {
+		g.Go(func() error {
+			session, err := s.memberSchedule(ctx, member)
+			if err != nil {
+				return err
+			}
+			sessions[i+1] = session
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		logger.ErrorContext(r.Context(), "Failed to load group schedules", "error", err)
+		errors.Render(w, r, err)
+		return
+	}
+
+	response := &dtos.ResponseDTO{
+		Message: "Successfully found common free slots",
+		Data: &dtos.FreeSlots{
+			From:    req.From,
+			To:      req.To,
+			Members: len(sessions),
+			Days:    freeSlots(sessions, req.Days, from, to, req.MinMinutes),
+		},
+		Stale: staleness(r.Context(), w),
+	}
+
+	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
+		logger.ErrorContext(r.Context(), "Failed to encode response", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreeSlots(t *testing.T) {
+	alice := dtos.ScheduleResponse{Schedule: []dtos.ScheduleSubject{
+		{Timestamps: []dtos.WeekTime{
+			{Start: "0830", End: "0950", Day: 1},
+			{Start: "1400", End: "1520", Day: 1},
+		}},
+	}}
+	bob := dtos.ScheduleResponse{Schedule: []dtos.ScheduleSubject{
+		{Timestamps: []dtos.WeekTime{
+			{Start: "0900", End: "1120", Day: 1}, // overlaps alice's morning class
+			{Start: "1200", End: "1220", Day: 1},
+			{Start: "1000", End: "1100", Day: 2},
+			{Start: "bad", End: "1100", Day: 2},
+		}},
+	}}
+
+	from, _ := clockMinutes("0800")
+	to, _ := clockMinutes("1800")
+	days := freeSlots([]dtos.ScheduleResponse{alice, bob}, []uint8{1, 2, 3}, from, to, 30)
+	require.Len(t, days, 3)
+
+	require.Equal(t, uint8(1), days[0].Day)
+	require.Equal(t, []dtos.FreeSlot{
+		{Start: "0800", End: "0830", Minutes: 30},
+		{Start: "1120", End: "1200", Minutes: 40},
+		{Start: "1220", End: "1400", Minutes: 100},
+		{Start: "1520", End: "1800", Minutes: 160},
+	}, days[0].Slots)
+
+	require.Equal(t, []dtos.FreeSlot{
+		{Start: "0800", End: "1000", Minutes: 120},
+		{Start: "1100", End: "1800", Minutes: 420},
+	}, days[1].Slots)
+
+	require.Equal(t, []dtos.FreeSlot{{Start: "0800", End: "1800", Minutes: 600}}, days[2].Slots)
+
+	// Windows shorter than the minimum are dropped.
+	days = freeSlots([]dtos.ScheduleResponse{alice, bob}, []uint8{1}, from, to, 45)
+	require.Len(t, days[0].Slots, 2)
+}
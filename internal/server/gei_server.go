@@ -0,0 +1,347 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/bytedance/sonic"
+	gei "github.com/nrmnqdds/gomaluum/internal/proto/gei"
+	"github.com/nrmnqdds/gomaluum/pkg/vault"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// geiScheduleResource is the resource kind schedules are stored under.
+// StoreResource rejects empty kinds, so it can't collide with a resource.
+const geiScheduleResource = ""
+
+// embeddedGEI serves GEI's ScheduleIndexer from gomaluum itself, so
+// self-hosters get the schedule cache without deploying GEI. Like GEI it
+// encrypts payloads at rest; it also only answers calls, reads included,
+// carrying the admin key, since a read returns a student's decrypted records.
+// scheduleIndexer talks to it exactly as it would to GEI.
+type embeddedGEI struct {
+	gei.UnimplementedScheduleIndexerServer
+
+	vault    *vault.Vault
+	store    geiRecordStore
+	adminKey string
+}
+
+// geiRecordStore persists sealed payloads by username and resource kind.
+type geiRecordStore interface {
+	Put(ctx context.Context, username, resource string, sealed vault.Sealed) error
+	Get(ctx context.Context, username, resource string) (sealed vault.Sealed, found bool, err error)
+}
+
+func newEmbeddedGEI(masterKey []byte, adminKey string, store geiRecordStore) (*embeddedGEI, error) {
+	v, err := vault.New(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return &embeddedGEI{vault: v, store: store, adminKey: adminKey}, nil
+}
+
+// serveEmbeddedGEI starts the embedded GEI on GEI_EMBEDDED_ADDR and returns it
+// with the address and transport credentials to dial it with. It returns a nil
+// server when GEI_EMBEDDED_ADDR is unset. Records go to files in
+// GEI_EMBEDDED_DIR when set, otherwise to Postgres; they are encrypted with the
+// required GEI_MASTER_KEY (hex).
+//
+// It serves plaintext only on loopback. Any other address needs
+// GEI_EMBEDDED_TLS_* (see grpcServerCredentials), and is then dialed with the
+// GEI_TLS_* client settings.
+func serveEmbeddedGEI(db *sql.DB) (*grpc.Server, string, credentials.TransportCredentials, error) {
+	addr := os.Getenv("GEI_EMBEDDED_ADDR")
+	if addr == "" {
+		return nil, "", nil, nil
+	}
+
+	adminKey := os.Getenv("GEI_ADMIN_KEY")
+	if adminKey == "" {
+		return nil, "", nil, fmt.Errorf("GEI_ADMIN_KEY is required to serve GEI")
+	}
+
+	serverCreds, secure, err := grpcServerCredentials("GEI_EMBEDDED")
+	if err != nil {
+		return nil, "", nil, err
+	}
+	dialCreds := insecure.NewCredentials()
+	if secure {
+		if dialCreds, err = grpcTransportCredentials("GEI"); err != nil {
+			return nil, "", nil, err
+		}
+		if dialCreds.Info().SecurityProtocol != "tls" {
+			return nil, "", nil, fmt.Errorf("GEI_TLS must be true to dial the embedded GEI over TLS")
+		}
+	} else if !isLoopbackAddr(addr) {
+		return nil, "", nil, fmt.Errorf("GEI_EMBEDDED_ADDR %s is not loopback; set GEI_EMBEDDED_TLS_CERT_FILE and GEI_EMBEDDED_TLS_KEY_FILE to serve it over TLS", addr)
+	}
+
+	var store geiRecordStore
+	switch dir := os.Getenv("GEI_EMBEDDED_DIR"); {
+	case dir != "":
+		fileStore, err := newFileGEIStore(dir)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		store = fileStore
+	case db != nil:
+		store = &postgresGEIStore{db: db}
+	default:
+		return nil, "", nil, fmt.Errorf("GEI_EMBEDDED_DIR or DATABASE_URL is required to serve GEI")
+	}
+
+	key, err := masterKey("GEI_MASTER_KEY")
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to read GEI_MASTER_KEY: %w", err)
+	}
+	svc, err := newEmbeddedGEI(key, adminKey, store)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	srv := grpc.NewServer(
+		grpc.Creds(serverCreds),
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+	)
+	gei.RegisterScheduleIndexerServer(srv, svc)
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			log.Printf("Embedded GEI stopped: %v", err)
+		}
+	}()
+
+	// A wildcard listener is dialed over loopback.
+	dial := lis.Addr().(*net.TCPAddr)
+	host := dial.IP.String()
+	if dial.IP.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	return srv, net.JoinHostPort(host, fmt.Sprint(dial.Port)), dialCreds, nil
+}
+
+// isLoopbackAddr reports whether the listen address addr only accepts local
+// connections. Wildcard addresses (":50053", "0.0.0.0:50053") do not.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// authorize checks the admin-key metadata every call must carry.
+func (g *embeddedGEI) authorize(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	keys := md.Get("admin-key")
+	if len(keys) != 1 || subtle.ConstantTimeCompare([]byte(keys[0]), []byte(g.adminKey)) != 1 {
+		return status.Error(codes.PermissionDenied, "invalid admin key")
+	}
+	return nil
+}
+
+// geiAAD binds a sealed payload to its owner and resource kind.
+func geiAAD(username, resource string) []byte {
+	return []byte(username + "\x00" + resource)
+}
+
+func (g *embeddedGEI) put(ctx context.Context, username, resource, payload string) error {
+	if username == "" {
+		return status.Error(codes.InvalidArgument, "username is required")
+	}
+	sealed, err := g.vault.Seal([]byte(payload), geiAAD(username, resource))
+	if err != nil {
+		return status.Error(codes.Internal, "failed to encrypt payload")
+	}
+	if err := g.store.Put(ctx, username, resource, sealed); err != nil {
+		log.Printf("Embedded GEI failed to store %q for %s: %v", resource, username, err)
+		return status.Error(codes.Internal, "failed to store payload")
+	}
+	return nil
+}
+
+func (g *embeddedGEI) get(ctx context.Context, username, resource string) (payload string, found bool, err error) {
+	if username == "" {
+		return "", false, status.Error(codes.InvalidArgument, "username is required")
+	}
+	sealed, found, err := g.store.Get(ctx, username, resource)
+	if err != nil {
+		log.Printf("Embedded GEI failed to load %q for %s: %v", resource, username, err)
+		return "", false, status.Error(codes.Internal, "failed to load payload")
+	}
+	if !found {
+		return "", false, nil
+	}
+	plaintext, err := g.vault.Open(sealed, geiAAD(username, resource))
+	if err != nil {
+		return "", false, status.Error(codes.Internal, "failed to decrypt payload")
+	}
+	return string(plaintext), true, nil
+}
+
+func (g *embeddedGEI) StoreSchedule(ctx context.Context, req *gei.StoreScheduleRequest) (*gei.StoreScheduleResponse, error) {
+	if err := g.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if err := g.put(ctx, req.GetUsername(), geiScheduleResource, req.GetScheduleJson()); err != nil {
+		return nil, err
+	}
+	return &gei.StoreScheduleResponse{Success: true, Message: "Schedule stored"}, nil
+}
+
+func (g *embeddedGEI) GetSchedule(ctx context.Context, req *gei.GetScheduleRequest) (*gei.GetScheduleResponse, error) {
+	if err := g.authorize(ctx); err != nil {
+		return nil, err
+	}
+	payload, found, err := g.get(ctx, req.GetUsername(), geiScheduleResource)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return &gei.GetScheduleResponse{Message: "Schedule not found"}, nil
+	}
+	return &gei.GetScheduleResponse{Success: true, ScheduleJson: payload}, nil
+}
+
+func (g *embeddedGEI) StoreResource(ctx context.Context, req *gei.StoreResourceRequest) (*gei.StoreResourceResponse, error) {
+	if err := g.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if req.GetResource() == "" {
+		return nil, status.Error(codes.InvalidArgument, "resource is required")
+	}
+	if err := g.put(ctx, req.GetUsername(), req.GetResource(), req.GetPayloadJson()); err != nil {
+		return nil, err
+	}
+	return &gei.StoreResourceResponse{Success: true, Message: "Resource stored"}, nil
+}
+
+func (g *embeddedGEI) GetResource(ctx context.Context, req *gei.GetResourceRequest) (*gei.GetResourceResponse, error) {
+	if err := g.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if req.GetResource() == "" {
+		return nil, status.Error(codes.InvalidArgument, "resource is required")
+	}
+	payload, found, err := g.get(ctx, req.GetUsername(), req.GetResource())
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return &gei.GetResourceResponse{Message: "Resource not found"}, nil
+	}
+	return &gei.GetResourceResponse{Success: true, PayloadJson: payload}, nil
+}
+
+type postgresGEIStore struct {
+	db *sql.DB
+}
+
+func (p *postgresGEIStore) Put(ctx context.Context, username, resource string, sealed vault.Sealed) error {
+	_, err := p.db.ExecContext(ctx, `
+			INSERT INTO gei_records (username, resource, wrapped_key, ciphertext, updated_at)
+			VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+			ON CONFLICT (username, resource) DO UPDATE
+			SET wrapped_key = EXCLUDED.wrapped_key, ciphertext = EXCLUDED.ciphertext, updated_at = EXCLUDED.updated_at
+		`, username, resource, sealed.WrappedKey, sealed.Ciphertext)
+	return err
+}
+
+func (p *postgresGEIStore) Get(ctx context.Context, username, resource string) (vault.Sealed, bool, error) {
+	var sealed vault.Sealed
+	err := p.db.QueryRowContext(ctx, `
+			SELECT wrapped_key, ciphertext FROM gei_records
+			WHERE username = $1 AND resource = $2
+		`, username, resource).Scan(&sealed.WrappedKey, &sealed.Ciphertext)
+	if errors.Is(err, sql.ErrNoRows) {
+		return vault.Sealed{}, false, nil
+	}
+	if err != nil {
+		return vault.Sealed{}, false, err
+	}
+	return sealed, true, nil
+}
+
+// fileGEIStore keeps one file per record in dir, named by a hash of the
+// username and resource kind so file names don't reveal whose they are.
+type fileGEIStore struct {
+	dir string
+}
+
+type geiFileRecord struct {
+	WrappedKey []byte `json:"wrapped_key"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func newFileGEIStore(dir string) (*fileGEIStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create GEI_EMBEDDED_DIR: %w", err)
+	}
+	return &fileGEIStore{dir: dir}, nil
+}
+
+func (f *fileGEIStore) path(username, resource string) string {
+	sum := sha256.Sum256(geiAAD(username, resource))
+	return filepath.Join(f.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Put writes the record to a temporary file and renames it into place, so a
+// crash never leaves a partial record behind.
+func (f *fileGEIStore) Put(_ context.Context, username, resource string, sealed vault.Sealed) error {
+	data, err := sonic.ConfigFastest.Marshal(geiFileRecord{WrappedKey: sealed.WrappedKey, Ciphertext: sealed.Ciphertext})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(f.dir, ".record-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path(username, resource))
+}
+
+func (f *fileGEIStore) Get(_ context.Context, username, resource string) (vault.Sealed, bool, error) {
+	data, err := os.ReadFile(f.path(username, resource))
+	if errors.Is(err, fs.ErrNotExist) {
+		return vault.Sealed{}, false, nil
+	}
+	if err != nil {
+		return vault.Sealed{}, false, err
+	}
+	var record geiFileRecord
+	if err := sonic.ConfigFastest.Unmarshal(data, &record); err != nil {
+		return vault.Sealed{}, false, err
+	}
+	return vault.Sealed{WrappedKey: record.WrappedKey, Ciphertext: record.Ciphertext}, true, nil
+}
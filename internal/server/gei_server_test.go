@@ -0,0 +1,169 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/nrmnqdds/gomaluum/pkg/vault"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestEmbeddedGEI(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GEI_EMBEDDED_ADDR", "127.0.0.1:0")
+	t.Setenv("GEI_EMBEDDED_DIR", dir)
+	t.Setenv("GEI_ADMIN_KEY", "admin")
+	t.Setenv("GEI_MASTER_KEY", strings.Repeat("ab", vault.KeySize))
+
+	srv, addr, creds, err := serveEmbeddedGEI(nil)
+	require.NoError(t, err)
+	t.Cleanup(srv.Stop)
+
+	// The existing client against the embedded server.
+	connect := func(adminKey string) *scheduleIndexer {
+		idx, err := newScheduleIndexer(addr, adminKey, creds)
+		require.NoError(t, err)
+		t.Cleanup(func() { idx.Close() })
+		return idx
+	}
+	idx := connect("admin")
+
+	_, found, err := idx.GetSchedule(t.Context(), "2110001")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	schedules := []dtos.ScheduleResponse{{ID: "1", SessionName: "Semester 1, 2024/2025", SessionQuery: "?ses=2024/2025&sem=1"}}
+	require.NoError(t, idx.StoreSchedule(t.Context(), "2110001", schedules))
+	cached, found, err := idx.GetSchedule(t.Context(), "2110001")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, schedules, cached)
+
+	require.NoError(t, idx.StoreResource(t.Context(), "2110001", resultsResource, map[string]string{"cgpa": "3.90"}))
+	var results map[string]string
+	found, err = idx.GetResource(t.Context(), "2110001", resultsResource, &results)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "3.90", results["cgpa"])
+	found, err = idx.GetResource(t.Context(), "2110001", profileResource, &results)
+	require.NoError(t, err)
+	require.False(t, found, "resources don't leak into each other")
+
+	// Payloads are encrypted at rest.
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		require.NotContains(t, string(data), "2024/2025")
+		require.NotContains(t, string(data), "3.90")
+	}
+
+	// Writes and reads need the admin key.
+	err = connect("wrong").StoreSchedule(t.Context(), "2110001", nil)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	err = connect("").StoreResource(t.Context(), "2110001", resultsResource, nil)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, _, err = connect("wrong").GetSchedule(t.Context(), "2110001")
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = connect("").GetResource(t.Context(), "2110001", resultsResource, &results)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	cached, _, err = idx.GetSchedule(t.Context(), "2110001")
+	require.NoError(t, err)
+	require.Equal(t, schedules, cached)
+
+	// Records survive a restart with the same key, and are unreadable with
+	// another.
+	store, err := newFileGEIStore(dir)
+	require.NoError(t, err)
+	key, err := masterKey("GEI_MASTER_KEY")
+	require.NoError(t, err)
+	restarted, err := newEmbeddedGEI(key, "admin", store)
+	require.NoError(t, err)
+	_, found, err = restarted.get(t.Context(), "2110001", resultsResource)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	key[0] ^= 1
+	rekeyed, err := newEmbeddedGEI(key, "admin", store)
+	require.NoError(t, err)
+	_, _, err = rekeyed.get(t.Context(), "2110001", resultsResource)
+	require.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestServeEmbeddedGEIConfig(t *testing.T) {
+	t.Setenv("GEI_EMBEDDED_ADDR", "")
+	srv, _, _, err := serveEmbeddedGEI(nil)
+	require.NoError(t, err)
+	require.Nil(t, srv, "not embedded unless asked for")
+
+	t.Setenv("GEI_EMBEDDED_ADDR", "127.0.0.1:0")
+	t.Setenv("GEI_EMBEDDED_DIR", t.TempDir())
+	t.Setenv("GEI_MASTER_KEY", strings.Repeat("ab", vault.KeySize))
+	t.Setenv("GEI_ADMIN_KEY", "")
+	_, _, _, err = serveEmbeddedGEI(nil)
+	require.Error(t, err, "calls must be guarded")
+
+	t.Setenv("GEI_ADMIN_KEY", "admin")
+	t.Setenv("GEI_MASTER_KEY", "")
+	_, _, _, err = serveEmbeddedGEI(nil)
+	require.Error(t, err, "the master key is not derived from anything")
+
+	t.Setenv("GEI_MASTER_KEY", strings.Repeat("ab", vault.KeySize))
+	t.Setenv("GEI_EMBEDDED_DIR", "")
+	_, _, _, err = serveEmbeddedGEI(nil)
+	require.Error(t, err, "a store is required")
+
+	t.Setenv("GEI_EMBEDDED_DIR", t.TempDir())
+	for _, addr := range []string{":0", "0.0.0.0:0", "[::]:0", "192.0.2.1:0"} {
+		t.Setenv("GEI_EMBEDDED_ADDR", addr)
+		_, _, _, err = serveEmbeddedGEI(nil)
+		require.Error(t, err, "%s is not loopback, so plaintext is refused", addr)
+	}
+}
+
+func TestServeEmbeddedGEIOverTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caFile, _ := testCert(t, dir, "test-ca", nil, true)
+	_, certFile, keyFile := testCert(t, dir, "gei.internal", &ca, false)
+
+	t.Setenv("GEI_EMBEDDED_ADDR", "0.0.0.0:0")
+	t.Setenv("GEI_EMBEDDED_DIR", t.TempDir())
+	t.Setenv("GEI_ADMIN_KEY", "admin")
+	t.Setenv("GEI_MASTER_KEY", strings.Repeat("ab", vault.KeySize))
+	t.Setenv("GEI_EMBEDDED_TLS_CERT_FILE", certFile)
+	t.Setenv("GEI_EMBEDDED_TLS_KEY_FILE", keyFile)
+
+	_, _, _, err := serveEmbeddedGEI(nil)
+	require.Error(t, err, "the embedded GEI must be dialed over TLS too")
+
+	t.Setenv("GEI_TLS", "true")
+	t.Setenv("GEI_TLS_CA_FILE", caFile)
+	t.Setenv("GEI_TLS_SERVER_NAME", "gei.internal")
+	srv, addr, creds, err := serveEmbeddedGEI(nil)
+	require.NoError(t, err)
+	t.Cleanup(srv.Stop)
+
+	idx, err := newScheduleIndexer(addr, "admin", creds)
+	require.NoError(t, err)
+	t.Cleanup(func() { idx.Close() })
+	schedules := []dtos.ScheduleResponse{{ID: "1", SessionName: "Semester 1, 2024/2025"}}
+	require.NoError(t, idx.StoreSchedule(t.Context(), "2110001", schedules))
+	cached, found, err := idx.GetSchedule(t.Context(), "2110001")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, schedules, cached)
+
+	plain, err := newScheduleIndexer(addr, "admin", insecure.NewCredentials())
+	require.NoError(t, err)
+	t.Cleanup(func() { plain.Close() })
+	_, _, err = plain.GetSchedule(t.Context(), "2110001")
+	require.Error(t, err, "plaintext clients are refused")
+}
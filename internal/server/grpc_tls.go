@@ -0,0 +1,118 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strconv"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// grpcTransportCredentials returns the transport credentials for dialing the
+// gRPC service configured under prefix ("GAS" or "GEI"):
+//
+//	<prefix>_TLS              true to dial over TLS; plaintext otherwise
+//	<prefix>_TLS_CA_FILE      PEM CA bundle to verify the server with, instead of the system roots
+//	<prefix>_TLS_CERT_FILE    PEM client certificate for mTLS, with <prefix>_TLS_KEY_FILE
+//	<prefix>_TLS_KEY_FILE     PEM private key of the client certificate
+//	<prefix>_TLS_SERVER_NAME  name to verify the server certificate against, if not the dialed host
+//
+// Any setting that can't be honoured is an error, so a service meant to be
+// reached over TLS is never silently reached in plaintext.
+func grpcTransportCredentials(prefix string) (credentials.TransportCredentials, error) {
+	env := func(name string) string { return os.Getenv(prefix + "_TLS" + name) }
+	caFile, certFile, keyFile, serverName := env("_CA_FILE"), env("_CERT_FILE"), env("_KEY_FILE"), env("_SERVER_NAME")
+
+	enabled := false
+	if raw := env(""); raw != "" {
+		var err error
+		if enabled, err = strconv.ParseBool(raw); err != nil {
+			return nil, fmt.Errorf("%s_TLS must be true or false, got %q", prefix, raw)
+		}
+	}
+	if !enabled {
+		if caFile != "" || certFile != "" || keyFile != "" || serverName != "" {
+			return nil, fmt.Errorf("%s_TLS_* settings are set but %s_TLS is not true", prefix, prefix)
+		}
+		return insecure.NewCredentials(), nil
+	}
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s_TLS_CA_FILE: %w", prefix, err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s_TLS_CA_FILE %s contains no PEM certificates", prefix, caFile)
+		}
+	}
+
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("%s_TLS_CERT_FILE and %s_TLS_KEY_FILE must be set together for mTLS", prefix, prefix)
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s client certificate: %w", prefix, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return credentials.NewTLS(config), nil
+}
+
+// grpcServerCredentials returns the transport credentials for serving gRPC
+// under prefix ("GEI_EMBEDDED"), and whether they are TLS:
+//
+//	<prefix>_TLS_CERT_FILE       PEM server certificate, with <prefix>_TLS_KEY_FILE; plaintext when unset
+//	<prefix>_TLS_KEY_FILE        PEM private key of the server certificate
+//	<prefix>_TLS_CLIENT_CA_FILE  PEM CA bundle clients must present a certificate from (mTLS)
+//
+// As with grpcTransportCredentials, a setting that can't be honoured is an
+// error.
+func grpcServerCredentials(prefix string) (creds credentials.TransportCredentials, secure bool, err error) {
+	env := func(name string) string { return os.Getenv(prefix + "_TLS" + name) }
+	certFile, keyFile, clientCAFile := env("_CERT_FILE"), env("_KEY_FILE"), env("_CLIENT_CA_FILE")
+
+	if (certFile == "") != (keyFile == "") {
+		return nil, false, fmt.Errorf("%s_TLS_CERT_FILE and %s_TLS_KEY_FILE must be set together", prefix, prefix)
+	}
+	if certFile == "" {
+		if clientCAFile != "" {
+			return nil, false, fmt.Errorf("%s_TLS_CLIENT_CA_FILE is set but %s_TLS_CERT_FILE is not", prefix, prefix)
+		}
+		return insecure.NewCredentials(), false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to load %s server certificate: %w", prefix, err)
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read %s_TLS_CLIENT_CA_FILE: %w", prefix, err)
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, false, fmt.Errorf("%s_TLS_CLIENT_CA_FILE %s contains no PEM certificates", prefix, clientCAFile)
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return credentials.NewTLS(config), true, nil
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// testCert issues a certificate for name signed by parent (self-signed when
+// parent is nil) and writes it and its key as PEM files in dir.
+func testCert(t *testing.T, dir, name string, parent *tls.Certificate, isCA bool) (tls.Certificate, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := tmpl, any(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, certFile, keyFile
+}
+
+func TestGRPCTransportCredentials(t *testing.T) {
+	dir := t.TempDir()
+	ca, caFile, _ := testCert(t, dir, "test-ca", nil, true)
+	serverCert, _, _ := testCert(t, dir, "gas.internal", &ca, false)
+	_, clientCertFile, clientKeyFile := testCert(t, dir, "gomaluum", &ca, false)
+	notPEM := filepath.Join(dir, "not-pem.crt")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o600))
+
+	creds, err := grpcTransportCredentials("TEST")
+	require.NoError(t, err)
+	require.Equal(t, "insecure", creds.Info().SecurityProtocol, "plaintext stays the default")
+
+	for name, env := range map[string]map[string]string{
+		"settings without TLS": {"TEST_TLS_CA_FILE": caFile},
+		"invalid switch":       {"TEST_TLS": "yes please"},
+		"missing CA":           {"TEST_TLS": "true", "TEST_TLS_CA_FILE": filepath.Join(dir, "missing.crt")},
+		"CA without PEM":       {"TEST_TLS": "true", "TEST_TLS_CA_FILE": notPEM},
+		"cert without key":     {"TEST_TLS": "true", "TEST_TLS_CERT_FILE": clientCertFile},
+		"mismatched key pair":  {"TEST_TLS": "true", "TEST_TLS_CERT_FILE": clientCertFile, "TEST_TLS_KEY_FILE": caFile},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range env {
+				t.Setenv(k, v)
+			}
+			_, err := grpcTransportCredentials("TEST")
+			require.Error(t, err)
+		})
+	}
+
+	// An mTLS server with a private CA, reached by IP but verified by name.
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	check := func() error {
+		creds, err := grpcTransportCredentials("TEST")
+		require.NoError(t, err)
+		conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(creds))
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = healthpb.NewHealthClient(conn).Check(t.Context(), &healthpb.HealthCheckRequest{})
+		return err
+	}
+
+	t.Setenv("TEST_TLS", "true")
+	t.Setenv("TEST_TLS_CA_FILE", caFile)
+	t.Setenv("TEST_TLS_SERVER_NAME", "gas.internal")
+	require.Error(t, check(), "the server requires a client certificate")
+
+	t.Setenv("TEST_TLS_CERT_FILE", clientCertFile)
+	t.Setenv("TEST_TLS_KEY_FILE", clientKeyFile)
+	require.NoError(t, check())
+
+	t.Setenv("TEST_TLS_SERVER_NAME", "gei.internal")
+	require.Error(t, check(), "the server certificate must match the server name")
+}
@@ -2,12 +2,14 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"os"
 	"time"
 
 	"github.com/alexliesenfeld/health"
 	"github.com/cloudflare/cloudflare-go"
+	"github.com/nrmnqdds/gomaluum/pkg/breaker"
 )
 
 // @Title HealthHandler
@@ -55,6 +57,51 @@ func (s *Server) HealthHandler() http.HandlerFunc {
 			},
 		}),
 
+		// Logins fail fast while the auth service's circuit is open; the
+		// breaker's state is also reported under info.
+		health.WithCheck(health.Check{
+			Name: "auth-service",
+			Check: func(_ context.Context) error {
+				if s.authBreaker == nil {
+					return nil
+				}
+				if snap := s.authBreaker.Snapshot(); snap.State == breaker.Open {
+					return fmt.Errorf("circuit open after %d consecutive failures, retrying at %s", snap.Failures, snap.Until.Format(time.RFC3339))
+				}
+				return nil
+			},
+		}),
+		health.WithInfoFunc(func(info map[string]any) {
+			if s.authBreaker == nil {
+				return
+			}
+			snap := s.authBreaker.Snapshot()
+			breakerInfo := map[string]any{
+				"state":                snap.State.String(),
+				"consecutive_failures": snap.Failures,
+			}
+			if !snap.Until.IsZero() {
+				breakerInfo["open_until"] = snap.Until.Format(time.RFC3339)
+			}
+			info["auth_service_circuit"] = breakerInfo
+		}),
+		health.WithInfoFunc(func(info map[string]any) {
+			if s.tokenManager == nil {
+				return
+			}
+			stats := s.tokenManager.Stats()
+			info["session_cache"] = map[string]any{
+				"cached":         stats.Cached,
+				"capacity":       stats.Capacity,
+				"hits":           stats.Hits,
+				"misses":         stats.Misses,
+				"refreshes":      stats.Refreshes,
+				"refresh_errors": stats.RefreshErrors,
+				"evictions":      stats.Evictions,
+				"expirations":    stats.Expirations,
+			}
+		}),
+
 		health.WithCheck(health.Check{
 			Name: "i-Ma'luum Official Website",
 			Check: func(_ context.Context) error {
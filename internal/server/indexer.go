@@ -9,24 +9,31 @@ import (
 	gei "github.com/nrmnqdds/gomaluum/internal/proto/gei"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
+// GEI resource kinds cached through GetResource/StoreResource. Schedules keep
+// their dedicated RPCs.
+const resultsResource = "results"
+
 // scheduleIndexer is the client for GEI's ScheduleIndexer: gomaluum's shared,
-// encrypted cache for scraped schedules. It persists across the stateless
-// serverless instances that in-process caches cannot span, letting a request
-// re-scrape only the latest semester and serve the rest from cache.
+// encrypted cache for scraped schedules and results. It persists across the
+// stateless serverless instances that in-process caches cannot span, letting a
+// request re-scrape only the latest semester and serve the rest from cache.
 type scheduleIndexer struct {
 	conn     *grpc.ClientConn
 	client   gei.ScheduleIndexerClient
 	adminKey string
 }
 
-// newScheduleIndexer dials GEI and returns a client. adminKey guards writes.
-func newScheduleIndexer(serviceURL, adminKey string) (*scheduleIndexer, error) {
+// newScheduleIndexer dials GEI with creds and returns a client. adminKey
+// guards writes, and reads on the embedded GEI.
+func newScheduleIndexer(serviceURL, adminKey string, creds credentials.TransportCredentials) (*scheduleIndexer, error) {
 	conn, err := grpc.NewClient(serviceURL,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(creds),
 		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
 	)
 	if err != nil {
@@ -49,6 +56,7 @@ func (i *scheduleIndexer) Close() error {
 // GetSchedule returns the cached schedule for username. found is false (with a
 // nil error) when the user has nothing cached yet.
 func (i *scheduleIndexer) GetSchedule(ctx context.Context, username string) (schedules []dtos.ScheduleResponse, found bool, err error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, "admin-key", i.adminKey)
 	resp, err := i.client.GetSchedule(ctx, &gei.GetScheduleRequest{Username: username})
 	if err != nil {
 		return nil, false, err
@@ -76,3 +84,39 @@ func (i *scheduleIndexer) StoreSchedule(ctx context.Context, username string, sc
 	})
 	return err
 }
+
+// GetResource decodes the cached resource of the given kind for username into
+// v. found is false (with a nil error) when nothing is cached yet, including
+// when GEI predates the resource RPCs.
+func (i *scheduleIndexer) GetResource(ctx context.Context, username, resource string, v any) (found bool, err error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, "admin-key", i.adminKey)
+	resp, err := i.client.GetResource(ctx, &gei.GetResourceRequest{Username: username, Resource: resource})
+	if status.Code(err) == codes.Unimplemented {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !resp.GetSuccess() {
+		return false, nil
+	}
+	if err := sonic.ConfigFastest.Unmarshal([]byte(resp.GetPayloadJson()), v); err != nil {
+		return false, fmt.Errorf("decoding cached %s: %w", resource, err)
+	}
+	return true, nil
+}
+
+// StoreResource caches v as the resource of the given kind for username.
+func (i *scheduleIndexer) StoreResource(ctx context.Context, username, resource string, v any) error {
+	payload, err := sonic.ConfigFastest.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding %s for cache: %w", resource, err)
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, "admin-key", i.adminKey)
+	_, err = i.client.StoreResource(ctx, &gei.StoreResourceRequest{
+		Username:    username,
+		Resource:    resource,
+		PayloadJson: string(payload),
+	})
+	return err
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/base64"
+	"net/http"
+	"sort"
+
+	"github.com/bytedance/sonic"
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+)
+
+// publicKeysMaxAge is how long clients may cache the key set. A rotated-in key
+// must be published at least this long before it signs tokens.
+const publicKeysMaxAge = "3600"
+
+// publicKeySet returns the keyring's verification keys, current key first.
+func (s *Server) publicKeySet() dtos.PublicKeySet {
+	set := dtos.PublicKeySet{Keys: make([]dtos.PublicKey, 0, len(s.paseto.VerificationKeys))}
+	for kid, key := range s.paseto.VerificationKeys {
+		x := base64.RawURLEncoding.EncodeToString(key.ExportBytes())
+		set.Keys = append(set.Keys, dtos.PublicKey{
+			KeyID:     kid,
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			X:         x,
+			Algorithm: "v4.public",
+			Use:       "sig",
+			PASERK:    "k4.public." + x,
+			Current:   kid == s.paseto.KeyID,
+		})
+	}
+	sort.Slice(set.Keys, func(i, j int) bool {
+		if set.Keys[i].Current != set.Keys[j].Current {
+			return set.Keys[i].Current
+		}
+		return set.Keys[i].KeyID < set.Keys[j].KeyID
+	})
+	return set
+}
+
+// @Title PublicKeysHandler
+// @Description Get the public keys access tokens are signed with (PASETO v4.public), JWKS-style, so third parties can verify tokens across key rotations. Pick the key whose kid matches the token footer. The body is the bare key set, not wrapped in a response object.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} dtos.PublicKeySet
+// @Router /api/auth/keys [get]
+func (s *Server) PublicKeysHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age="+publicKeysMaxAge)
+
+	if err := sonic.ConfigFastest.NewEncoder(w).Encode(s.publicKeySet()); err != nil {
+		s.log.ErrorContext(r.Context(), "Failed to encode response", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	gopaseto "aidanwoods.dev/go-paseto"
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+	"github.com/nrmnqdds/gomaluum/pkg/paseto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+	payload := TokenPayload{username: "2110000", password: "p@ss", imaluumCookie: "cookie", apiKey: "key"}
+
+	oldKeyring := s.paseto
+	token, _, err := s.GeneratePasetoToken(ctx, payload)
+	require.NoError(t, err)
+
+	// Rotate: a new signing key, the old one kept for verification only.
+	s.paseto = paseto.NewKeyring(gopaseto.NewV4AsymmetricSecretKey(), *oldKeyring.LocalKey, *oldKeyring.PublicKey)
+	require.NotEqual(t, oldKeyring.KeyID, s.paseto.KeyID)
+
+	sess, err := s.DecodePasetoToken(ctx, token, "key")
+	require.NoError(t, err, "tokens signed before the rotation stay valid")
+	require.Equal(t, "cookie", sess.imaluumCookie)
+
+	rotated, _, err := s.GeneratePasetoToken(ctx, payload)
+	require.NoError(t, err)
+	_, err = s.DecodePasetoToken(ctx, rotated, "key")
+	require.NoError(t, err)
+
+	// Once the old key is dropped its tokens are rejected.
+	s.paseto = paseto.NewKeyring(*s.paseto.PrivateKey, *s.paseto.LocalKey)
+	_, err = s.DecodePasetoToken(ctx, token, "key")
+	require.Equal(t, errors.ErrUnknownPASETOKeyID, err)
+
+	set := s.publicKeySet()
+	require.Len(t, set.Keys, 1)
+	require.Equal(t, s.paseto.KeyID, set.Keys[0].KeyID)
+	require.True(t, set.Keys[0].Current)
+	x, err := base64.RawURLEncoding.DecodeString(set.Keys[0].X)
+	require.NoError(t, err)
+	require.Equal(t, s.paseto.PublicKey.ExportBytes(), x)
+}
+
+func TestPublicKeySetOrder(t *testing.T) {
+	retired := []gopaseto.V4AsymmetricPublicKey{
+		gopaseto.NewV4AsymmetricSecretKey().Public(),
+		gopaseto.NewV4AsymmetricSecretKey().Public(),
+	}
+	s := &Server{paseto: paseto.NewKeyring(gopaseto.NewV4AsymmetricSecretKey(), gopaseto.NewV4SymmetricKey(), retired...)}
+
+	set := s.publicKeySet()
+	require.Len(t, set.Keys, 3)
+	require.True(t, set.Keys[0].Current, "current key first")
+	require.False(t, set.Keys[1].Current)
+	require.Less(t, set.Keys[1].KeyID, set.Keys[2].KeyID)
+}
@@ -0,0 +1,270 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Failed logins are throttled per username and per client IP before they
+// reach GAS, since repeated bad passwords from us could lock students' CAS
+// accounts or get the server's IP banned. After a few free attempts each
+// failure doubles the wait before the next attempt, and enough failures lock
+// the subject out for a while. Logins the server makes on a student's behalf
+// with a stored password count separately, so a stale stored password cannot
+// lock the student out of logging in with their new one. State is kept in
+// memory, per instance.
+
+// loginAttemptWindow is how long failures are remembered: a subject with no
+// failures for this long starts over.
+const loginAttemptWindow = time.Hour
+
+// loginPolicy is how failed logins of one kind of subject are throttled.
+type loginPolicy struct {
+	free          int           // failures allowed without waiting
+	backoff       time.Duration // wait after the first failure beyond free; doubles each time
+	maxBackoff    time.Duration
+	lockoutAfter  int // failures that lock the subject out
+	lockout       time.Duration
+	keepOnSuccess bool // a successful login doesn't forget the failures
+}
+
+var (
+	// loginUserPolicy stays well below CAS's own lockout.
+	loginUserPolicy = loginPolicy{free: 3, backoff: time.Second, maxBackoff: time.Minute, lockoutAfter: 8, lockout: 15 * time.Minute}
+
+	// loginIPPolicy allows for students sharing an IP, such as on campus Wi-Fi.
+	// One valid account doesn't reset an IP guessing at others.
+	loginIPPolicy = loginPolicy{free: 10, backoff: time.Second, maxBackoff: time.Minute, lockoutAfter: 50, lockout: 15 * time.Minute, keepOnSuccess: true}
+
+	// loginStoredPolicy is stricter: a stored password that fails is stale, and
+	// its credentials are forgotten, so further failures come from other stale
+	// copies (old feed URLs, refresh tokens) that should not reach CAS.
+	loginStoredPolicy = loginPolicy{free: 1, backoff: time.Minute, maxBackoff: 15 * time.Minute, lockoutAfter: 3, lockout: time.Hour}
+)
+
+type loginAttempts struct {
+	failures     int
+	pending      int // reserved attempts not resolved yet
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// loginLockout is a subject newly locked out by a failed login.
+type loginLockout struct {
+	subject  string
+	failures int
+	until    time.Time
+}
+
+type loginThrottle struct {
+	mu        sync.Mutex
+	subjects  map[string]*loginAttempts
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newLoginThrottle() *loginThrottle {
+	return &loginThrottle{
+		subjects: make(map[string]*loginAttempts),
+		now:      time.Now,
+	}
+}
+
+// loginSubjects are the counters a login counts against, with their policies.
+type loginSubjects map[string]loginPolicy
+
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// userLoginSubjects are the counters a student's own login of username from ip
+// counts against.
+func userLoginSubjects(username, ip string) loginSubjects {
+	subjects := loginSubjects{"user:" + normalizeUsername(username): loginUserPolicy}
+	if ip != "" {
+		subjects["ip:"+ip] = loginIPPolicy
+	}
+	return subjects
+}
+
+// storedLoginSubjects are the counters a login the server makes on username's
+// behalf, with a stored password, counts against.
+func storedLoginSubjects(username string) loginSubjects {
+	return loginSubjects{"stored:" + normalizeUsername(username): loginStoredPolicy}
+}
+
+// attempts returns the live counter of subject, forgetting expired failures.
+// It must be called with t.mu held.
+func (t *loginThrottle) attempts(subject string, now time.Time) *loginAttempts {
+	a, ok := t.subjects[subject]
+	if !ok {
+		a = &loginAttempts{}
+		t.subjects[subject] = a
+	}
+	if now.Sub(a.lastFailure) > loginAttemptWindow {
+		a.failures = 0
+	}
+	return a
+}
+
+// wait returns how long until a login counting against subjects may be
+// attempted, or 0 if it may now.
+func (t *loginThrottle) wait(subjects loginSubjects) time.Duration {
+	now := t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.waitLocked(subjects, now)
+}
+
+func (t *loginThrottle) waitLocked(subjects loginSubjects, now time.Time) time.Duration {
+	var wait time.Duration
+	for subject, policy := range subjects {
+		a, ok := t.subjects[subject]
+		if !ok {
+			continue
+		}
+		wait = max(wait, a.blockedUntil.Sub(now))
+		// Once failing, one attempt at a time past the free failures: otherwise
+		// a burst would all reach GAS before the next failure is recorded. A
+		// subject with no failures, such as a shared IP, isn't held back.
+		failures := a.failures
+		if now.Sub(a.lastFailure) > loginAttemptWindow {
+			failures = 0
+		}
+		if failures > 0 && a.pending > 0 && failures+a.pending >= policy.free {
+			wait = max(wait, policy.backoff)
+		}
+	}
+	return wait
+}
+
+// reserve is wait that, when the login may be attempted now, also reserves
+// the attempt in the same critical section. A reserved attempt must be
+// resolved with fail, succeed or release.
+func (t *loginThrottle) reserve(subjects loginSubjects) time.Duration {
+	now := t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if wait := t.waitLocked(subjects, now); wait > 0 {
+		return wait
+	}
+	for subject := range subjects {
+		t.attempts(subject, now).pending++
+	}
+	return 0
+}
+
+// fail resolves a failed login and returns the subjects it locked out.
+func (t *loginThrottle) fail(subjects loginSubjects) []loginLockout {
+	now := t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.sweep(now)
+
+	var lockouts []loginLockout
+	for subject, policy := range subjects {
+		a := t.attempts(subject, now)
+		a.pending = max(a.pending-1, 0)
+		a.failures++
+		a.lastFailure = now
+
+		switch {
+		case a.failures >= policy.lockoutAfter:
+			a.blockedUntil = now.Add(policy.lockout)
+			lockouts = append(lockouts, loginLockout{subject: subject, failures: a.failures, until: a.blockedUntil})
+		case a.failures > policy.free:
+			backoff := policy.maxBackoff
+			if shift := a.failures - policy.free - 1; shift < 16 {
+				backoff = min(policy.backoff<<shift, policy.maxBackoff)
+			}
+			a.blockedUntil = now.Add(backoff)
+		}
+	}
+	return lockouts
+}
+
+// release resolves a login that said nothing about the password, such as one
+// the auth service was unavailable for.
+func (t *loginThrottle) release(subjects loginSubjects) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for subject := range subjects {
+		if a, ok := t.subjects[subject]; ok {
+			a.pending = max(a.pending-1, 0)
+		}
+	}
+}
+
+// succeed resolves a successful login, forgetting the failures of subjects
+// whose policy doesn't keep them.
+func (t *loginThrottle) succeed(subjects loginSubjects) {
+	t.release(subjects)
+	t.forget(subjects)
+}
+
+// forget clears the failures of subjects whose policy doesn't keep them on
+// success, leaving attempts in flight reserved.
+func (t *loginThrottle) forget(subjects loginSubjects) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for subject, policy := range subjects {
+		a, ok := t.subjects[subject]
+		if !ok || policy.keepOnSuccess {
+			continue
+		}
+		if a.pending == 0 {
+			delete(t.subjects, subject)
+			continue
+		}
+		a.failures = 0
+		a.blockedUntil = time.Time{}
+	}
+}
+
+// sweep forgets subjects whose failures have expired.
+func (t *loginThrottle) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < loginAttemptWindow {
+		return
+	}
+	t.lastSweep = now
+	for subject, a := range t.subjects {
+		if a.pending == 0 && now.Sub(a.lastFailure) > loginAttemptWindow && now.After(a.blockedUntil) {
+			delete(t.subjects, subject)
+		}
+	}
+}
+
+// recordLoginFailure resolves a failed login of username from ip against
+// subjects and audits any lockout it causes.
+func (s *Server) recordLoginFailure(ctx context.Context, subjects loginSubjects, username, ip string) {
+	for _, lockout := range s.loginThrottle.fail(subjects) {
+		s.log.WarnContext(ctx, "Login locked out",
+			"audit", "login_lockout",
+			"subject", lockout.subject,
+			"username", username,
+			"ip", ip,
+			"failures", lockout.failures,
+			"until", lockout.until,
+		)
+	}
+}
+
+// isBadCredentials reports whether err definitively rejects the password:
+// ErrLoginFailed from the built-in CAS login, or Unauthenticated from GAS.
+func isBadCredentials(err error) bool {
+	return errors.Is(err, errors.ErrLoginFailed) || status.Code(err) == codes.Unauthenticated
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nrmnqdds/gomaluum/internal/constants"
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	now := time.Now()
+	lt := newLoginThrottle()
+	lt.now = func() time.Time { return now }
+	subjects := userLoginSubjects("2110000", "10.0.0.1")
+	user := userLoginSubjects("2110000", "")
+
+	for range loginUserPolicy.free {
+		require.Empty(t, lt.fail(subjects))
+		require.Zero(t, lt.wait(subjects))
+	}
+	require.Empty(t, lt.fail(subjects))
+	require.Equal(t, time.Second, lt.wait(subjects))
+	require.Equal(t, time.Second, lt.wait(userLoginSubjects(" 2110000", "10.0.0.2")), "usernames are throttled from any IP")
+	require.Zero(t, lt.wait(userLoginSubjects("2110001", "10.0.0.2")))
+	require.Empty(t, lt.fail(subjects))
+	require.Equal(t, 2*time.Second, lt.wait(user), "backoff doubles")
+
+	var lockouts []loginLockout
+	for range loginUserPolicy.lockoutAfter - loginUserPolicy.free - 2 {
+		lockouts = lt.fail(subjects)
+	}
+	require.Len(t, lockouts, 1)
+	require.Equal(t, "user:2110000", lockouts[0].subject)
+	require.Equal(t, loginUserPolicy.lockout, lt.wait(user))
+	require.Zero(t, lt.wait(storedLoginSubjects("2110000")), "stored-password logins count separately")
+
+	lt.succeed(user)
+	require.Zero(t, lt.wait(userLoginSubjects("2110000", "10.0.0.2")))
+	require.Zero(t, lt.wait(subjects), "the IP is still below its own limit")
+
+	now = now.Add(loginAttemptWindow + time.Minute)
+	for range loginUserPolicy.free {
+		lt.fail(user)
+	}
+	require.Zero(t, lt.wait(user), "failures are forgotten after the window")
+}
+
+func TestLoginThrottleReserve(t *testing.T) {
+	now := time.Now()
+	lt := newLoginThrottle()
+	lt.now = func() time.Time { return now }
+	subjects := userLoginSubjects("2110000", "10.0.0.1")
+
+	// With nothing failing, a concurrent burst isn't held back: students
+	// sharing an IP log in at the same time.
+	for range loginIPPolicy.free + 1 {
+		require.Zero(t, lt.reserve(subjects))
+	}
+	for range loginIPPolicy.free + 1 {
+		lt.release(subjects)
+	}
+
+	// Once failing, failures and attempts in flight stay within the free ones
+	// until they resolve.
+	require.Zero(t, lt.reserve(subjects))
+	lt.fail(subjects)
+	for range loginUserPolicy.free - 1 {
+		require.Zero(t, lt.reserve(subjects))
+	}
+	require.Positive(t, lt.reserve(subjects), "the burst waits for the reserved attempts")
+
+	lt.release(subjects)
+	require.Zero(t, lt.reserve(subjects), "an outage frees its reservation")
+	for range loginUserPolicy.free - 1 {
+		lt.fail(subjects)
+	}
+
+	// Past the free failures, attempts go one at a time.
+	require.Zero(t, lt.reserve(subjects))
+	require.Positive(t, lt.reserve(subjects))
+	lt.fail(subjects)
+	require.Equal(t, loginUserPolicy.backoff, lt.reserve(subjects))
+
+	lt.succeed(subjects)
+	require.Zero(t, lt.reserve(subjects))
+	lt.succeed(subjects)
+	require.Zero(t, lt.wait(subjects))
+}
+
+func TestLoginHandlerThrottled(t *testing.T) {
+	s := newTestServer()
+	login := func(username, password, ip string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(`{"username":"`+username+`","password":"`+password+`"}`))
+		r.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		s.LoginHandler(w, r)
+		return w
+	}
+
+	// GAS is not configured, so a throttled login must be refused before it
+	// is reached.
+	for range loginUserPolicy.free + 1 {
+		s.recordLoginFailure(t.Context(), userLoginSubjects("2110000", "10.0.0.1"), "2110000", "10.0.0.1")
+	}
+	w := login("2110000", "wrong", "10.0.0.2")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	for range loginIPPolicy.lockoutAfter {
+		s.recordLoginFailure(t.Context(), userLoginSubjects("2110001", "10.0.0.9"), "2110001", "10.0.0.9")
+	}
+	require.Equal(t, http.StatusTooManyRequests, login(constants.DebugUsername, constants.DebugPassword, "10.0.0.9").Code,
+		"a locked out IP can't try other usernames")
+
+	debug := userLoginSubjects(constants.DebugUsername, "")
+	s.recordLoginFailure(t.Context(), debug, constants.DebugUsername, "")
+	for range loginStoredPolicy.lockoutAfter {
+		s.recordLoginFailure(t.Context(), storedLoginSubjects(constants.DebugUsername), constants.DebugUsername, "")
+	}
+	require.Equal(t, http.StatusOK, login(constants.DebugUsername, constants.DebugPassword, "10.0.0.1").Code,
+		"a stale stored password doesn't lock the student out")
+	require.Zero(t, s.loginThrottle.wait(debug))
+	require.Zero(t, s.loginThrottle.wait(storedLoginSubjects(constants.DebugUsername)), "logging in clears stored-password failures")
+}
+
+func TestStalePasswordIsForgotten(t *testing.T) {
+	s := newTestServer()
+	s.auth = &fakeAuthenticator{login: func(context.Context) error { return errors.ErrLoginFailed }}
+	ctx := t.Context()
+
+	handle, err := s.credentials.Store(ctx, "2110000", "old-p@ss", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	_, _, err = s.loginFunc(ctx, "2110000", "old-p@ss", handle)()
+	require.Equal(t, errors.ErrLoginFailed, err)
+	_, found, err := s.credentials.Password(ctx, handle, "2110000")
+	require.NoError(t, err)
+	require.False(t, found, "a rejected stored password is not retried")
+	require.Zero(t, s.loginThrottle.wait(userLoginSubjects("2110000", "")), "nor does it count against the student's own logins")
+}
+
+func TestLoginHandlerChecksAPIKeyFirst(t *testing.T) {
+	s := newTestServer()
+	fake := &fakeAuthenticator{login: func(context.Context) error { return nil }}
+	s.auth = fake
+
+	for _, key := range []string{"gml_1700000000_forged", "not-a-key"} {
+		r := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(`{"username":"2110000","password":"p@ss"}`))
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("x-gomaluum-key", key)
+		w := httptest.NewRecorder()
+		s.LoginHandler(w, r)
+		require.NotEqual(t, http.StatusOK, w.Code, key)
+	}
+	require.Zero(t, fake.calls, "a rejected key never reaches the authenticator")
+	require.Zero(t, s.loginThrottle.wait(userLoginSubjects("2110000", "10.0.0.1")))
+}
+
+func TestLoginHandlerSharedIP(t *testing.T) {
+	s := newTestServer()
+	students := loginIPPolicy.free + 5
+
+	// Every login is held until all of them are in flight at once.
+	var arrived sync.WaitGroup
+	arrived.Add(students)
+	all := make(chan struct{})
+	go func() { arrived.Wait(); close(all) }()
+	s.auth = &fakeAuthenticator{login: func(context.Context) error {
+		arrived.Done()
+		select {
+		case <-all:
+			return nil
+		case <-time.After(5 * time.Second):
+			return status.Error(codes.DeadlineExceeded, "not all logins were let through")
+		}
+	}}
+
+	results := make([]int, students)
+	var wg sync.WaitGroup
+	for i := range students {
+		wg.Go(func() {
+			r := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(fmt.Sprintf(`{"username":"21100%02d","password":"p@ss"}`, i)))
+			r.RemoteAddr = "10.0.0.1:1234"
+			w := httptest.NewRecorder()
+			s.LoginHandler(w, r)
+			results[i] = w.Code
+		})
+	}
+	wg.Wait()
+	for i, code := range results {
+		require.Equal(t, http.StatusOK, code, "student %d on the shared IP", i)
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"net/http"
 
-	"github.com/nrmnqdds/gomaluum/pkg/apikey"
+	"github.com/nrmnqdds/gomaluum/internal/errors"
 )
 
 type originCookie int
@@ -12,9 +12,23 @@ type originCookie int
 const (
 	ctxToken originCookie = iota
 	ctxSession
+	ctxFreshness
+	ctxAPIKey
 )
 
-func (s *Server) PasetoAuthenticator() func(http.Handler) http.Handler {
+// requestUsername returns the authenticated user's username, or "" when ctx
+// carries no session.
+func requestUsername(ctx context.Context) string {
+	if sess, ok := ctx.Value(ctxSession).(*TokenPayload); ok && sess != nil {
+		return sess.username
+	}
+	return ""
+}
+
+// PasetoAuthenticator authenticates the bearer token and requires it to carry
+// all of scopes. Tokens issued to third-party apps only carry the scopes the
+// student granted; first-party tokens carry all of them.
+func (s *Server) PasetoAuthenticator(scopes ...string) func(http.Handler) http.Handler {
 	logger := s.log
 	return func(next http.Handler) http.Handler {
 		hfn := func(w http.ResponseWriter, r *http.Request) {
@@ -35,20 +49,29 @@ func (s *Server) PasetoAuthenticator() func(http.Handler) http.Handler {
 
 			authHeader := fullAuthHeader[7:]
 
-			// Get API key from header
-			userAPIKey := r.Header.Get("x-gomaluum-key")
-			if userAPIKey == "" {
-				userAPIKey = apikey.DefaultAPIKey
-			} else {
-				// Validate the provided API key format
-				if !apikey.ValidateAPIKey(userAPIKey) {
-					logger.WarnContext(r.Context(), "Invalid API key format")
-					http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			// Get API key from header and check it against the registry
+			use, err := s.requestAPIKeyUse(r)
+			if err != nil {
+				logger.WarnContext(r.Context(), "API key rejected", "error", err)
+				errors.Render(w, r, err)
+				return
+			}
+
+			// Rate limit before the session is restored: an expired legacy token
+			// logs into i-Ma'luum again, and that is what the limit protects.
+			var token *TokenPayload
+			verified, err := s.verifyPasetoToken(r.Context(), authHeader, use.key)
+			if err == nil {
+				if !s.allowRequest(w, r, s.rateLimitSubject(r, use, verified.username), use.tier) {
 					return
 				}
+				token, err = s.tokenSession(r.Context(), verified)
+			}
+			if err == errors.ErrAccessTokenExpired || err == errors.ErrTokenRevoked || err == errors.ErrLegacyTokenRetired || err == errors.ErrTooManyLoginAttempts {
+				// Tell the client whether to refresh, log in again or wait.
+				errors.Render(w, r, err)
+				return
 			}
-
-			token, err := s.DecodePasetoToken(r.Context(), authHeader, userAPIKey)
 			if err != nil {
 				logger.ErrorContext(r.Context(), "Failed to decode token", "error", err)
 
@@ -62,11 +85,19 @@ func (s *Server) PasetoAuthenticator() func(http.Handler) http.Handler {
 				return
 			}
 
+			if !token.hasScopes(scopes...) {
+				logger.WarnContext(r.Context(), "Token lacks required scopes", "client_id", token.clientID, "required", scopes)
+				errors.Render(w, r, errors.ErrInsufficientScope)
+				return
+			}
+
 			logger.DebugContext(r.Context(), "Token is authenticated", "cookie", "MOD_AUTH_CAS="+token.imaluumCookie)
 
 			// Create a new context from the request context and add the token to it
 			ctx := context.WithValue(r.Context(), ctxToken, token.imaluumCookie)
 			ctx = context.WithValue(ctx, ctxSession, token)
+			ctx = context.WithValue(ctx, ctxAPIKey, use)
+			ctx = withFreshness(ctx)
 
 			// Token is authenticated, pass it through
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -0,0 +1,566 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"aidanwoods.dev/go-paseto"
+	"github.com/bytedance/sonic"
+	"github.com/lib/pq"
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+)
+
+// Third-party apps get scoped tokens through an OAuth2-style authorization
+// code flow:
+//
+//  1. The app is registered once (RegisterOAuthClientHandler) with its
+//     redirect URIs and the scopes it may request.
+//  2. The student's first-party client shows the consent screen
+//     (OAuthConsentHandler) and, on approval, gets a one-time code
+//     (AuthorizeHandler) to pass to the app's redirect URI.
+//  3. The app exchanges the code with its client secret for an access and
+//     refresh token limited to the approved scopes (OAuthTokenHandler).
+
+const (
+	// authCodeType is the "typ" claim of authorization codes.
+	authCodeType = "code"
+
+	// authCodeTTL is how long an app has to exchange a code.
+	authCodeTTL = time.Minute
+)
+
+// oauthClient is a registered third-party app.
+type oauthClient struct {
+	id           string
+	secretHash   string // hex SHA-256 of the client secret
+	name         string
+	owner        string // username of the student who registered it
+	redirectURIs []string
+	scopes       []string // the most the app may request
+	createdAt    time.Time
+}
+
+type oauthClientStore interface {
+	Create(ctx context.Context, client *oauthClient) error
+	Get(ctx context.Context, id string) (client *oauthClient, found bool, err error)
+}
+
+// newOAuthClientStore returns a Postgres-backed registry when a database is
+// configured, otherwise an in-memory one that does not survive a restart.
+func newOAuthClientStore(db *sql.DB) oauthClientStore {
+	if db != nil {
+		return &postgresOAuthClients{db: db}
+	}
+	return &memoryOAuthClients{clients: make(map[string]*oauthClient)}
+}
+
+func hashClientSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// verifySecret reports whether secret is the client's secret.
+func (c *oauthClient) verifySecret(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashClientSecret(secret)), []byte(c.secretHash)) == 1
+}
+
+// validRedirectURI accepts absolute https URIs, and http only for local
+// development.
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.Fragment != "" {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		return u.Hostname() == "localhost" || u.Hostname() == "127.0.0.1"
+	}
+	return false
+}
+
+// authorization checks an app's request for scope on behalf of a student and
+// returns the app and the parsed scopes. The redirect URI must match a
+// registered one exactly, and the scopes must be within the app's.
+func (s *Server) authorization(ctx context.Context, clientID, redirectURI, scope string) (*oauthClient, []string, error) {
+	client, found, err := s.oauthClients.Get(ctx, clientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		return nil, nil, errors.ErrInvalidClient
+	}
+	if !slices.Contains(client.redirectURIs, redirectURI) {
+		return nil, nil, errors.ErrInvalidRedirectURI
+	}
+	scopes, err := parseScopes(scope)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, sc := range scopes {
+		if !slices.Contains(client.scopes, sc) {
+			return nil, nil, errors.ErrInvalidScope
+		}
+	}
+	return client, scopes, nil
+}
+
+// authCodeClaims is what an authorization code carries. The code is v4.local,
+// so the i-Ma'luum cookie stays confidential; the password stays in the
+// credential vault.
+type authCodeClaims struct {
+	id            string
+	username      string
+	credential    string
+	imaluumCookie string
+	clientID      string
+	redirectURI   string
+	scopes        []string
+	issuedAt      time.Time
+	expiresAt     time.Time
+}
+
+// generateAuthCode mints a one-time code granting scopes of sess's account to
+// the app.
+func (s *Server) generateAuthCode(ctx context.Context, sess *TokenPayload, clientID, redirectURI string, scopes []string) (string, *authCodeClaims, error) {
+	id, err := newTokenID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	claims := &authCodeClaims{
+		id:            id,
+		username:      sess.username,
+		imaluumCookie: sess.imaluumCookie,
+		clientID:      clientID,
+		redirectURI:   redirectURI,
+		scopes:        scopes,
+		issuedAt:      now,
+		expiresAt:     now.Add(authCodeTTL),
+	}
+	claims.credential, err = s.credentials.Store(ctx, sess.username, sess.password, claims.expiresAt)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token := paseto.NewToken()
+	token.SetIssuer("gomaluum")
+	token.SetIssuedAt(now)
+	token.SetNotBefore(now)
+	token.SetExpiration(claims.expiresAt)
+	token.SetJti(claims.id)
+	token.SetSubject(claims.username)
+	token.SetString("typ", authCodeType)
+	token.SetString("cred", claims.credential)
+	token.SetString("imaluumCookie", claims.imaluumCookie)
+	token.SetString("client_id", claims.clientID)
+	token.SetString("redirect_uri", claims.redirectURI)
+	token.SetString("scope", strings.Join(claims.scopes, " "))
+
+	return token.V4Encrypt(*s.paseto.LocalKey, nil), claims, nil
+}
+
+// parseAuthCode decrypts and validates an authorization code. It does not
+// consult the revocation list.
+func (s *Server) parseAuthCode(code string) (*authCodeClaims, error) {
+	parser := paseto.NewParser() // checks expiry and not-before
+	parser.AddRule(paseto.IssuedBy("gomaluum"))
+
+	decoded, err := parser.ParseV4Local(*s.paseto.LocalKey, code, nil)
+	if err != nil {
+		return nil, err
+	}
+	if typ, err := decoded.GetString("typ"); err != nil || typ != authCodeType {
+		return nil, errors.ErrInvalidAuthorizationCode
+	}
+
+	var claims authCodeClaims
+	if claims.id, err = decoded.GetJti(); err != nil {
+		return nil, err
+	}
+	if claims.username, err = decoded.GetSubject(); err != nil {
+		return nil, err
+	}
+	if claims.issuedAt, err = decoded.GetIssuedAt(); err != nil {
+		return nil, err
+	}
+	if claims.expiresAt, err = decoded.GetExpiration(); err != nil {
+		return nil, err
+	}
+	for claim, v := range map[string]*string{
+		"cred":          &claims.credential,
+		"imaluumCookie": &claims.imaluumCookie,
+		"client_id":     &claims.clientID,
+		"redirect_uri":  &claims.redirectURI,
+	} {
+		if *v, err = decoded.GetString(claim); err != nil {
+			return nil, err
+		}
+	}
+	_, claims.scopes = tokenScopes(decoded)
+	return &claims, nil
+}
+
+// @Title RegisterOAuthClientHandler
+// @Description Register a third-party app. The app may later ask students for any of the given scopes (profile:basic, profile:full, schedule:read, result:read, exam:read, starpoint:read, disciplinary:read, carry-mark:read, documents:read). Save the client secret: it is only shown once.
+// @Tags oauth
+// @Accept json
+// @Produce json
+// @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Param request body dtos.OAuthClientRequest true "App name, redirect URIs and scopes"
+// @Success 200 {object} dtos.ResponseDTO{data=dtos.OAuthClient}
+// @Failure 400 {object} errors.CustomError "Invalid redirect URI or scope"
+// @Router /api/oauth/clients [post]
+func (s *Server) RegisterOAuthClientHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx := r.Context()
+	logger := s.log
+	sess := ctx.Value(ctxSession).(*TokenPayload)
+
+	var req dtos.OAuthClientRequest
+	if err := sonic.ConfigFastest.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Name) == "" || len(req.RedirectURIs) == 0 {
+		logger.ErrorContext(ctx, "Failed to decode request body", "error", err)
+		errors.Render(w, r, errors.ErrInvalidRequest)
+		return
+	}
+	for _, uri := range req.RedirectURIs {
+		if !validRedirectURI(uri) {
+			errors.Render(w, r, errors.ErrInvalidRedirectURI)
+			return
+		}
+	}
+	scopes, err := parseScopes(strings.Join(req.Scopes, " "))
+	if err != nil {
+		errors.Render(w, r, err)
+		return
+	}
+
+	id, err := newTokenID()
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to generate client ID", "error", err)
+		errors.Render(w, r, errors.ErrFailedToRegisterClient)
+		return
+	}
+	var secret [32]byte
+	if _, err := rand.Read(secret[:]); err != nil {
+		logger.ErrorContext(ctx, "Failed to generate client secret", "error", err)
+		errors.Render(w, r, errors.ErrFailedToRegisterClient)
+		return
+	}
+	clientSecret := hex.EncodeToString(secret[:])
+
+	client := &oauthClient{
+		id:           "gmc_" + id,
+		secretHash:   hashClientSecret(clientSecret),
+		name:         strings.TrimSpace(req.Name),
+		owner:        sess.username,
+		redirectURIs: req.RedirectURIs,
+		scopes:       scopes,
+		createdAt:    time.Now(),
+	}
+	if err := s.oauthClients.Create(ctx, client); err != nil {
+		logger.ErrorContext(ctx, "Failed to register app", "error", err)
+		errors.Render(w, r, errors.ErrFailedToRegisterClient)
+		return
+	}
+
+	response := &dtos.ResponseDTO{
+		Message: "Successfully registered app. Save the client secret: it will not be shown again.",
+		Data: dtos.OAuthClient{
+			ClientID:     client.id,
+			ClientSecret: clientSecret,
+			Name:         client.name,
+			RedirectURIs: client.redirectURIs,
+			Scopes:       client.scopes,
+			CreatedAt:    client.createdAt.Unix(),
+		},
+	}
+
+	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
+		logger.ErrorContext(ctx, "Failed to encode response", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+	}
+}
+
+// @Title OAuthConsentHandler
+// @Description Describe an app's request for the consent screen: which app is asking and what each requested scope gives it access to. Nothing is granted until the student approves with POST /api/oauth/authorize.
+// @Tags oauth
+// @Produce json
+// @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Param client_id query string true "App client ID"
+// @Param redirect_uri query string true "One of the app's registered redirect URIs"
+// @Param scope query string true "Space-separated scopes"
+// @Success 200 {object} dtos.ResponseDTO{data=dtos.OAuthConsent}
+// @Failure 400 {object} errors.CustomError "Invalid redirect URI or scope"
+// @Router /api/oauth/authorize [get]
+func (s *Server) OAuthConsentHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx := r.Context()
+	logger := s.log
+	query := r.URL.Query()
+
+	client, scopes, err := s.authorization(ctx, query.Get("client_id"), query.Get("redirect_uri"), query.Get("scope"))
+	if err != nil {
+		errors.Render(w, r, err)
+		return
+	}
+
+	consent := dtos.OAuthConsent{
+		ClientID:    client.id,
+		ClientName:  client.name,
+		RedirectURI: query.Get("redirect_uri"),
+		Scopes:      make([]dtos.ConsentScope, 0, len(scopes)),
+	}
+	for _, sc := range scopes {
+		consent.Scopes = append(consent.Scopes, dtos.ConsentScope{Scope: sc, Description: grantableScopes[sc]})
+	}
+
+	response := &dtos.ResponseDTO{
+		Message: "Successfully fetched consent request",
+		Data:    consent,
+	}
+
+	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
+		logger.ErrorContext(ctx, "Failed to encode response", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+	}
+}
+
+// @Title AuthorizeHandler
+// @Description Approve an app's request. Returns a one-time authorization code, valid for a minute, and the redirect URI to send the student to with the code and state attached. The app exchanges the code at /api/oauth/token.
+// @Tags oauth
+// @Accept json
+// @Produce json
+// @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Param request body dtos.AuthorizeRequest true "The approved request"
+// @Success 200 {object} dtos.ResponseDTO{data=dtos.AuthorizeResponse}
+// @Failure 400 {object} errors.CustomError "Invalid redirect URI or scope"
+// @Router /api/oauth/authorize [post]
+func (s *Server) AuthorizeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx := r.Context()
+	logger := s.log
+	sess := ctx.Value(ctxSession).(*TokenPayload)
+
+	var req dtos.AuthorizeRequest
+	if err := sonic.ConfigFastest.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.ErrorContext(ctx, "Failed to decode request body", "error", err)
+		errors.Render(w, r, errors.ErrInvalidRequest)
+		return
+	}
+
+	client, scopes, err := s.authorization(ctx, req.ClientID, req.RedirectURI, req.Scope)
+	if err != nil {
+		errors.Render(w, r, err)
+		return
+	}
+
+	code, claims, err := s.generateAuthCode(ctx, sess, client.id, req.RedirectURI, scopes)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to generate authorization code", "error", err)
+		errors.Render(w, r, errors.ErrFailedToGeneratePASETO)
+		return
+	}
+
+	redirect, _ := url.Parse(req.RedirectURI) // validated at registration
+	params := redirect.Query()
+	params.Set("code", code)
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	redirect.RawQuery = params.Encode()
+
+	response := &dtos.ResponseDTO{
+		Message: "Successfully authorized app",
+		Data: dtos.AuthorizeResponse{
+			Code:        code,
+			RedirectURI: redirect.String(),
+			ExpiresAt:   claims.expiresAt.Unix(),
+		},
+	}
+
+	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
+		logger.ErrorContext(ctx, "Failed to encode response", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+	}
+}
+
+// @Title OAuthTokenHandler
+// @Description Exchange an authorization code for an access token and refresh token limited to the scopes the student approved. Codes are single-use. Refresh the pair at /api/auth/refresh; the scopes carry over.
+// @Tags oauth
+// @Accept json
+// @Produce json
+// @Param x-gomaluum-key header string false "API key the tokens will be used with"
+// @Param request body dtos.OAuthTokenRequest true "Authorization code and client credentials"
+// @Success 200 {object} dtos.ResponseDTO{data=dtos.AuthTokens}
+// @Failure 400 {object} errors.CustomError "Invalid, expired or used code"
+// @Failure 401 {object} errors.CustomError "Unknown app or wrong client secret"
+// @Router /api/oauth/token [post]
+func (s *Server) OAuthTokenHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx := r.Context()
+	logger := s.log
+
+	var req dtos.OAuthTokenRequest
+	if err := sonic.ConfigFastest.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.ErrorContext(ctx, "Failed to decode request body", "error", err)
+		errors.Render(w, r, errors.ErrInvalidRequest)
+		return
+	}
+	if req.GrantType != "authorization_code" {
+		errors.Render(w, r, errors.ErrUnsupportedGrantType)
+		return
+	}
+
+	userAPIKey, err := s.requestAPIKey(r)
+	if err != nil {
+		logger.WarnContext(ctx, "API key rejected in token exchange", "error", err)
+		errors.Render(w, r, err)
+		return
+	}
+
+	client, found, err := s.oauthClients.Get(ctx, req.ClientID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to look up app", "error", err)
+		errors.Render(w, r, err)
+		return
+	}
+	if !found || !client.verifySecret(req.ClientSecret) {
+		errors.Render(w, r, errors.ErrInvalidClient)
+		return
+	}
+
+	claims, err := s.parseAuthCode(req.Code)
+	if err != nil || claims.clientID != client.id || claims.redirectURI != req.RedirectURI {
+		logger.WarnContext(ctx, "Invalid authorization code", "client_id", client.id, "error", err)
+		errors.Render(w, r, errors.ErrInvalidAuthorizationCode)
+		return
+	}
+
+	revoked, err := s.revocations.IsRevoked(ctx, claims.id, claims.username, claims.issuedAt)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check authorization code revocation", "error", err)
+		errors.Render(w, r, err)
+		return
+	}
+	if revoked {
+		logger.WarnContext(ctx, "Used authorization code presented", "client_id", client.id)
+		errors.Render(w, r, errors.ErrInvalidAuthorizationCode)
+		return
+	}
+	// Spend the code before issuing anything. Only one of concurrent
+	// exchanges of the same code gets to spend it.
+	spent, err := s.revocations.Spend(ctx, claims.id, claims.username, claims.expiresAt)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to revoke authorization code", "error", err)
+		errors.Render(w, r, err)
+		return
+	}
+	if !spent {
+		logger.WarnContext(ctx, "Used authorization code presented", "client_id", client.id)
+		errors.Render(w, r, errors.ErrInvalidAuthorizationCode)
+		return
+	}
+
+	password, found, err := s.credentials.Password(ctx, claims.credential, claims.username)
+	if err != nil || !found {
+		logger.WarnContext(ctx, "Authorization code credentials are gone", "error", err)
+		errors.Render(w, r, errors.ErrInvalidAuthorizationCode)
+		return
+	}
+	if err := s.credentials.Forget(ctx, claims.credential); err != nil {
+		logger.WarnContext(ctx, "Failed to forget authorization code credentials", "error", err)
+	}
+
+	tokens, err := s.issueTokens(ctx, TokenPayload{
+		username:      claims.username,
+		password:      password,
+		imaluumCookie: claims.imaluumCookie,
+		apiKey:        userAPIKey,
+		clientID:      client.id,
+		scopes:        claims.scopes,
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to generate PASETO token", "error", err)
+		errors.Render(w, r, errors.ErrFailedToGeneratePASETO)
+		return
+	}
+
+	response := &dtos.ResponseDTO{
+		Message: "Successfully issued app tokens",
+		Data:    tokens,
+	}
+
+	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
+		logger.ErrorContext(ctx, "Failed to encode response", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+	}
+}
+
+type memoryOAuthClients struct {
+	mu      sync.RWMutex
+	clients map[string]*oauthClient
+}
+
+func (m *memoryOAuthClients) Create(_ context.Context, client *oauthClient) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clients[client.id] = client
+	return nil
+}
+
+func (m *memoryOAuthClients) Get(_ context.Context, id string) (*oauthClient, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	client, ok := m.clients[id]
+	return client, ok, nil
+}
+
+type postgresOAuthClients struct {
+	db *sql.DB
+}
+
+func (p *postgresOAuthClients) Create(ctx context.Context, client *oauthClient) error {
+	_, err := p.db.ExecContext(ctx, `
+			INSERT INTO oauth_clients (client_id, secret_hash, name, owner, redirect_uris, scopes, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, client.id, client.secretHash, client.name, client.owner, pq.Array(client.redirectURIs), pq.Array(client.scopes), client.createdAt)
+	return err
+}
+
+func (p *postgresOAuthClients) Get(ctx context.Context, id string) (*oauthClient, bool, error) {
+	client := &oauthClient{id: id}
+	err := p.db.QueryRowContext(ctx, `
+			SELECT secret_hash, name, owner, redirect_uris, scopes, created_at FROM oauth_clients
+			WHERE client_id = $1
+		`, id).Scan(&client.secretHash, &client.name, &client.owner, pq.Array(&client.redirectURIs), pq.Array(&client.scopes), &client.createdAt)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return client, true, nil
+}
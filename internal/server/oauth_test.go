@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/nrmnqdds/gomaluum/internal/constants"
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/stretchr/testify/require"
+)
+
+// oauthCall runs handler with a JSON body and, when sess is set, as that
+// student. It returns the status and decodes the response data into data.
+func oauthCall(t *testing.T, handler http.HandlerFunc, method, target, body string, sess *TokenPayload, data any) int {
+	t.Helper()
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if sess != nil {
+		r = r.WithContext(context.WithValue(r.Context(), ctxSession, sess))
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	if w.Code == http.StatusOK && data != nil {
+		require.NoError(t, sonic.ConfigFastest.Unmarshal(w.Body.Bytes(), &dtos.ResponseDTO{Data: data}))
+	}
+	return w.Code
+}
+
+func TestOAuthFlow(t *testing.T) {
+	s := newTestServer()
+	student := &TokenPayload{
+		username:      constants.DebugUsername,
+		password:      constants.DebugPassword,
+		imaluumCookie: constants.DebugUserCookie,
+		apiKey:        "key",
+	}
+
+	var client dtos.OAuthClient
+	code := oauthCall(t, s.RegisterOAuthClientHandler, http.MethodPost, "/api/oauth/clients",
+		`{"name":"Timetable App","redirect_uris":["https://app.example/callback"],"scopes":["schedule:read","profile:basic"]}`, student, &client)
+	require.Equal(t, http.StatusOK, code)
+	require.NotEmpty(t, client.ClientSecret)
+
+	code = oauthCall(t, s.RegisterOAuthClientHandler, http.MethodPost, "/api/oauth/clients",
+		`{"name":"Bad","redirect_uris":["http://app.example/callback"],"scopes":["schedule:read"]}`, student, nil)
+	require.Equal(t, http.StatusBadRequest, code, "plain http redirect URIs are only allowed for localhost")
+
+	query := url.Values{"client_id": {client.ClientID}, "redirect_uri": {"https://app.example/callback"}, "scope": {"schedule:read"}}
+	var consent dtos.OAuthConsent
+	code = oauthCall(t, s.OAuthConsentHandler, http.MethodGet, "/api/oauth/authorize?"+query.Encode(), "", student, &consent)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "Timetable App", consent.ClientName)
+	require.Equal(t, grantableScopes[scopeScheduleRead], consent.Scopes[0].Description)
+
+	query.Set("scope", "schedule:read result:read")
+	code = oauthCall(t, s.OAuthConsentHandler, http.MethodGet, "/api/oauth/authorize?"+query.Encode(), "", student, nil)
+	require.Equal(t, http.StatusBadRequest, code, "scopes beyond the app's registration are refused")
+	query.Set("scope", "schedule:read account")
+	code = oauthCall(t, s.OAuthConsentHandler, http.MethodGet, "/api/oauth/authorize?"+query.Encode(), "", student, nil)
+	require.Equal(t, http.StatusBadRequest, code, "account is never grantable")
+
+	var grant dtos.AuthorizeResponse
+	code = oauthCall(t, s.AuthorizeHandler, http.MethodPost, "/api/oauth/authorize",
+		`{"client_id":"`+client.ClientID+`","redirect_uri":"https://app.example/callback","scope":"schedule:read","state":"xyz"}`, student, &grant)
+	require.Equal(t, http.StatusOK, code)
+	redirect, err := url.Parse(grant.RedirectURI)
+	require.NoError(t, err)
+	require.Equal(t, grant.Code, redirect.Query().Get("code"))
+	require.Equal(t, "xyz", redirect.Query().Get("state"))
+
+	exchange := func(secret string) (int, dtos.AuthTokens) {
+		var tokens dtos.AuthTokens
+		code := oauthCall(t, s.OAuthTokenHandler, http.MethodPost, "/api/oauth/token",
+			`{"grant_type":"authorization_code","code":"`+grant.Code+`","redirect_uri":"https://app.example/callback","client_id":"`+client.ClientID+`","client_secret":"`+secret+`"}`, nil, &tokens)
+		return code, tokens
+	}
+	code, _ = exchange("wrong")
+	require.Equal(t, http.StatusUnauthorized, code)
+	code, tokens := exchange(client.ClientSecret)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "schedule:read", tokens.Scope)
+	code, _ = exchange(client.ClientSecret)
+	require.Equal(t, http.StatusBadRequest, code, "codes are single-use")
+
+	// The authenticator enforces the granted scopes per route group.
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	call := func(token string, scopes ...string) int {
+		r := httptest.NewRequest(http.MethodGet, "/api/x", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		s.PasetoAuthenticator(scopes...)(ok).ServeHTTP(w, r)
+		return w.Code
+	}
+	require.Equal(t, http.StatusOK, call(tokens.Token, scopeScheduleRead))
+	require.Equal(t, http.StatusForbidden, call(tokens.Token, scopeResultRead))
+	require.Equal(t, http.StatusForbidden, call(tokens.Token, scopeAccount))
+	require.Equal(t, http.StatusOK, call(tokens.Token))
+
+	first, err := s.issueTokens(context.Background(), TokenPayload{username: "2110000", password: "p@ss", imaluumCookie: "cookie", apiKey: "gomaluum-default-key-2024"})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, call(first.Token, scopeResultRead, scopeAccount), "first-party tokens have every scope")
+
+	// Refreshing keeps the grant.
+	var refreshed dtos.AuthTokens
+	code = oauthCall(t, s.RefreshTokenHandler, http.MethodPost, "/api/auth/refresh",
+		`{"refresh_token":"`+tokens.RefreshToken+`"}`, nil, &refreshed)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "schedule:read", refreshed.Scope)
+	require.Equal(t, http.StatusForbidden, call(refreshed.Token, scopeResultRead))
+}
+
+func TestBasicProfile(t *testing.T) {
+	full := &dtos.Profile{Name: "ALI BIN ABU", MatricNo: "2110000", IC: "000101-01-0001", Address: "Gombak"}
+	basic := basicProfile(full)
+	require.Equal(t, "ALI BIN ABU", basic.Name)
+	require.Empty(t, basic.IC)
+	require.Empty(t, basic.Address)
+}
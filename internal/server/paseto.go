@@ -2,12 +2,17 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/cristalhq/base64"
 
 	"aidanwoods.dev/go-paseto"
 	"github.com/nrmnqdds/gomaluum/internal/constants"
+	"github.com/nrmnqdds/gomaluum/internal/errors"
 	pb "github.com/nrmnqdds/gomaluum/internal/proto"
 	"github.com/nrmnqdds/gomaluum/pkg/apikey"
 )
@@ -17,6 +22,22 @@ type TokenPayload struct {
 	password      string
 	imaluumCookie string
 	apiKey        string
+
+	// Token metadata, filled in by DecodePasetoToken for revocation. Legacy
+	// tokens carry none of it. refreshID (the jti of the refresh token issued
+	// alongside) and credential are also read by GeneratePasetoToken, so logout
+	// can revoke both and forget their shared credentials.
+	tokenID    string
+	refreshID  string
+	credential string // credential vault handle
+	issuedAt   time.Time
+	expiresAt  time.Time
+
+	// Set for tokens issued to a third-party app (see oauth.go): the app and
+	// the scopes the student granted it. nil scopes means a first-party token
+	// with every scope. Read by GeneratePasetoToken too.
+	clientID string
+	scopes   []string
 }
 
 // imaluumSessionTTL is how long a fetched i-Ma'luum session cookie is reused
@@ -25,62 +46,152 @@ type TokenPayload struct {
 // and requests fail until the entry expires.
 const imaluumSessionTTL = 30 * time.Minute
 
-// GeneratePasetoToken generates a PASETO token for the given original uia cookie
-// origin: the original uia cookie
-// username: the username of the user
-// password: the password of the user in base64
-func (s *Server) GeneratePasetoToken(payload TokenPayload) (string, string, error) {
-	logger := s.log
-	token := s.paseto.Token
+const (
+	// accessTokenType is the "typ" claim of bearer tokens. Tokens minted before
+	// refresh tokens existed carry no type (see DecodePasetoToken).
+	accessTokenType = "access"
 
-	token.SetIssuedAt(time.Now())
-	token.SetNotBefore(time.Now())
-	// token.SetExpiration(time.Now().Add(time.Minute * 30)) // 30 minutes
-	token.SetExpiration(time.Now()) // now
-	// token.SetExpiration(time.Now().Add(time.Minute * 1)) // 1 minutes
-	token.SetIssuer("gomaluum")
+	// accessTokenTTL is how long a bearer token is accepted. It stays below
+	// imaluumSessionTTL so the i-Ma'luum cookie it carries is still usable.
+	accessTokenTTL = 15 * time.Minute
+)
 
-	originPassword := payload.password
-	imaluumCookie := payload.imaluumCookie
-	username := payload.username
-	userAPIKey := payload.apiKey
+// newTokenID returns a random token ID for the jti claim.
+func newTokenID() (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id[:]), nil
+}
 
-	// encode the base64 password
-	password := []byte(originPassword)
-	base64Password := base64.StdEncoding.EncodeToString(password)
+// accessClaims is what an access token asserts. It is a value built for each
+// token and only turned into a paseto.Token when signing, so concurrent logins
+// share no mutable token state.
+type accessClaims struct {
+	id         string
+	refreshID  string // jti of the refresh token issued alongside, if any
+	credential string // credential vault handle
+	issuedAt   time.Time
+	expiresAt  time.Time
+	clientID   string
+	scopes     []string // nil for first-party tokens
+
+	// Encrypted with the user's API key.
+	encryptedUsername string
+	encryptedCookie   string
+}
 
-	// Encrypt sensitive data with API key before storing in PASETO
-	encryptedCookie, err := apikey.EncryptWithAPIKey(imaluumCookie, userAPIKey)
-	if err != nil {
-		logger.Error("Failed to encrypt cookie with API key", "error", err)
-		return "", "", err
+// token returns a new, unsigned paseto.Token holding c.
+func (c accessClaims) token() paseto.Token {
+	token := paseto.NewToken()
+	token.SetIssuer("gomaluum")
+	token.SetIssuedAt(c.issuedAt)
+	token.SetNotBefore(c.issuedAt)
+	token.SetExpiration(c.expiresAt)
+	token.SetJti(c.id)
+	token.SetString("typ", accessTokenType)
+	if c.refreshID != "" {
+		token.SetString("rti", c.refreshID)
+	}
+	token.SetString("imaluumCookie", c.encryptedCookie)
+	token.SetString("username", c.encryptedUsername)
+	token.SetString("cred", c.credential)
+	if c.scopes != nil {
+		token.SetString("client_id", c.clientID)
+		token.SetString("scope", strings.Join(c.scopes, " "))
 	}
+	return token
+}
 
-	encryptedUsername, err := apikey.EncryptWithAPIKey(username, userAPIKey)
+// GeneratePasetoToken generates a short-lived access token for the given
+// original uia cookie and returns it with its expiry. Use a refresh token (see
+// generateRefreshToken) to get a new one.
+//
+// The password never enters the token: it is sealed in s.credentials and the
+// token carries only the opaque handle ("cred"), either payload.credential or a
+// new record for the token's lifetime.
+func (s *Server) GeneratePasetoToken(ctx context.Context, payload TokenPayload) (string, time.Time, error) {
+	logger := s.log
+
+	jti, err := newTokenID()
 	if err != nil {
-		logger.Error("Failed to encrypt username with API key", "error", err)
-		return "", "", err
+		logger.ErrorContext(ctx, "Failed to generate token ID", "error", err)
+		return "", time.Time{}, err
 	}
 
-	encryptedPassword, err := apikey.EncryptWithAPIKey(base64Password, userAPIKey)
+	now := time.Now()
+	expiresAt := now.Add(accessTokenTTL)
+
+	// Tokens issued with a refresh token share its record (see issueTokens).
+	handle := payload.credential
+	if handle == "" {
+		handle, err = s.credentials.Store(ctx, payload.username, payload.password, expiresAt)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to store credentials", "error", err)
+			return "", time.Time{}, err
+		}
+	}
+
+	// Encrypt sensitive data with API key before storing in PASETO
+	encryptedCookie, err := apikey.EncryptWithAPIKey(payload.imaluumCookie, payload.apiKey)
 	if err != nil {
-		logger.Error("Failed to encrypt password with API key", "error", err)
-		return "", "", err
+		logger.ErrorContext(ctx, "Failed to encrypt cookie with API key", "error", err)
+		return "", time.Time{}, err
 	}
 
-	token.SetString("imaluumCookie", encryptedCookie)
-	token.SetString("username", encryptedUsername)
-	token.SetString("password", encryptedPassword)
+	encryptedUsername, err := apikey.EncryptWithAPIKey(payload.username, payload.apiKey)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to encrypt username with API key", "error", err)
+		return "", time.Time{}, err
+	}
 
-	signed := token.V4Sign(*s.paseto.PrivateKey, nil)
+	claims := accessClaims{
+		id:                jti,
+		refreshID:         payload.refreshID,
+		credential:        handle,
+		issuedAt:          now,
+		expiresAt:         expiresAt,
+		clientID:          payload.clientID,
+		scopes:            payload.scopes,
+		encryptedUsername: encryptedUsername,
+		encryptedCookie:   encryptedCookie,
+	}
 
-	s.paseto.Token = token
+	token := claims.token()
+	token.SetFooter(s.paseto.Footer())
 
-	return signed, imaluumCookie, nil
+	return token.V4Sign(*s.paseto.PrivateKey, nil), expiresAt, nil
 }
 
 // DecodePasetoToken decodes the given PASETO token and returns the original uia cookie
 func (s *Server) DecodePasetoToken(ctx context.Context, token, userAPIKey string) (*TokenPayload, error) {
+	verified, err := s.verifyPasetoToken(ctx, token, userAPIKey)
+	if err != nil {
+		return nil, err
+	}
+	return s.tokenSession(ctx, verified)
+}
+
+// verifiedToken is an access token whose signature, type, age and revocation
+// have been checked, but whose i-Ma'luum session has not been restored yet.
+type verifiedToken struct {
+	decoded    *paseto.Token
+	username   string
+	apiKey     string
+	tokenID    string
+	refreshID  string
+	credential string
+	issuedAt   time.Time
+	expiresAt  time.Time
+	clientID   string
+	scopes     []string
+}
+
+// verifyPasetoToken does the checks of DecodePasetoToken that never reach
+// i-Ma'luum, so callers can act on who the token belongs to (rate limit them,
+// say) before tokenSession may log in again.
+func (s *Server) verifyPasetoToken(ctx context.Context, token, userAPIKey string) (*verifiedToken, error) {
 	parser := paseto.NewParserWithoutExpiryCheck() // Don't use NewParser() which will checks expiry by default
 	logger := s.log
 
@@ -88,7 +199,15 @@ func (s *Server) DecodePasetoToken(ctx context.Context, token, userAPIKey string
 	// parser.AddRule(paseto.NotExpired())         // this will fail if the token has expired
 	parser.AddRule(paseto.IssuedBy("gomaluum")) // this will fail if the token was not issued by "gomaluum"
 
-	decodedToken, err := parser.ParseV4Public(*s.paseto.PublicKey, token, nil) // this will fail if parsing failes, cryptographic checks fail, or validation rules fail
+	// Pick the keyring key named in the footer, so tokens signed before a key
+	// rotation stay valid until they expire.
+	publicKey, err := s.paseto.VerificationKey(token)
+	if err != nil {
+		logger.WarnContext(ctx, "Failed to find token verification key", "error", err)
+		return nil, err
+	}
+
+	decodedToken, err := parser.ParseV4Public(publicKey, token, nil) // this will fail if parsing failes, cryptographic checks fail, or validation rules fail
 	if err != nil {
 		logger.ErrorContext(ctx, "Failed to parse token", "error", err)
 
@@ -105,7 +224,6 @@ func (s *Server) DecodePasetoToken(ctx context.Context, token, userAPIKey string
 
 	// Get encrypted data from token
 	encryptedUsername, _ := decodedToken.GetString("username")
-	encryptedPassword, _ := decodedToken.GetString("password")
 
 	// Decrypt data using API key
 	username, err := apikey.DecryptWithAPIKey(encryptedUsername, userAPIKey)
@@ -114,72 +232,194 @@ func (s *Server) DecodePasetoToken(ctx context.Context, token, userAPIKey string
 		return nil, err
 	}
 
-	password, err := apikey.DecryptWithAPIKey(encryptedPassword, userAPIKey)
+	typ, _ := decodedToken.GetString("typ")
+	if typ != "" && typ != accessTokenType {
+		logger.WarnContext(ctx, "Token is not an access token", "typ", typ)
+		return nil, errors.ErrInvalidToken
+	}
+
+	// Legacy tokens carry no type and were minted already expired, so they
+	// re-login on every request until they are retired. Access tokens are
+	// rejected once expired; the client uses its refresh token instead.
+	issuedAt, _ := decodedToken.GetIssuedAt()
+	if typ == accessTokenType && today.After(tokenExpiryDate) {
+		logger.DebugContext(ctx, "Access token has expired")
+		return nil, errors.ErrAccessTokenExpired
+	}
+	if typ == "" && s.legacyTokenRetired(issuedAt, today) {
+		logger.WarnContext(ctx, "Retired legacy token presented", "username", username, "issued_at", issuedAt)
+		return nil, errors.ErrLegacyTokenRetired
+	}
+
+	// Checked before any re-login, so a revoked legacy token cannot mint a new
+	// i-Ma'luum session either.
+	tokenID, _ := decodedToken.GetJti()
+	refreshID, _ := decodedToken.GetString("rti")
+	credential, _ := decodedToken.GetString("cred")
+	clientID, scopes := tokenScopes(decodedToken)
+	revoked, err := s.revocations.IsRevoked(ctx, tokenID, username, issuedAt)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check token revocation", "error", err)
+		return nil, err
+	}
+	if revoked {
+		logger.WarnContext(ctx, "Revoked token presented", "username", username)
+		return nil, errors.ErrTokenRevoked
+	}
+
+	return &verifiedToken{
+		decoded:    decodedToken,
+		username:   username,
+		apiKey:     userAPIKey,
+		tokenID:    tokenID,
+		refreshID:  refreshID,
+		credential: credential,
+		issuedAt:   issuedAt,
+		expiresAt:  tokenExpiryDate,
+		clientID:   clientID,
+		scopes:     scopes,
+	}, nil
+}
+
+// tokenSession returns the session behind a verified token, logging into
+// i-Ma'luum again if the token's cookie has expired.
+func (s *Server) tokenSession(ctx context.Context, verified *verifiedToken) (*TokenPayload, error) {
+	logger := s.log
+	username, userAPIKey := verified.username, verified.apiKey
+
+	password, err := s.tokenPassword(ctx, verified.decoded, username, userAPIKey)
 	if err != nil {
-		logger.ErrorContext(ctx, "Failed to decrypt password with API key", "error", err)
 		return nil, err
 	}
 
+	session := &TokenPayload{
+		username:   username,
+		password:   password,
+		apiKey:     userAPIKey,
+		tokenID:    verified.tokenID,
+		refreshID:  verified.refreshID,
+		credential: verified.credential,
+		issuedAt:   verified.issuedAt,
+		expiresAt:  verified.expiresAt,
+		clientID:   verified.clientID,
+		scopes:     verified.scopes,
+	}
+
 	// if the token has expired, we need to regenerate it
-	if today.After(tokenExpiryDate) {
+	if time.Now().After(verified.expiresAt) {
 		logger.DebugContext(ctx, "Token has expired")
 
-		// decode the password
-		decodedPassword, err := base64.StdEncoding.DecodeString(password)
-		if err != nil {
-			logger.ErrorContext(ctx, "Failed to decode password", "error", err)
-			return nil, err
-		}
-
-		refresh := s.loginFunc(ctx, username, string(decodedPassword))
+		refresh := s.loginFunc(ctx, username, password, verified.credential)
 
 		newToken, err := s.tokenManager.GetToken(username, refresh)
-		if err != nil {
+		if err != nil && !isUpstreamFailure(err) {
 			logger.ErrorContext(ctx, "Failed to get token", "error", err)
 			return nil, err
 		}
+		if err != nil {
+			// The token itself is valid; only the i-Ma'luum login is down. Let the
+			// request through without a session so scrapers can serve their last
+			// known-good copy instead of failing authentication.
+			logger.WarnContext(ctx, "Login unavailable, continuing without a session", "error", err)
+		}
 
 		logger.DebugContext(ctx, "Refreshed token", "username", username)
 
 		go s.UpdateAnalytics(username)
 
-		return &TokenPayload{
-			username:      username,
-			password:      string(decodedPassword),
-			imaluumCookie: newToken,
-			apiKey:        userAPIKey,
-		}, nil
+		session.imaluumCookie = newToken
+		return session, nil
 
 		// End of if token expired
 	}
 
 	// If token not expired yet - decrypt the cookie
-	encryptedCookie, _ := decodedToken.GetString("imaluumCookie")
+	encryptedCookie, _ := verified.decoded.GetString("imaluumCookie")
 	imaluumCookie, err := apikey.DecryptWithAPIKey(encryptedCookie, userAPIKey)
 	if err != nil {
 		logger.ErrorContext(ctx, "Failed to decrypt cookie with API key", "error", err)
 		return nil, err
 	}
 
-	plainPassword, err := base64.StdEncoding.DecodeString(password)
+	go s.UpdateAnalytics(username)
+	session.imaluumCookie = imaluumCookie
+	return session, nil
+}
+
+// legacyTokenCutoff reads LEGACY_TOKEN_CUTOFF, a date (2006-01-02) or RFC 3339
+// time before which legacy tokens are refused. Unset, it is the zero time.
+func legacyTokenCutoff() (time.Time, error) {
+	raw := os.Getenv("LEGACY_TOKEN_CUTOFF")
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if cutoff, err := time.ParseInLocation(time.DateOnly, raw, klTimezone); err == nil {
+		return cutoff, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+// legacyTokenRetired reports whether a legacy (untyped) token issued at
+// issuedAt is refused at now. Legacy tokens embed the password and nothing
+// mints them anymore, so they get at most a refresh token's lifetime, and none
+// if issued before s.legacyCutoff.
+func (s *Server) legacyTokenRetired(issuedAt, now time.Time) bool {
+	return issuedAt.Before(s.legacyCutoff) || now.After(issuedAt.Add(refreshTokenTTL))
+}
+
+// tokenScopes returns the app and granted scopes of a token issued to a
+// third-party app, or nil scopes for a first-party token.
+func tokenScopes(decodedToken *paseto.Token) (clientID string, scopes []string) {
+	scope, err := decodedToken.GetString("scope")
+	if err != nil {
+		return "", nil
+	}
+	clientID, _ = decodedToken.GetString("client_id")
+	return clientID, append([]string{}, strings.Fields(scope)...)
+}
+
+// tokenPassword returns the plaintext password behind a decoded access token:
+// from the credential vault for tokens carrying a handle, or from the claims of
+// legacy tokens that embed it. A handle whose record is gone means the client
+// must refresh, exactly like an expired token.
+func (s *Server) tokenPassword(ctx context.Context, decodedToken *paseto.Token, username, userAPIKey string) (string, error) {
+	logger := s.log
+
+	if handle, err := decodedToken.GetString("cred"); err == nil {
+		password, found, err := s.credentials.Password(ctx, handle, username)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to read credentials", "error", err)
+			return "", err
+		}
+		if !found {
+			logger.DebugContext(ctx, "Credentials are gone", "username", username)
+			return "", errors.ErrAccessTokenExpired
+		}
+		return password, nil
+	}
+
+	encryptedPassword, _ := decodedToken.GetString("password")
+	password, err := apikey.DecryptWithAPIKey(encryptedPassword, userAPIKey)
 	if err != nil {
-		logger.ErrorContext(ctx, "Failed to decode password", "error", err)
-		return nil, err
+		logger.ErrorContext(ctx, "Failed to decrypt password with API key", "error", err)
+		return "", err
 	}
 
-	go s.UpdateAnalytics(username)
-	return &TokenPayload{
-		username:      username,
-		password:      string(plainPassword),
-		imaluumCookie: imaluumCookie,
-		apiKey:        userAPIKey,
-	}, nil
+	// decode the password
+	decodedPassword, err := base64.StdEncoding.DecodeString(password)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to decode password", "error", err)
+		return "", err
+	}
+	return string(decodedPassword), nil
 }
 
 // loginFunc returns a TokenManager refresh closure that logs into i-Ma'luum
-// (via the gRPC auth service) and caches the resulting cookie for
-// imaluumSessionTTL. password must be plaintext.
-func (s *Server) loginFunc(ctx context.Context, username, password string) func() (string, time.Time, error) {
+// (via s.auth) and caches the resulting cookie for
+// imaluumSessionTTL. password must be plaintext; it is a stored password, read
+// from the credential vault record credential (see tokenPassword), or from a
+// legacy token when credential is empty.
+func (s *Server) loginFunc(ctx context.Context, username, password, credential string) func() (string, time.Time, error) {
 	return func() (string, time.Time, error) {
 		logger := s.log
 		logger.DebugContext(ctx, "Refreshing session token", "username", username)
@@ -195,14 +435,33 @@ func (s *Server) loginFunc(ctx context.Context, username, password string) func(
 				Token:    constants.DebugUserCookie,
 			}
 		} else {
-			resp, err = s.grpc.client.Login(ctx, &pb.LoginRequest{
+			// A stored password goes stale when the student changes it; don't
+			// let retries with it lock their CAS account, or their own logins.
+			subjects := storedLoginSubjects(username)
+			if s.loginThrottle.reserve(subjects) > 0 {
+				return "", time.Now(), errors.ErrTooManyLoginAttempts
+			}
+			resp, err = s.auth.Login(ctx, &pb.LoginRequest{
 				Username: username,
 				Password: password,
 			})
 			if err != nil {
 				logger.ErrorContext(ctx, "Failed to login", "error", err)
+				if isUpstreamFailure(err) {
+					s.loginThrottle.release(subjects)
+					return "", time.Now(), err
+				}
+				s.recordLoginFailure(ctx, subjects, username, "")
+				if isBadCredentials(err) && credential != "" {
+					// The password is stale: drop it rather than retry it. Tokens
+					// backed by it then ask the client to log in again.
+					if err := s.credentials.Forget(ctx, credential); err != nil {
+						logger.WarnContext(ctx, "Failed to forget stale credentials", "username", username, "error", err)
+					}
+				}
 				return "", time.Now(), err
 			}
+			s.loginThrottle.succeed(subjects)
 		}
 
 		return resp.Token, time.Now().Add(imaluumSessionTTL), nil
@@ -210,8 +469,9 @@ func (s *Server) loginFunc(ctx context.Context, username, password string) func(
 }
 
 // refreshSession evicts the cached session for username and forces a fresh
-// login, returning the new cookie. Used to recover from a stale session.
-func (s *Server) refreshSession(ctx context.Context, username, password string) (string, error) {
+// login with the stored password behind credential, returning the new cookie.
+// Used to recover from a stale session.
+func (s *Server) refreshSession(ctx context.Context, username, password, credential string) (string, error) {
 	s.tokenManager.Invalidate(username)
-	return s.tokenManager.GetToken(username, s.loginFunc(ctx, username, password))
+	return s.tokenManager.GetToken(username, s.loginFunc(ctx, username, password, credential))
 }
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestConcurrentTokenMinting logs many users in at once and checks that every
+// token carries only its own user's claims. Run with -race.
+func TestConcurrentTokenMinting(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+
+	const users = 64
+	type minted struct {
+		payload TokenPayload
+		token   string
+		err     error
+	}
+	results := make([]minted, users)
+
+	var wg sync.WaitGroup
+	for i := range users {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			payload := TokenPayload{
+				username:      fmt.Sprintf("21%05d", i),
+				password:      fmt.Sprintf("password-%d", i),
+				imaluumCookie: fmt.Sprintf("cookie-%d", i),
+				apiKey:        fmt.Sprintf("key-%d", i),
+				refreshID:     fmt.Sprintf("refresh-%d", i),
+			}
+			token, _, err := s.GeneratePasetoToken(ctx, payload)
+			results[i] = minted{payload: payload, token: token, err: err}
+		}()
+	}
+	wg.Wait()
+
+	ids := make(map[string]bool, users)
+	for _, m := range results {
+		require.NoError(t, m.err)
+
+		sess, err := s.DecodePasetoToken(ctx, m.token, m.payload.apiKey)
+		require.NoError(t, err, "token of %s", m.payload.username)
+		require.Equal(t, m.payload.username, sess.username)
+		require.Equal(t, m.payload.password, sess.password)
+		require.Equal(t, m.payload.imaluumCookie, sess.imaluumCookie)
+		require.Equal(t, m.payload.refreshID, sess.refreshID)
+
+		require.False(t, ids[sess.tokenID], "token IDs are unique")
+		ids[sess.tokenID] = true
+	}
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/bytedance/sonic"
@@ -8,12 +9,40 @@ import (
 	"github.com/nrmnqdds/gomaluum/internal/errors"
 )
 
+// fetchProfile scrapes the request user's profile.
+func (s *Server) fetchProfile(ctx context.Context) (*dtos.Profile, error) {
+	var profile *dtos.Profile
+	if err := s.scrapeWithRetry(ctx, func(cookie string) (bool, error) {
+		p, stale, err := s.Profile(ctx, cookie)
+		if err != nil {
+			return false, err
+		}
+		profile = p
+		return stale, nil
+	}); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// basicProfile keeps only the fields covered by the profile:basic scope.
+func basicProfile(p *dtos.Profile) *dtos.Profile {
+	return &dtos.Profile{
+		ImageURL: p.ImageURL,
+		Name:     p.Name,
+		MatricNo: p.MatricNo,
+		Level:    p.Level,
+		Kuliyyah: p.Kuliyyah,
+	}
+}
+
 // @Title ProfileHandler
-// @Description Get i-Ma'luum profile
+// @Description Get i-Ma'luum profile. Served from cache for up to a day unless refresh is set. Apps granted profile:basic but not profile:full only get name, matric number, level, kulliyyah and photo.
 // @Tags scraper
 // @Produce json
 // @Param x-gomaluum-key header string false "API key for additional security layer"
 // @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Param refresh query bool false "Bypass the cache and re-scrape"
 // @Success 200 {object} dtos.ResponseDTO
 // @Router /api/profile [get]
 func (s *Server) ProfileHandler(w http.ResponseWriter, r *http.Request) {
@@ -23,22 +52,19 @@ func (s *Server) ProfileHandler(w http.ResponseWriter, r *http.Request) {
 		logger = s.log
 	)
 
-	var profile *dtos.Profile
-	if err := s.scrapeWithRetry(r.Context(), func(cookie string) (bool, error) {
-		p, stale, err := s.Profile(r.Context(), cookie)
-		if err != nil {
-			return false, err
-		}
-		profile = p
-		return stale, nil
-	}); err != nil {
+	profile, err := cachedResource(r.Context(), s, profileResource, r.URL.Query().Has("refresh"), s.fetchProfile)
+	if err != nil {
 		errors.Render(w, r, err)
 		return
 	}
+	if sess, ok := r.Context().Value(ctxSession).(*TokenPayload); ok && !sess.hasScopes(scopeProfileFull) {
+		profile = basicProfile(profile)
+	}
 
 	response := &dtos.ResponseDTO{
 		Message: "Successfully fetched profile",
 		Data:    profile,
+		Stale:   staleness(r.Context(), w),
 	}
 
 	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
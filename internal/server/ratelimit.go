@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+	"github.com/nrmnqdds/gomaluum/pkg/ratelimit"
+)
+
+// Requests are limited per API key, so one misbehaving client can't get the
+// server's IP blocked by i-Ma'luum (see errors.ErrUpstreamForbidden). Traffic
+// without a key of its own shares the default key, so it is limited per
+// student instead, or per client IP before logging in.
+
+const (
+	// rateLimitDefaultTier limits each student (or IP) using the default key.
+	rateLimitDefaultTier = "default"
+
+	// rateLimitStandardTier limits each generated key, across all its users.
+	rateLimitStandardTier = "standard"
+)
+
+// defaultRateLimitTiers apply unless RATE_LIMIT_TIERS overrides them.
+var defaultRateLimitTiers = map[string]ratelimit.Limit{
+	rateLimitDefaultTier:  {Rate: 1, Burst: 30, Daily: 2000},
+	rateLimitStandardTier: {Rate: 5, Burst: 100, Daily: 50000},
+}
+
+// rateLimitTiers returns the default tiers, overridden and extended by
+// RATE_LIMIT_TIERS: a comma-separated list of
+// name=requests per minute/burst/daily quota, e.g. "partner=600/200/0" (a
+// quota of 0 is unlimited). Keys get a tier other than standard by setting
+// api_keys.tier in the database.
+func rateLimitTiers(spec string) (map[string]ratelimit.Limit, error) {
+	tiers := make(map[string]ratelimit.Limit, len(defaultRateLimitTiers))
+	for name, limit := range defaultRateLimitTiers {
+		tiers[name] = limit
+	}
+	if strings.TrimSpace(spec) == "" {
+		return tiers, nil
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		name, values, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		parts := strings.Split(values, "/")
+		if !ok || name == "" || len(parts) != 3 {
+			return nil, fmt.Errorf("invalid tier %q, want name=per-minute/burst/daily", entry)
+		}
+		var n [3]int
+		for i, part := range parts {
+			v, err := strconv.Atoi(part)
+			if err != nil || v < 0 || (i < 2 && v == 0) {
+				return nil, fmt.Errorf("invalid tier %q, want name=per-minute/burst/daily", entry)
+			}
+			n[i] = v
+		}
+		tiers[name] = ratelimit.Limit{Rate: float64(n[0]) / 60, Burst: n[1], Daily: n[2]}
+	}
+	return tiers, nil
+}
+
+// trustProxyHeaders reads RATE_LIMIT_TRUST_PROXY. Set it when the server runs
+// behind a reverse proxy, so clients are told apart by their forwarded IP
+// rather than the proxy's, and the forwarded scheme is believed.
+func trustProxyHeaders() bool {
+	return os.Getenv("RATE_LIMIT_TRUST_PROXY") == "true"
+}
+
+// clientIP returns the address r came from.
+func (s *Server) clientIP(r *http.Request) string {
+	if s.trustProxy {
+		if ip := r.Header.Get("X-Real-IP"); ip != "" {
+			return ip
+		}
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimitSubject returns whom a request made with use counts against: the
+// key, or for the default key the student, or failing that the client IP.
+func (s *Server) rateLimitSubject(r *http.Request, use apiKeyUse, username string) string {
+	if subject := apiKeySubject(use, username); subject != "" {
+		return subject
+	}
+	return "ip:" + s.clientIP(r)
+}
+
+// apiKeySubject is rateLimitSubject without the client IP fallback: "" for
+// the default key used without a username.
+func apiKeySubject(use apiKeyUse, username string) string {
+	switch {
+	case !use.isDefault() && use.id != "":
+		return "key:" + use.id
+	case !use.isDefault():
+		return "key:" + hashAPIKey(use.key)
+	case username != "":
+		return "user:" + username
+	}
+	return ""
+}
+
+// seconds formats d for Retry-After and RateLimit headers.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// allowRequest takes a request from subject's allowance under tier and sets
+// the RateLimit headers. When the allowance is spent it renders 429 with
+// Retry-After and returns false.
+func (s *Server) allowRequest(w http.ResponseWriter, r *http.Request, subject, tier string) bool {
+	limit, res := s.allow(subject, tier)
+
+	policy := fmt.Sprintf("%d;w=%s", limit.Burst, seconds(limit.Window()))
+	if limit.Daily > 0 {
+		policy += fmt.Sprintf(", %d;w=86400", limit.Daily)
+	}
+	h := w.Header()
+	h.Set("RateLimit-Policy", policy)
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", seconds(res.Reset))
+
+	if res.Allowed {
+		return true
+	}
+
+	h.Set("Retry-After", seconds(res.RetryAfter))
+	s.log.WarnContext(r.Context(), "Rate limit exceeded", "subject", subject, "tier", tier, "quota", res.Quota)
+	if res.Quota {
+		errors.Render(w, r, errors.ErrQuotaExceeded)
+	} else {
+		errors.Render(w, r, errors.ErrRateLimited)
+	}
+	return false
+}
+
+// allow takes a request from subject's allowance under tier.
+func (s *Server) allow(subject, tier string) (ratelimit.Limit, ratelimit.Result) {
+	limit, ok := s.rateLimits[tier]
+	if !ok {
+		limit = s.rateLimits[rateLimitStandardTier]
+	}
+	return limit, s.limiter.Allow(subject, limit)
+}
+
+// RateLimit limits routes that don't authenticate with PasetoAuthenticator,
+// which limits the rest. It also checks the request's API key once for the
+// handler.
+func (s *Server) RateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		use, err := s.requestAPIKeyUse(r)
+		if err != nil {
+			s.log.WarnContext(r.Context(), "API key rejected", "error", err)
+			errors.Render(w, r, err)
+			return
+		}
+		if !s.allowRequest(w, r, s.rateLimitSubject(r, use, ""), use.tier) {
+			return
+		}
+		ctx := context.WithValue(r.Context(), ctxAPIKey, use)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gopaseto "aidanwoods.dev/go-paseto"
+	"github.com/cristalhq/base64"
+	"github.com/go-chi/chi/v5"
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+	"github.com/nrmnqdds/gomaluum/pkg/apikey"
+	"github.com/nrmnqdds/gomaluum/pkg/ratelimit"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRateLimitTiers(t *testing.T) {
+	tiers, err := rateLimitTiers("partner=600/200/0, default=30/10/500")
+	require.NoError(t, err)
+	require.Equal(t, ratelimit.Limit{Rate: 10, Burst: 200}, tiers["partner"])
+	require.Equal(t, ratelimit.Limit{Rate: 0.5, Burst: 10, Daily: 500}, tiers[rateLimitDefaultTier])
+	require.Equal(t, defaultRateLimitTiers[rateLimitStandardTier], tiers[rateLimitStandardTier])
+
+	for _, spec := range []string{"partner", "partner=1/2", "partner=0/1/1", "partner=1/x/1"} {
+		_, err := rateLimitTiers(spec)
+		require.Error(t, err, spec)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	s := newTestServer()
+	s.rateLimits = map[string]ratelimit.Limit{
+		rateLimitDefaultTier:  {Rate: 0.001, Burst: 1},
+		rateLimitStandardTier: {Rate: 0.001, Burst: 2},
+	}
+	ctx := context.Background()
+	key, _, err := s.apiKeys.register(ctx, "", "", rateLimitStandardTier, apiKeyDefaultTTL)
+	require.NoError(t, err)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	public := func(key, ip string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+		r.RemoteAddr = ip + ":1234"
+		if key != "" {
+			r.Header.Set("x-gomaluum-key", key)
+		}
+		w := httptest.NewRecorder()
+		s.RateLimit(ok).ServeHTTP(w, r)
+		return w
+	}
+
+	w := public("", "10.0.0.1")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	w = public("", "10.0.0.1")
+	require.Equal(t, http.StatusTooManyRequests, w.Code, "default-key traffic is limited per IP before login")
+	require.Equal(t, "1000", w.Header().Get("Retry-After"))
+	require.Equal(t, http.StatusOK, public("", "10.0.0.2").Code)
+
+	require.Equal(t, http.StatusOK, public(key, "10.0.0.1").Code)
+	require.Equal(t, http.StatusOK, public(key, "10.0.0.2").Code)
+	require.Equal(t, http.StatusTooManyRequests, public(key, "10.0.0.3").Code, "keys are limited across all their users")
+
+	authenticated := func(username string) int {
+		tokens, err := s.issueTokens(ctx, TokenPayload{username: username, password: "p@ss", imaluumCookie: "cookie", apiKey: apikey.DefaultAPIKey})
+		require.NoError(t, err)
+		r := httptest.NewRequest(http.MethodGet, "/api/profile", nil)
+		r.RemoteAddr = "10.0.0.9:1234"
+		r.Header.Set("Authorization", "Bearer "+tokens.Token)
+		w := httptest.NewRecorder()
+		s.PasetoAuthenticator()(ok).ServeHTTP(w, r)
+		return w.Code
+	}
+	require.Equal(t, http.StatusOK, authenticated("2110000"))
+	require.Equal(t, http.StatusTooManyRequests, authenticated("2110000"))
+	require.Equal(t, http.StatusOK, authenticated("2110001"), "default-key traffic is limited per student once logged in")
+
+	// A limited request never reaches i-Ma'luum: expired legacy tokens log in
+	// again on every request, so the limit applies before that.
+	fake := &fakeAuthenticator{login: func(context.Context) error { return status.Error(codes.Unavailable, "down") }}
+	s.auth = fake
+	encrypt := func(v string) string {
+		encrypted, err := apikey.EncryptWithAPIKey(v, apikey.DefaultAPIKey)
+		require.NoError(t, err)
+		return encrypted
+	}
+	legacy := gopaseto.NewToken()
+	legacy.SetIssuer("gomaluum")
+	legacy.SetIssuedAt(time.Now())
+	legacy.SetExpiration(time.Now())
+	legacy.SetString("username", encrypt("2110002"))
+	legacy.SetString("password", encrypt(base64.StdEncoding.EncodeToString([]byte("p@ss"))))
+	expired := func() int {
+		r := httptest.NewRequest(http.MethodGet, "/api/profile", nil)
+		r.Header.Set("Authorization", "Bearer "+legacy.V4Sign(*s.paseto.PrivateKey, nil))
+		w := httptest.NewRecorder()
+		s.PasetoAuthenticator()(ok).ServeHTTP(w, r)
+		return w.Code
+	}
+	require.Equal(t, http.StatusOK, expired())
+	require.Equal(t, 1, fake.calls)
+	require.Equal(t, http.StatusTooManyRequests, expired())
+	require.Equal(t, 1, fake.calls, "no login once limited")
+
+	// Nor does a feed poll, which logs in with the stored password on a miss.
+	fake.calls = 0
+	feedToken, _, err := s.generateFeedToken(ctx, "2110003", "p@ss")
+	require.NoError(t, err)
+	feed := func() int {
+		r := httptest.NewRequest(http.MethodGet, "/api/feed/"+feedToken+".ics", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("token", feedToken+".ics")
+		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		s.FeedHandler(w, r)
+		return w.Code
+	}
+	require.NotEqual(t, http.StatusTooManyRequests, feed())
+	require.Equal(t, 1, fake.calls)
+	require.Equal(t, http.StatusTooManyRequests, feed(), "feeds are limited per student")
+	require.Equal(t, 1, fake.calls)
+
+	// Nor does a free-slots lookup, which counts against the member, not the
+	// caller.
+	fake.calls = 0
+	fake.login = func(context.Context) error { return errors.ErrLoginFailed }
+	legacy.SetString("username", encrypt("2110004"))
+	member := dtos.FreeSlotsMember{Token: legacy.V4Sign(*s.paseto.PrivateKey, nil)}
+	_, err = s.memberSchedule(ctx, member)
+	require.Equal(t, errors.ErrInvalidGroupMemberToken, err)
+	require.Equal(t, 1, fake.calls)
+	_, err = s.memberSchedule(ctx, member)
+	require.Equal(t, errors.ErrRateLimited, err)
+	require.Equal(t, 1, fake.calls)
+}
@@ -0,0 +1,279 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"aidanwoods.dev/go-paseto"
+	"github.com/bytedance/sonic"
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+	"github.com/nrmnqdds/gomaluum/pkg/apikey"
+)
+
+const (
+	// refreshTokenType is the "typ" claim of refresh tokens, so they cannot be
+	// used as bearer tokens or feed tokens and vice versa.
+	refreshTokenType = "refresh"
+
+	// refreshTokenTTL is how long a client can stay logged in without sending
+	// the password again. Each refresh rotates the token.
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// refreshClaims is what a refresh token carries.
+type refreshClaims struct {
+	id         string
+	username   string
+	credential string // credential vault handle
+	issuedAt   time.Time
+	expiresAt  time.Time
+	clientID   string
+	scopes     []string // nil for first-party tokens; carried over on refresh
+}
+
+// generateRefreshToken mints an encrypted refresh token for the credentials
+// and granted scopes in payload. Like access tokens, it carries no password:
+// the password is sealed in s.credentials for the token's lifetime and the
+// token holds the handle, encrypted with the user's API key so the token is
+// useless with another key. The password must be plaintext.
+func (s *Server) generateRefreshToken(ctx context.Context, payload TokenPayload) (string, *refreshClaims, error) {
+	id, err := newTokenID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	claims := &refreshClaims{
+		id:        id,
+		username:  payload.username,
+		issuedAt:  now,
+		expiresAt: now.Add(refreshTokenTTL),
+		clientID:  payload.clientID,
+		scopes:    payload.scopes,
+	}
+
+	claims.credential, err = s.credentials.Store(ctx, payload.username, payload.password, claims.expiresAt)
+	if err != nil {
+		return "", nil, err
+	}
+	encryptedCredential, err := apikey.EncryptWithAPIKey(claims.credential, payload.apiKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token := paseto.NewToken()
+	token.SetIssuer("gomaluum")
+	token.SetIssuedAt(now)
+	token.SetNotBefore(now)
+	token.SetExpiration(claims.expiresAt)
+	token.SetJti(claims.id)
+	token.SetSubject(claims.username)
+	token.SetString("typ", refreshTokenType)
+	token.SetString("cred", encryptedCredential)
+	if claims.scopes != nil {
+		token.SetString("client_id", claims.clientID)
+		token.SetString("scope", strings.Join(claims.scopes, " "))
+	}
+
+	return token.V4Encrypt(*s.paseto.LocalKey, nil), claims, nil
+}
+
+// parseRefreshToken decrypts and validates a refresh token presented with
+// userAPIKey. It does not consult the revocation list.
+func (s *Server) parseRefreshToken(token, userAPIKey string) (*refreshClaims, error) {
+	parser := paseto.NewParser() // checks expiry and not-before
+	parser.AddRule(paseto.IssuedBy("gomaluum"))
+
+	decoded, err := parser.ParseV4Local(*s.paseto.LocalKey, token, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ, err := decoded.GetString("typ"); err != nil || typ != refreshTokenType {
+		return nil, errors.ErrInvalidRefreshToken
+	}
+
+	id, err := decoded.GetJti()
+	if err != nil {
+		return nil, err
+	}
+	username, err := decoded.GetSubject()
+	if err != nil {
+		return nil, err
+	}
+	encryptedCredential, err := decoded.GetString("cred")
+	if err != nil {
+		return nil, err
+	}
+	credential, err := apikey.DecryptWithAPIKey(encryptedCredential, userAPIKey)
+	if err != nil {
+		return nil, err
+	}
+	issuedAt, err := decoded.GetIssuedAt()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt, err := decoded.GetExpiration()
+	if err != nil {
+		return nil, err
+	}
+
+	clientID, scopes := tokenScopes(decoded)
+
+	return &refreshClaims{
+		id:         id,
+		username:   username,
+		credential: credential,
+		issuedAt:   issuedAt,
+		expiresAt:  expiresAt,
+		clientID:   clientID,
+		scopes:     scopes,
+	}, nil
+}
+
+// issueTokens mints an access token and a refresh token for payload. The
+// access token carries the refresh token's ID and shares its vaulted
+// credentials, so logging out revokes both and forgets the password.
+func (s *Server) issueTokens(ctx context.Context, payload TokenPayload) (*dtos.AuthTokens, error) {
+	refreshToken, claims, err := s.generateRefreshToken(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	payload.refreshID = claims.id
+	payload.credential = claims.credential
+	accessToken, expiresAt, err := s.GeneratePasetoToken(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	return &dtos.AuthTokens{
+		Token:            accessToken,
+		Username:         payload.username,
+		ExpiresAt:        expiresAt.Unix(),
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: claims.expiresAt.Unix(),
+		Scope:            strings.Join(payload.scopes, " "),
+	}, nil
+}
+
+// @Title RefreshTokenHandler
+// @Description Exchange a refresh token for a new access token and refresh token. This is the only call that re-authenticates against i-Ma'luum; the old refresh token is revoked.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param x-gomaluum-key header string false "API key the tokens were issued with"
+// @Param request body dtos.RefreshTokenRequest true "Refresh token from login or a previous refresh"
+// @Success 200 {object} dtos.ResponseDTO{data=dtos.AuthTokens}
+// @Failure 401 {object} errors.CustomError "Invalid, expired or revoked refresh token"
+// @Router /api/auth/refresh [post]
+func (s *Server) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx := r.Context()
+	logger := s.log
+
+	var req dtos.RefreshTokenRequest
+	if err := sonic.ConfigFastest.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		logger.ErrorContext(ctx, "Failed to decode request body", "error", err)
+		errors.Render(w, r, errors.ErrInvalidRequest)
+		return
+	}
+
+	// Get API key from header and check it against the registry
+	userAPIKey, err := s.requestAPIKey(r)
+	if err != nil {
+		logger.WarnContext(ctx, "API key rejected in refresh", "error", err)
+		errors.Render(w, r, err)
+		return
+	}
+
+	claims, err := s.parseRefreshToken(req.RefreshToken, userAPIKey)
+	if err != nil {
+		logger.WarnContext(ctx, "Invalid refresh token", "error", err)
+		errors.Render(w, r, errors.ErrInvalidRefreshToken)
+		return
+	}
+
+	revoked, err := s.revocations.IsRevoked(ctx, claims.id, claims.username, claims.issuedAt)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check refresh token revocation", "error", err)
+		errors.Render(w, r, err)
+		return
+	}
+	if revoked {
+		logger.WarnContext(ctx, "Revoked refresh token presented", "username", claims.username)
+		errors.Render(w, r, errors.ErrInvalidRefreshToken)
+		return
+	}
+
+	// An app's grant ends with the app.
+	if claims.scopes != nil {
+		if _, found, err := s.oauthClients.Get(ctx, claims.clientID); err != nil || !found {
+			logger.WarnContext(ctx, "Refresh token of an unknown app", "client_id", claims.clientID, "error", err)
+			errors.Render(w, r, errors.ErrInvalidRefreshToken)
+			return
+		}
+	}
+
+	// Rotate: the presented refresh token is spent. Spend it before logging in
+	// and issuing a new pair so a failed revocation cannot leave two live
+	// refresh tokens, and so only one of concurrent replays reaches CAS. A
+	// failed login below does not give the token back; the client logs in.
+	spent, err := s.revocations.Spend(ctx, claims.id, claims.username, claims.expiresAt)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to revoke used refresh token", "error", err)
+		errors.Render(w, r, err)
+		return
+	}
+	if !spent {
+		logger.WarnContext(ctx, "Revoked refresh token presented", "username", claims.username)
+		errors.Render(w, r, errors.ErrInvalidRefreshToken)
+		return
+	}
+
+	password, found, err := s.credentials.Password(ctx, claims.credential, claims.username)
+	if err != nil || !found {
+		logger.WarnContext(ctx, "Refresh token credentials are gone", "username", claims.username, "error", err)
+		errors.Render(w, r, errors.ErrInvalidRefreshToken)
+		return
+	}
+
+	cookie, err := s.refreshSession(ctx, claims.username, password, claims.credential)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to log in for refresh", "error", err)
+		s.renderLoginError(w, r, err)
+		return
+	}
+
+	tokens, err := s.issueTokens(ctx, TokenPayload{
+		username:      claims.username,
+		password:      password,
+		imaluumCookie: cookie,
+		apiKey:        userAPIKey,
+		clientID:      claims.clientID,
+		scopes:        claims.scopes,
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to generate PASETO token", "error", err)
+		errors.Render(w, r, errors.ErrFailedToGeneratePASETO)
+		return
+	}
+	// The new pair has its own record; the spent token's is now unreachable.
+	if err := s.credentials.Forget(ctx, claims.credential); err != nil {
+		logger.WarnContext(ctx, "Failed to forget spent refresh token credentials", "error", err)
+	}
+
+	go s.UpdateAnalytics(claims.username)
+
+	response := &dtos.ResponseDTO{
+		Message: "Successfully refreshed token",
+		Data:    tokens,
+	}
+
+	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
+		logger.ErrorContext(ctx, "Failed to encode response", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+	}
+}
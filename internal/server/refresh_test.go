@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	gopaseto "aidanwoods.dev/go-paseto"
+	"github.com/nrmnqdds/gomaluum/internal/constants"
+	"github.com/nrmnqdds/gomaluum/pkg/apikey"
+	"github.com/nrmnqdds/gomaluum/pkg/paseto"
+	"github.com/nrmnqdds/gomaluum/pkg/ratelimit"
+	"github.com/nrmnqdds/gomaluum/pkg/sf"
+	"github.com/nrmnqdds/gomaluum/pkg/vault"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestServer() *Server {
+	credentials, err := newCredentialVault(make([]byte, vault.KeySize), nil)
+	if err != nil {
+		panic(err)
+	}
+	return &Server{
+		log:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+		paseto:        paseto.NewKeyring(gopaseto.NewV4AsymmetricSecretKey(), gopaseto.NewV4SymmetricKey()),
+		revocations:   newTokenRevocations(nil),
+		credentials:   credentials,
+		oauthClients:  newOAuthClientStore(nil),
+		apiKeys:       newAPIKeyRegistry(nil, false),
+		limiter:       ratelimit.New(),
+		rateLimits:    defaultRateLimitTiers,
+		loginThrottle: newLoginThrottle(),
+		tokenManager:  sf.NewTokenManager(),
+		cache:         newMemoryResourceCache(8),
+	}
+}
+
+func TestIssueTokens(t *testing.T) {
+	s := newTestServer()
+	payload := TokenPayload{username: "2110000", password: "p@ss", imaluumCookie: "cookie", apiKey: "key"}
+
+	tokens, err := s.issueTokens(context.Background(), payload)
+	require.NoError(t, err)
+	require.Equal(t, "2110000", tokens.Username)
+	require.WithinDuration(t, time.Now().Add(accessTokenTTL), time.Unix(tokens.ExpiresAt, 0), time.Second)
+	require.Greater(t, tokens.RefreshExpiresAt, tokens.ExpiresAt)
+
+	// A live access token is served from its claims: no re-login (s.auth is
+	// nil, so one would panic).
+	sess, err := s.DecodePasetoToken(context.Background(), tokens.Token, "key")
+	require.NoError(t, err)
+	require.Equal(t, "cookie", sess.imaluumCookie)
+	require.Equal(t, "p@ss", sess.password)
+
+	claims, err := s.parseRefreshToken(tokens.RefreshToken, "key")
+	require.NoError(t, err)
+	require.Equal(t, "2110000", claims.username)
+	require.Equal(t, sess.credential, claims.credential, "the pair shares one vault record")
+	password, found, err := s.credentials.Password(context.Background(), claims.credential, "2110000")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "p@ss", password)
+
+	// Like the access token, the refresh token carries no password.
+	raw, err := gopaseto.NewParser().ParseV4Local(*s.paseto.LocalKey, tokens.RefreshToken, nil)
+	require.NoError(t, err)
+	_, err = raw.GetString("password")
+	require.Error(t, err)
+
+	_, err = s.parseRefreshToken(tokens.RefreshToken, "other-key")
+	require.Error(t, err, "refresh token is bound to the API key")
+
+	// Token types do not cross over.
+	_, err = s.parseRefreshToken(tokens.Token, "key")
+	require.Error(t, err)
+	_, err = s.DecodePasetoToken(context.Background(), tokens.RefreshToken, "key")
+	require.Error(t, err)
+	feed, _, err := s.generateFeedToken(context.Background(), "2110000", "p@ss")
+	require.NoError(t, err)
+	_, err = s.parseRefreshToken(feed, "key")
+	require.Error(t, err)
+}
+
+func TestRefreshTokenIsSingleUse(t *testing.T) {
+	s := newTestServer()
+	payload := TokenPayload{username: constants.DebugUsername, password: constants.DebugPassword, imaluumCookie: constants.DebugUserCookie, apiKey: apikey.DefaultAPIKey}
+
+	tokens, err := s.issueTokens(context.Background(), payload)
+	require.NoError(t, err)
+
+	refresh := func() int {
+		return oauthCall(t, s.RefreshTokenHandler, http.MethodPost, "/api/auth/refresh", `{"refresh_token":"`+tokens.RefreshToken+`"}`, nil, nil)
+	}
+	require.Equal(t, http.StatusOK, refresh())
+	require.Equal(t, http.StatusUnauthorized, refresh(), "a replayed refresh token does not rotate again")
+
+	// Concurrent replays are refused before they reach CAS, and a failed
+	// login doesn't give the token back.
+	fake := &fakeAuthenticator{login: func(context.Context) error { return status.Error(codes.Unavailable, "down") }}
+	s.auth = fake
+	tokens, err = s.issueTokens(context.Background(), TokenPayload{username: "2110000", password: "p@ss", imaluumCookie: "cookie", apiKey: apikey.DefaultAPIKey})
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() { refresh() })
+	}
+	wg.Wait()
+	require.Equal(t, 1, fake.calls)
+	require.Equal(t, http.StatusUnauthorized, refresh())
+	require.Equal(t, 1, fake.calls)
+}
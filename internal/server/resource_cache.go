@@ -0,0 +1,371 @@
+package server
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/nrmnqdds/gomaluum/internal/constants"
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/nrmnqdds/gomaluum/pkg/apikey"
+)
+
+// Resource kinds cached by cachedResource. Each has its own TTL: how long a
+// scrape may be served before i-Ma'luum is asked again.
+const (
+	profileResource      = "profile"
+	starpointResource    = "starpoint"
+	disciplinaryResource = "disciplinary"
+	carryMarkResource    = "carry-mark"
+	finalExamResource    = "final-exam"
+	schedulesResource    = "schedules"
+)
+
+var resourceTTLs = map[string]time.Duration{
+	profileResource:      24 * time.Hour,
+	starpointResource:    6 * time.Hour,
+	disciplinaryResource: 6 * time.Hour,
+	carryMarkResource:    30 * time.Minute,
+	finalExamResource:    6 * time.Hour,
+	// Schedules and results have their own GEI cache (see resolveSchedules);
+	// here they are kept only as the fallback for outages.
+	schedulesResource: 0,
+	resultsResource:   0,
+}
+
+// memoryCacheCapacity bounds the in-memory LRU, in entries (user x resource).
+const memoryCacheCapacity = 4096
+
+// resourceCache stores scraped resources keyed by user and resource kind.
+// Payloads are opaque (already encrypted by cachedResource). Get returns
+// entries past their expiry, for up to staleRetention, so a failed scrape can
+// fall back to the last known-good copy; callers check expiresAt.
+type resourceCache interface {
+	Get(ctx context.Context, username, resource string) (entry cacheEntry, found bool, err error)
+	Set(ctx context.Context, username, resource string, entry cacheEntry) error
+	Delete(ctx context.Context, username, resource string) error
+}
+
+type cacheEntry struct {
+	payload   string
+	storedAt  time.Time
+	expiresAt time.Time
+}
+
+// newResourceCache returns the backend named by backend ("memory", "postgres"
+// or "gei"). When backend is empty it picks Postgres if a database is
+// configured, then GEI, then the in-memory LRU. An unknown or unconfigured
+// backend falls back to the in-memory LRU.
+func newResourceCache(backend string, db *sql.DB, indexer *scheduleIndexer) resourceCache {
+	if backend == "" {
+		switch {
+		case db != nil:
+			backend = "postgres"
+		case indexer != nil:
+			backend = "gei"
+		}
+	}
+
+	switch backend {
+	case "postgres":
+		if db != nil {
+			return &postgresResourceCache{db: db}
+		}
+	case "gei":
+		if indexer != nil {
+			return &geiResourceCache{indexer: indexer}
+		}
+	case "", "memory":
+		return newMemoryResourceCache(memoryCacheCapacity)
+	}
+	log.Printf("RESOURCE_CACHE %q is unknown or not configured, using in-memory cache", backend)
+	return newMemoryResourceCache(memoryCacheCapacity)
+}
+
+// cachedResource returns the request user's resource from s.cache while it is
+// fresh, and otherwise calls scrape and caches a successful result for the
+// resource's TTL. refresh skips the read but still refreshes the cache. A zero
+// TTL caches for fallback only.
+//
+// When the scrape fails because i-Ma'luum is down or blocking us, the last
+// known-good copy is served instead: the request is marked stale (see
+// staleness) and a background refresh is triggered.
+//
+// Cached values are encrypted with the user's API key, like the PASETO claims,
+// so a cache dump is useless without the key. Sessions without a key (feeds)
+// bypass the cache rather than re-seal the user's entries under another one.
+// Cache failures are logged and never fail the request.
+func cachedResource[T any](ctx context.Context, s *Server, resource string, refresh bool, scrape func(context.Context) (T, error)) (T, error) {
+	sess, ok := ctx.Value(ctxSession).(*TokenPayload)
+	if s.cache == nil || !ok || sess == nil || sess.username == "" || sess.apiKey == "" || sess.imaluumCookie == constants.DebugUserCookie {
+		return scrape(ctx)
+	}
+
+	var (
+		cached T
+		entry  cacheEntry
+		found  bool
+		loaded bool
+		ttl    = resourceTTLs[resource]
+	)
+	if !refresh && ttl > 0 {
+		var err error
+		entry, found, err = loadResource(ctx, s.cache, sess, resource, &cached)
+		if err != nil {
+			s.log.WarnContext(ctx, "Failed to read resource cache", "resource", resource, "error", err)
+		}
+		if found && time.Now().Before(entry.expiresAt) {
+			return cached, nil
+		}
+		loaded = err == nil
+	}
+
+	value, err := scrape(ctx)
+	if err != nil {
+		if !isUpstreamFailure(err) {
+			return value, err
+		}
+		if !loaded {
+			var cacheErr error
+			entry, found, cacheErr = loadResource(ctx, s.cache, sess, resource, &cached)
+			if cacheErr != nil {
+				s.log.WarnContext(ctx, "Failed to read resource cache", "resource", resource, "error", cacheErr)
+			}
+		}
+		if !found {
+			return value, err
+		}
+
+		s.log.WarnContext(ctx, "i-Ma'luum unavailable, serving last known-good copy",
+			"resource", resource, "stored_at", entry.storedAt, "error", err)
+		markStale(ctx, entry.storedAt)
+		revalidateResource(ctx, s, sess, resource, scrape)
+		return cached, nil
+	}
+
+	if err := storeResource(ctx, s.cache, sess, resource, value, ttl); err != nil {
+		s.log.WarnContext(ctx, "Failed to write resource cache", "resource", resource, "error", err)
+	}
+	return value, nil
+}
+
+// loadResource reads and decrypts a cached resource into v. An entry that
+// does not decrypt (the user rotated their API key) counts as a miss.
+func loadResource(ctx context.Context, cache resourceCache, sess *TokenPayload, resource string, v any) (cacheEntry, bool, error) {
+	entry, found, err := cache.Get(ctx, sess.username, resource)
+	if err != nil || !found {
+		return cacheEntry{}, false, err
+	}
+	plaintext, err := apikey.DecryptWithAPIKey(entry.payload, sess.apiKey)
+	if err != nil {
+		return cacheEntry{}, false, nil
+	}
+	if err := sonic.ConfigFastest.UnmarshalFromString(plaintext, v); err != nil {
+		return cacheEntry{}, false, err
+	}
+	return entry, true, nil
+}
+
+// storeResource encrypts v with the user's API key and caches it for ttl.
+func storeResource(ctx context.Context, cache resourceCache, sess *TokenPayload, resource string, v any, ttl time.Duration) error {
+	plaintext, err := sonic.ConfigFastest.MarshalToString(v)
+	if err != nil {
+		return err
+	}
+	payload, err := apikey.EncryptWithAPIKey(plaintext, sess.apiKey)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return cache.Set(ctx, sess.username, resource, cacheEntry{
+		payload:   payload,
+		storedAt:  now,
+		expiresAt: now.Add(ttl),
+	})
+}
+
+// purgeUserData drops everything cached for username: every resource in
+// s.cache plus GEI's copies of their schedules and results. GEI has no delete
+// RPC, so those are overwritten with empty values, which read as a miss.
+func (s *Server) purgeUserData(ctx context.Context, username string) error {
+	var errs []error
+	for resource := range resourceTTLs {
+		if err := s.cache.Delete(ctx, username, resource); err != nil {
+			errs = append(errs, fmt.Errorf("deleting cached %s: %w", resource, err))
+		}
+	}
+	if s.indexer != nil {
+		if err := s.indexer.StoreSchedule(ctx, username, []dtos.ScheduleResponse{}); err != nil {
+			errs = append(errs, fmt.Errorf("clearing GEI schedule: %w", err))
+		}
+		if err := s.indexer.StoreResource(ctx, username, resultsResource, []dtos.ResultResponse{}); err != nil {
+			errs = append(errs, fmt.Errorf("clearing GEI results: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// memoryResourceCache is a per-instance LRU. It does not survive a restart.
+type memoryResourceCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // front is most recently used
+	entries  map[string]*list.Element
+}
+
+type memoryCacheEntry struct {
+	key string
+	cacheEntry
+}
+
+func newMemoryResourceCache(capacity int) *memoryResourceCache {
+	return &memoryResourceCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (m *memoryResourceCache) Get(_ context.Context, username, resource string) (cacheEntry, bool, error) {
+	key := username + "\x00" + resource
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.entries[key]
+	if !ok {
+		return cacheEntry{}, false, nil
+	}
+	entry := el.Value.(*memoryCacheEntry)
+	if time.Now().After(entry.expiresAt.Add(staleRetention)) {
+		m.order.Remove(el)
+		delete(m.entries, key)
+		return cacheEntry{}, false, nil
+	}
+	m.order.MoveToFront(el)
+	return entry.cacheEntry, true, nil
+}
+
+func (m *memoryResourceCache) Set(_ context.Context, username, resource string, entry cacheEntry) error {
+	key := username + "\x00" + resource
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.entries[key]; ok {
+		el.Value.(*memoryCacheEntry).cacheEntry = entry
+		m.order.MoveToFront(el)
+		return nil
+	}
+
+	m.entries[key] = m.order.PushFront(&memoryCacheEntry{key: key, cacheEntry: entry})
+	for m.order.Len() > m.capacity {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryCacheEntry).key)
+	}
+	return nil
+}
+
+func (m *memoryResourceCache) Delete(_ context.Context, username, resource string) error {
+	key := username + "\x00" + resource
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.entries[key]; ok {
+		m.order.Remove(el)
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+type postgresResourceCache struct {
+	db *sql.DB
+}
+
+func (p *postgresResourceCache) Get(ctx context.Context, username, resource string) (cacheEntry, bool, error) {
+	var entry cacheEntry
+	err := p.db.QueryRowContext(ctx, `
+			SELECT payload, updated_at, expires_at FROM resource_cache
+			WHERE username = $1 AND resource = $2 AND expires_at > $3
+		`, username, resource, time.Now().Add(-staleRetention)).Scan(&entry.payload, &entry.storedAt, &entry.expiresAt)
+	if err == sql.ErrNoRows {
+		return cacheEntry{}, false, nil
+	}
+	if err != nil {
+		return cacheEntry{}, false, err
+	}
+	return entry, true, nil
+}
+
+func (p *postgresResourceCache) Set(ctx context.Context, username, resource string, entry cacheEntry) error {
+	if _, err := p.db.ExecContext(ctx, `DELETE FROM resource_cache WHERE expires_at < $1`, time.Now().Add(-staleRetention)); err != nil {
+		return err
+	}
+	_, err := p.db.ExecContext(ctx, `
+			INSERT INTO resource_cache (username, resource, payload, expires_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (username, resource) DO UPDATE
+			SET payload = EXCLUDED.payload, expires_at = EXCLUDED.expires_at, updated_at = EXCLUDED.updated_at
+		`, username, resource, entry.payload, entry.expiresAt, entry.storedAt)
+	return err
+}
+
+func (p *postgresResourceCache) Delete(ctx context.Context, username, resource string) error {
+	_, err := p.db.ExecContext(ctx, `DELETE FROM resource_cache WHERE username = $1 AND resource = $2`, username, resource)
+	return err
+}
+
+// geiResourceCache stores entries through GEI's resource RPCs. GEI has no
+// notion of expiry, so the timestamps travel with the payload.
+type geiResourceCache struct {
+	indexer *scheduleIndexer
+}
+
+type geiCacheEntry struct {
+	Payload   string `json:"payload"`
+	StoredAt  int64  `json:"stored_at"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// geiCachePrefix keeps cache entries apart from the resources GEI stores in
+// the clear (e.g. resultsResource).
+const geiCachePrefix = "cache:"
+
+func (g *geiResourceCache) Get(ctx context.Context, username, resource string) (cacheEntry, bool, error) {
+	var stored geiCacheEntry
+	found, err := g.indexer.GetResource(ctx, username, geiCachePrefix+resource, &stored)
+	if err != nil || !found {
+		return cacheEntry{}, false, err
+	}
+	entry := cacheEntry{
+		payload:   stored.Payload,
+		storedAt:  time.Unix(stored.StoredAt, 0),
+		expiresAt: time.Unix(stored.ExpiresAt, 0),
+	}
+	if time.Now().After(entry.expiresAt.Add(staleRetention)) {
+		return cacheEntry{}, false, nil
+	}
+	return entry, true, nil
+}
+
+func (g *geiResourceCache) Set(ctx context.Context, username, resource string, entry cacheEntry) error {
+	return g.indexer.StoreResource(ctx, username, geiCachePrefix+resource, geiCacheEntry{
+		Payload:   entry.payload,
+		StoredAt:  entry.storedAt.Unix(),
+		ExpiresAt: entry.expiresAt.Unix(),
+	})
+}
+
+// Delete overwrites the entry with an empty one that has long expired, since
+// GEI cannot delete.
+func (g *geiResourceCache) Delete(ctx context.Context, username, resource string) error {
+	return g.indexer.StoreResource(ctx, username, geiCachePrefix+resource, geiCacheEntry{})
+}
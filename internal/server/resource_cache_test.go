@@ -0,0 +1,177 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	apperrors "github.com/nrmnqdds/gomaluum/internal/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryResourceCache(t *testing.T) {
+	ctx := context.Background()
+	cache := newMemoryResourceCache(2)
+	fresh := cacheEntry{storedAt: time.Now(), expiresAt: time.Now().Add(time.Hour)}
+
+	set := func(username, resource, payload string, entry cacheEntry) {
+		entry.payload = payload
+		require.NoError(t, cache.Set(ctx, username, resource, entry))
+	}
+	set("a", profileResource, "1", fresh)
+	set("b", profileResource, "2", fresh)
+
+	// Touch a so b is the least recently used, then overflow.
+	_, found, _ := cache.Get(ctx, "a", profileResource)
+	require.True(t, found)
+	set("c", profileResource, "3", fresh)
+
+	_, found, _ = cache.Get(ctx, "b", profileResource)
+	require.False(t, found, "least recently used entry is evicted")
+	entry, found, _ := cache.Get(ctx, "a", profileResource)
+	require.True(t, found)
+	require.Equal(t, "1", entry.payload)
+
+	set("a", starpointResource, "x", cacheEntry{expiresAt: time.Now().Add(-time.Hour)})
+	entry, found, _ = cache.Get(ctx, "a", starpointResource)
+	require.True(t, found, "expired entry is kept as last known-good")
+	require.True(t, time.Now().After(entry.expiresAt))
+
+	set("a", starpointResource, "x", cacheEntry{expiresAt: time.Now().Add(-staleRetention - time.Hour)})
+	_, found, _ = cache.Get(ctx, "a", starpointResource)
+	require.False(t, found, "entry past the retention is dropped")
+}
+
+func TestCachedResource(t *testing.T) {
+	cache := newMemoryResourceCache(8)
+	s := &Server{log: slog.New(slog.NewTextHandler(io.Discard, nil)), cache: cache}
+	sess := &TokenPayload{username: "2110000", imaluumCookie: "cookie", apiKey: "key-one"}
+	ctx := context.WithValue(context.Background(), ctxSession, sess)
+
+	scrapes := 0
+	scrape := func(context.Context) (*dtos.Profile, error) {
+		scrapes++
+		return &dtos.Profile{Name: "ALI BIN ABU", MatricNo: "2110000"}, nil
+	}
+
+	p, err := cachedResource(ctx, s, profileResource, false, scrape)
+	require.NoError(t, err)
+	require.Equal(t, "ALI BIN ABU", p.Name)
+	require.Equal(t, 1, scrapes)
+
+	p, err = cachedResource(ctx, s, profileResource, false, scrape)
+	require.NoError(t, err)
+	require.Equal(t, "ALI BIN ABU", p.Name)
+	require.Equal(t, 1, scrapes, "second call is served from cache")
+
+	entry, found, err := cache.Get(ctx, "2110000", profileResource)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.False(t, strings.Contains(entry.payload, "ALI BIN ABU"), "payload is encrypted")
+
+	_, err = cachedResource(ctx, s, profileResource, true, scrape)
+	require.NoError(t, err)
+	require.Equal(t, 2, scrapes, "refresh bypasses the cache")
+
+	// A different API key cannot read the entry: treated as a miss.
+	sess.apiKey = "key-two"
+	_, err = cachedResource(ctx, s, profileResource, false, scrape)
+	require.NoError(t, err)
+	require.Equal(t, 3, scrapes)
+}
+
+func TestCachedResourceFeedSession(t *testing.T) {
+	cache := newMemoryResourceCache(8)
+	s := &Server{log: slog.New(slog.NewTextHandler(io.Discard, nil)), cache: cache}
+	student := context.WithValue(context.Background(), ctxSession, &TokenPayload{username: "2110000", imaluumCookie: "cookie", apiKey: "key-one"})
+	// FeedHandler's session: same student, no API key.
+	feed := context.WithValue(context.Background(), ctxSession, &TokenPayload{username: "2110000", imaluumCookie: "cookie"})
+
+	scrapes := 0
+	scrape := func(context.Context) ([]dtos.FinalExamItem, error) {
+		scrapes++
+		return []dtos.FinalExamItem{{SubjectCode: "INFO4335"}}, nil
+	}
+
+	_, err := cachedResource(student, s, finalExamResource, false, scrape)
+	require.NoError(t, err)
+	stored, found, err := cache.Get(student, "2110000", finalExamResource)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	// Feed polls scrape without touching the student's entry...
+	for range 2 {
+		exams, err := cachedResource(feed, s, finalExamResource, false, scrape)
+		require.NoError(t, err)
+		require.Equal(t, "INFO4335", exams[0].SubjectCode)
+	}
+	require.Equal(t, 3, scrapes)
+	entry, found, err := cache.Get(student, "2110000", finalExamResource)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, stored.payload, entry.payload, "not re-sealed under another key")
+
+	// ...so the student's next request is still a hit.
+	_, err = cachedResource(student, s, finalExamResource, false, scrape)
+	require.NoError(t, err)
+	require.Equal(t, 3, scrapes)
+}
+
+func TestCachedResourceStaleFallback(t *testing.T) {
+	s := &Server{log: slog.New(slog.NewTextHandler(io.Discard, nil)), cache: newMemoryResourceCache(8)}
+	sess := &TokenPayload{username: "2110000", imaluumCookie: "cookie", apiKey: "key"}
+	ctx := withFreshness(context.WithValue(context.Background(), ctxSession, sess))
+
+	good := []dtos.ScheduleResponse{{SessionName: "2025/2026 Semester 1"}}
+	_, err := cachedResource(ctx, s, schedulesResource, false, func(context.Context) ([]dtos.ScheduleResponse, error) {
+		return good, nil
+	})
+	require.NoError(t, err)
+	require.Nil(t, staleness(ctx, httptest.NewRecorder()), "fresh scrape is not stale")
+
+	// Not an outage: the error is the answer.
+	_, err = cachedResource(ctx, s, schedulesResource, false, func(context.Context) ([]dtos.ScheduleResponse, error) {
+		return nil, apperrors.ErrScheduleIsEmpty
+	})
+	require.Equal(t, apperrors.ErrScheduleIsEmpty, err)
+
+	revalidated := make(chan struct{})
+	var calls atomic.Int32
+	blocked := func(context.Context) ([]dtos.ScheduleResponse, error) {
+		if calls.Add(1) == 2 {
+			defer close(revalidated)
+		}
+		return nil, classifyVisitError(errors.New(http.StatusText(http.StatusForbidden)))
+	}
+
+	got, err := cachedResource(ctx, s, schedulesResource, false, blocked)
+	require.NoError(t, err)
+	require.Equal(t, good, got)
+
+	w := httptest.NewRecorder()
+	st := staleness(ctx, w)
+	require.NotNil(t, st)
+	require.NotZero(t, st.CachedAt)
+	require.Equal(t, `110 - "Response is Stale"`, w.Header().Get("Warning"))
+	require.NotEmpty(t, w.Header().Get("Age"))
+
+	select {
+	case <-revalidated:
+	case <-time.After(time.Second):
+		t.Fatal("no background refresh after a stale fallback")
+	}
+
+	// A second fallback within revalidateInterval does not refresh again.
+	_, err = cachedResource(ctx, s, schedulesResource, false, blocked)
+	require.NoError(t, err)
+	s.revalidations.Wait()
+	require.Equal(t, int32(3), calls.Load())
+}
@@ -2,7 +2,6 @@ package server
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 	"slices"
 	"sort"
@@ -13,7 +12,6 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/bytedance/sonic"
 	"github.com/gocolly/colly/v2"
-	"github.com/lucsky/cuid"
 	"github.com/nrmnqdds/gomaluum/internal/constants"
 	"github.com/nrmnqdds/gomaluum/internal/dtos"
 	"github.com/nrmnqdds/gomaluum/internal/errors"
@@ -44,8 +42,9 @@ type resultWorkerResult struct {
 	err    error
 }
 
-// Parse result table row with object pooling
-func parseResultRow(tds []string, subjects *[]dtos.Result, gpaInfo *map[string]string, mu *sync.Mutex) {
+// Parse result table row with object pooling. session is the row's session
+// query, which scopes the result's stable ID.
+func parseResultRow(session string, tds []string, subjects *[]dtos.Result, gpaInfo *map[string]string, mu *sync.Mutex) {
 	if len(tds) < 4 {
 		return
 	}
@@ -87,7 +86,7 @@ func parseResultRow(tds []string, subjects *[]dtos.Result, gpaInfo *map[string]s
 	result := resultPool.Get().(*dtos.Result)
 	*result = dtos.Result{} // Reset
 
-	result.ID = fmt.Sprintf("gomaluum:subject:%s", cuid.Slug())
+	result.ID = utils.StableID("subject", session, courseCode)
 	result.CourseCode = courseCode
 	result.CourseName = courseName
 	result.CourseGrade = courseGrade
@@ -132,7 +131,7 @@ func (s *Server) resultWorker(ctx context.Context, jobs <-chan resultJob, result
 					tds = append(tds, s.Text())
 				})
 
-				parseResultRow(tds, &subjects, &gpaInfo, &mu)
+				parseResultRow(job.query, tds, &subjects, &gpaInfo, &mu)
 				resultStringSlicePool.Put(tds)
 			})
 
@@ -145,7 +144,7 @@ func (s *Server) resultWorker(ctx context.Context, jobs <-chan resultJob, result
 			}
 
 			response := dtos.ResultResponse{
-				ID:           fmt.Sprintf("gomaluum:result:%s", cuid.Slug()),
+				ID:           utils.StableID("result", job.query),
 				SessionName:  job.name,
 				SessionQuery: job.query,
 				GpaValue:     gpaInfo["gpa"],
@@ -206,30 +205,176 @@ func (s *Server) processResultsWithWorkerPool(ctx context.Context, queries, name
 	return resultResponses, nil
 }
 
+// resolveResults returns the results for the given sessions with the same
+// cache-aside strategy as resolveSchedules: past semesters' results do not
+// change, so on a cache hit only the latest session and any session missing
+// from the cache are scraped. It never writes the cache.
+func (s *Server) resolveResults(ctx context.Context, username string, queries, names []string, cookie string, stale *atomic.Bool, refresh bool) ([]dtos.ResultResponse, error) {
+	if s.indexer == nil || refresh || username == "" {
+		return s.processResultsWithWorkerPool(ctx, queries, names, cookie, stale)
+	}
+
+	var cached []dtos.ResultResponse
+	found, err := s.indexer.GetResource(ctx, username, resultsResource, &cached)
+	if err != nil {
+		// A cache read failure must not break the request: fall back to a scrape.
+		s.log.WarnContext(ctx, "GEI GetResource failed, scraping all results", "error", err)
+	}
+	if err != nil || !found || len(cached) == 0 {
+		return s.processResultsWithWorkerPool(ctx, queries, names, cookie, stale)
+	}
+
+	cachedByQuery := make(map[string]dtos.ResultResponse, len(cached))
+	for _, c := range cached {
+		cachedByQuery[c.SessionQuery] = c
+	}
+
+	// Re-scrape the latest session (grades may still be released) plus anything
+	// not yet cached.
+	latest := latestSessionQuery(queries, names)
+	var scrapeQueries, scrapeNames []string
+	for i, q := range queries {
+		if _, ok := cachedByQuery[q]; q == latest || !ok {
+			scrapeQueries = append(scrapeQueries, q)
+			scrapeNames = append(scrapeNames, names[i])
+		}
+	}
+
+	scraped, err := s.processResultsWithWorkerPool(ctx, scrapeQueries, scrapeNames, cookie, stale)
+	if err != nil {
+		return nil, err
+	}
+	return mergeResults(queries, scraped, cachedByQuery), nil
+}
+
+// mergeResults assembles results in the dropdown order of queries, preferring
+// a fresh scrape over the cache. Cached sessions no longer offered by
+// i-Ma'luum are dropped.
+func mergeResults(queries []string, scraped []dtos.ResultResponse, cachedByQuery map[string]dtos.ResultResponse) []dtos.ResultResponse {
+	scrapedByQuery := make(map[string]dtos.ResultResponse, len(scraped))
+	for _, sc := range scraped {
+		scrapedByQuery[sc.SessionQuery] = sc
+	}
+
+	merged := make([]dtos.ResultResponse, 0, len(queries))
+	for _, q := range queries {
+		if sc, ok := scrapedByQuery[q]; ok {
+			merged = append(merged, sc)
+		} else if c, ok := cachedByQuery[q]; ok {
+			merged = append(merged, c)
+		}
+	}
+	return merged
+}
+
+// fetchResults returns every session's result for the request's user, newest
+// session first. Past sessions come from the GEI cache (see resolveResults)
+// unless refresh is set, and the last known-good copy is served when i-Ma'luum
+// is unavailable (see cachedResource).
+func (s *Server) fetchResults(ctx context.Context, refresh bool) ([]dtos.ResultResponse, error) {
+	return cachedResource(ctx, s, resultsResource, refresh, func(ctx context.Context) ([]dtos.ResultResponse, error) {
+		return s.scrapeResults(ctx, refresh)
+	})
+}
+
+// scrapeResults is fetchResults without the fallback. It refreshes the GEI
+// cache after a successful scrape.
+func (s *Server) scrapeResults(ctx context.Context, refresh bool) ([]dtos.ResultResponse, error) {
+	var (
+		logger         = s.log
+		sessionQueries []string
+		sessionNames   []string
+		results        []dtos.ResultResponse
+	)
+
+	// username keys the GEI result cache.
+	username := requestUsername(ctx)
+
+	if err := s.scrapeWithRetry(ctx, func(cookie string) (bool, error) {
+		var stale atomic.Bool
+		sessionQueries = sessionQueries[:0]
+		sessionNames = sessionNames[:0]
+
+		c := s.newImaluumCollector(ctx, cookie, &stale)
+		c.OnHTML(".box.box-primary .box-header.with-border .dropdown ul.dropdown-menu", func(e *colly.HTMLElement) {
+			sessionQueries = e.ChildAttrs("li[style*='font-size:16px'] a", "href")
+			sessionNames = e.ChildTexts("li[style*='font-size:16px'] a")
+		})
+		if err := c.Visit(constants.ImaluumResultPage); err != nil {
+			return false, classifyVisitError(err)
+		}
+		if stale.Load() {
+			return true, nil
+		}
+
+		filteredQueries := make([]string, 0, len(sessionQueries))
+		filteredNames := make([]string, 0, len(sessionNames))
+		for i := range sessionQueries {
+			if !slices.Contains(UnwantedSessionQueries[:], sessionQueries[i]) {
+				filteredQueries = append(filteredQueries, sessionQueries[i])
+				filteredNames = append(filteredNames, sessionNames[i])
+			}
+		}
+		if len(filteredQueries) == 0 {
+			logger.ErrorContext(ctx, "No valid sessions found")
+			return false, errors.ErrResultIsEmpty
+		}
+
+		result, err := s.resolveResults(ctx, username, filteredQueries, filteredNames, cookie, &stale, refresh)
+		if err != nil {
+			return false, err
+		}
+		if stale.Load() {
+			return true, nil
+		}
+		results = result
+		return false, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 {
+		return nil, errors.ErrResultIsEmpty
+	}
+
+	// Sort results
+	sort.Slice(results, func(i, j int) bool {
+		return utils.SortSessionNames(results[i].SessionName, results[j].SessionName)
+	})
+
+	// Refresh the cache only after a successful (non-stale) scrape, so the login
+	// page can never poison it.
+	if s.indexer != nil && username != "" {
+		if err := s.indexer.StoreResource(ctx, username, resultsResource, results); err != nil {
+			logger.WarnContext(ctx, "Failed to cache results in GEI", "error", err)
+		}
+	}
+
+	return results, nil
+}
+
 // @Title ResultHandler
-// @Description Get result from i-Ma'luum
+// @Description Get result from i-Ma'luum. Past semesters are served from the GEI cache when available; only the latest session is re-scraped.
 // @Tags scraper
 // @Produce json
 // @Param x-gomaluum-key header string false "API key for additional security layer"
 // @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Param refresh query bool false "Bypass the result cache and re-scrape every session"
 // @Success 200 {object} dtos.ResponseDTO
 // @Router /api/result [get]
 func (s *Server) ResultHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var (
-		logger         = s.log
-		cookie         = r.Context().Value(ctxToken).(string)
-		sessionQueries []string
-		sessionNames   []string
-		results        []dtos.ResultResponse
+		logger  = s.log
+		cookie  = r.Context().Value(ctxToken).(string)
+		refresh = r.URL.Query().Has("refresh")
 	)
 
 	// Return fake data for fake user
 	if cookie == constants.DebugUserCookie {
 		fakeResults := []dtos.ResultResponse{
 			{
-				ID:           fmt.Sprintf("gomaluum:result:%s", cuid.Slug()),
 				SessionName:  "2024/2025 Semester 1",
 				SessionQuery: "?ses=2024/2025&sem=1",
 				GpaValue:     "3.67",
@@ -238,35 +383,30 @@ func (s *Server) ResultHandler(w http.ResponseWriter, r *http.Request) {
 				Status:       "Active",
 				Result: []dtos.Result{
 					{
-						ID:           fmt.Sprintf("gomaluum:subject:%s", cuid.Slug()),
 						CourseCode:   "INFO4335",
 						CourseName:   "Software Engineering",
 						CourseGrade:  "A",
 						CourseCredit: "3",
 					},
 					{
-						ID:           fmt.Sprintf("gomaluum:subject:%s", cuid.Slug()),
 						CourseCode:   "INFO4327",
 						CourseName:   "Database Systems",
 						CourseGrade:  "A-",
 						CourseCredit: "3",
 					},
 					{
-						ID:           fmt.Sprintf("gomaluum:subject:%s", cuid.Slug()),
 						CourseCode:   "INFO4501",
 						CourseName:   "Web Development",
 						CourseGrade:  "B+",
 						CourseCredit: "4",
 					},
 					{
-						ID:           fmt.Sprintf("gomaluum:subject:%s", cuid.Slug()),
 						CourseCode:   "INFO4210",
 						CourseName:   "Mobile Application Development",
 						CourseGrade:  "A",
 						CourseCredit: "3",
 					},
 					{
-						ID:           fmt.Sprintf("gomaluum:subject:%s", cuid.Slug()),
 						CourseCode:   "UNGS2040",
 						CourseName:   "Tamadun Islam dan Tamadun Asia (TITAS)",
 						CourseGrade:  "B+",
@@ -275,7 +415,6 @@ func (s *Server) ResultHandler(w http.ResponseWriter, r *http.Request) {
 				},
 			},
 			{
-				ID:           fmt.Sprintf("gomaluum:result:%s", cuid.Slug()),
 				SessionName:  "2023/2024 Semester 2",
 				SessionQuery: "?ses=2023/2024&sem=2",
 				GpaValue:     "3.67",
@@ -284,28 +423,24 @@ func (s *Server) ResultHandler(w http.ResponseWriter, r *http.Request) {
 				Status:       "Active",
 				Result: []dtos.Result{
 					{
-						ID:           fmt.Sprintf("gomaluum:subject:%s", cuid.Slug()),
 						CourseCode:   "INFO3202",
 						CourseName:   "Data Structures and Algorithms",
 						CourseGrade:  "A",
 						CourseCredit: "3",
 					},
 					{
-						ID:           fmt.Sprintf("gomaluum:subject:%s", cuid.Slug()),
 						CourseCode:   "INFO3150",
 						CourseName:   "Computer Networks",
 						CourseGrade:  "B+",
 						CourseCredit: "3",
 					},
 					{
-						ID:           fmt.Sprintf("gomaluum:subject:%s", cuid.Slug()),
 						CourseCode:   "INFO3240",
 						CourseName:   "Operating Systems",
 						CourseGrade:  "A-",
 						CourseCredit: "3",
 					},
 					{
-						ID:           fmt.Sprintf("gomaluum:subject:%s", cuid.Slug()),
 						CourseCode:   "INFO3301",
 						CourseName:   "Human Computer Interaction",
 						CourseGrade:  "B+",
@@ -314,6 +449,12 @@ func (s *Server) ResultHandler(w http.ResponseWriter, r *http.Request) {
 				},
 			},
 		}
+		for i := range fakeResults {
+			fakeResults[i].ID = utils.StableID("result", fakeResults[i].SessionQuery)
+			for j := range fakeResults[i].Result {
+				fakeResults[i].Result[j].ID = utils.StableID("subject", fakeResults[i].SessionQuery, fakeResults[i].Result[j].CourseCode)
+			}
+		}
 
 		response := &dtos.ResponseDTO{
 			Message: "Successfully fetched results",
@@ -327,65 +468,21 @@ func (s *Server) ResultHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.scrapeWithRetry(r.Context(), func(cookie string) (bool, error) {
-		var stale atomic.Bool
-		sessionQueries = sessionQueries[:0]
-		sessionNames = sessionNames[:0]
-
-		c := s.newImaluumCollector(r.Context(), cookie, &stale)
-		c.OnHTML(".box.box-primary .box-header.with-border .dropdown ul.dropdown-menu", func(e *colly.HTMLElement) {
-			sessionQueries = e.ChildAttrs("li[style*='font-size:16px'] a", "href")
-			sessionNames = e.ChildTexts("li[style*='font-size:16px'] a")
-		})
-		if err := c.Visit(constants.ImaluumResultPage); err != nil {
-			return false, classifyVisitError(err)
-		}
-		if stale.Load() {
-			return true, nil
-		}
-
-		filteredQueries := make([]string, 0, len(sessionQueries))
-		filteredNames := make([]string, 0, len(sessionNames))
-		for i := range sessionQueries {
-			if !slices.Contains(UnwantedSessionQueries[:], sessionQueries[i]) {
-				filteredQueries = append(filteredQueries, sessionQueries[i])
-				filteredNames = append(filteredNames, sessionNames[i])
-			}
-		}
-		if len(filteredQueries) == 0 {
-			logger.ErrorContext(r.Context(), "No valid sessions found")
-			return false, errors.ErrResultIsEmpty
-		}
-
-		result, err := s.processResultsWithWorkerPool(r.Context(), filteredQueries, filteredNames, cookie, &stale)
-		if err != nil {
-			return false, err
-		}
-		if stale.Load() {
-			return true, nil
+	results, err := s.fetchResults(r.Context(), refresh)
+	if err != nil {
+		if err == errors.ErrResultIsEmpty {
+			logger.ErrorContext(r.Context(), "Result is empty")
+		} else {
+			logger.ErrorContext(r.Context(), "Failed to scrape results", "error", err)
 		}
-		results = result
-		return false, nil
-	}); err != nil {
-		logger.ErrorContext(r.Context(), "Failed to scrape results", "error", err)
 		errors.Render(w, r, err)
 		return
 	}
 
-	if len(results) == 0 {
-		logger.ErrorContext(r.Context(), "Result is empty")
-		errors.Render(w, r, errors.ErrResultIsEmpty)
-		return
-	}
-
-	// Sort results
-	sort.Slice(results, func(i, j int) bool {
-		return utils.SortSessionNames(results[i].SessionName, results[j].SessionName)
-	})
-
 	response := &dtos.ResponseDTO{
 		Message: "Successfully fetched results",
 		Data:    results,
+		Stale:   staleness(r.Context(), w),
 	}
 
 	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	gei "github.com/nrmnqdds/gomaluum/internal/proto/gei"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMergeResults(t *testing.T) {
+	queries := []string{"?ses=2025/2026&sem=1", "?ses=2024/2025&sem=2", "?ses=2024/2025&sem=1"}
+	scraped := []dtos.ResultResponse{{SessionQuery: queries[0], GpaValue: "3.90"}}
+	cached := map[string]dtos.ResultResponse{
+		queries[0]:             {SessionQuery: queries[0], GpaValue: "0"},
+		queries[1]:             {SessionQuery: queries[1], GpaValue: "3.50"},
+		queries[2]:             {SessionQuery: queries[2], GpaValue: "3.20"},
+		"?ses=2023/2024&sem=3": {SessionQuery: "?ses=2023/2024&sem=3"},
+	}
+
+	merged := mergeResults(queries, scraped, cached)
+
+	require.Len(t, merged, 3)
+	require.Equal(t, "3.90", merged[0].GpaValue, "fresh scrape wins over cache")
+	require.Equal(t, "3.50", merged[1].GpaValue)
+	require.Equal(t, "3.20", merged[2].GpaValue)
+}
+
+// resourceClient is an in-memory GEI that only implements the resource RPCs,
+// or none of them when unimplemented is set.
+type resourceClient struct {
+	gei.ScheduleIndexerClient
+	unimplemented bool
+	payloads      map[string]string
+}
+
+func (c *resourceClient) StoreResource(_ context.Context, in *gei.StoreResourceRequest, _ ...grpc.CallOption) (*gei.StoreResourceResponse, error) {
+	if c.unimplemented {
+		return nil, status.Error(codes.Unimplemented, "method StoreResource not implemented")
+	}
+	c.payloads[in.GetUsername()+"/"+in.GetResource()] = in.GetPayloadJson()
+	return &gei.StoreResourceResponse{Success: true}, nil
+}
+
+func (c *resourceClient) GetResource(_ context.Context, in *gei.GetResourceRequest, _ ...grpc.CallOption) (*gei.GetResourceResponse, error) {
+	if c.unimplemented {
+		return nil, status.Error(codes.Unimplemented, "method GetResource not implemented")
+	}
+	payload, ok := c.payloads[in.GetUsername()+"/"+in.GetResource()]
+	return &gei.GetResourceResponse{Success: ok, PayloadJson: payload}, nil
+}
+
+func TestIndexerResources(t *testing.T) {
+	ctx := context.Background()
+	indexer := &scheduleIndexer{client: &resourceClient{payloads: make(map[string]string)}}
+
+	var results []dtos.ResultResponse
+	found, err := indexer.GetResource(ctx, "2210000", resultsResource, &results)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	stored := []dtos.ResultResponse{{SessionQuery: "?ses=2024/2025&sem=1", GpaValue: "3.67"}}
+	require.NoError(t, indexer.StoreResource(ctx, "2210000", resultsResource, stored))
+
+	found, err = indexer.GetResource(ctx, "2210000", resultsResource, &results)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, stored, results)
+
+	// An older GEI without the resource RPCs is a cache miss, not an error.
+	old := &scheduleIndexer{client: &resourceClient{unimplemented: true}}
+	found, err = old.GetResource(ctx, "2210000", resultsResource, &results)
+	require.NoError(t, err)
+	require.False(t, found)
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// tokenRevocations is the deny-list of revoked tokens. A token is revoked when
+// its ID (the PASETO jti claim) is on the list, or when it was issued before
+// its user's last "log out everywhere" (RevokeAll).
+//
+// Listed IDs only need to outlive the token they revoke, so each carries the
+// token's expiry and is pruned after it. The per-user cutoff is one row per
+// user and is kept.
+//
+// Single-use tokens (authorization and refresh tokens) are spent with Spend,
+// which lists the ID and reports whether this call was the one to list it, so
+// two concurrent uses cannot both succeed.
+type tokenRevocations interface {
+	Revoke(ctx context.Context, jti, username string, expiresAt time.Time) error
+	Spend(ctx context.Context, jti, username string, expiresAt time.Time) (spent bool, err error)
+	RevokeAll(ctx context.Context, username string, before time.Time) error
+	IsRevoked(ctx context.Context, jti, username string, issuedAt time.Time) (bool, error)
+}
+
+// newTokenRevocations returns a Postgres-backed deny-list when a database is
+// configured, otherwise an in-memory one. The in-memory list is per instance
+// and does not survive a restart.
+func newTokenRevocations(db *sql.DB) tokenRevocations {
+	if db != nil {
+		return &postgresRevocations{db: db}
+	}
+	return &memoryRevocations{
+		revoked:       make(map[string]time.Time),
+		revokedBefore: make(map[string]time.Time),
+	}
+}
+
+// revocationCutoff truncates a RevokeAll cutoff to the precision of the iat
+// claim (whole seconds). Tokens issued within the cutoff's second count as
+// issued before it: revoking one too many is safe, missing one is not.
+func revocationCutoff(before time.Time) time.Time {
+	return before.Truncate(time.Second)
+}
+
+type memoryRevocations struct {
+	mu            sync.RWMutex
+	revoked       map[string]time.Time
+	revokedBefore map[string]time.Time
+}
+
+func (m *memoryRevocations) Revoke(ctx context.Context, jti, username string, expiresAt time.Time) error {
+	_, err := m.Spend(ctx, jti, username, expiresAt)
+	return err
+}
+
+func (m *memoryRevocations) Spend(_ context.Context, jti, _ string, expiresAt time.Time) (bool, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, exp := range m.revoked {
+		if now.After(exp) {
+			delete(m.revoked, id)
+		}
+	}
+	if _, ok := m.revoked[jti]; ok {
+		return false, nil
+	}
+	m.revoked[jti] = expiresAt
+	return true, nil
+}
+
+func (m *memoryRevocations) RevokeAll(_ context.Context, username string, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.revokedBefore[username] = revocationCutoff(before)
+	return nil
+}
+
+func (m *memoryRevocations) IsRevoked(_ context.Context, jti, username string, issuedAt time.Time) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.revoked[jti]; ok && jti != "" {
+		return true, nil
+	}
+	cutoff, ok := m.revokedBefore[username]
+	return ok && !issuedAt.After(cutoff), nil
+}
+
+type postgresRevocations struct {
+	db *sql.DB
+}
+
+func (p *postgresRevocations) Revoke(ctx context.Context, jti, username string, expiresAt time.Time) error {
+	_, err := p.Spend(ctx, jti, username, expiresAt)
+	return err
+}
+
+func (p *postgresRevocations) Spend(ctx context.Context, jti, username string, expiresAt time.Time) (bool, error) {
+	if _, err := p.db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at < CURRENT_TIMESTAMP`); err != nil {
+		return false, err
+	}
+	res, err := p.db.ExecContext(ctx, `
+			INSERT INTO revoked_tokens (jti, username, expires_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (jti) DO NOTHING
+		`, jti, username, expiresAt)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := res.RowsAffected()
+	return inserted == 1, err
+}
+
+func (p *postgresRevocations) RevokeAll(ctx context.Context, username string, before time.Time) error {
+	_, err := p.db.ExecContext(ctx, `
+			INSERT INTO revoked_sessions (username, revoked_before)
+			VALUES ($1, $2)
+			ON CONFLICT (username) DO UPDATE SET revoked_before = EXCLUDED.revoked_before
+		`, username, revocationCutoff(before))
+	return err
+}
+
+func (p *postgresRevocations) IsRevoked(ctx context.Context, jti, username string, issuedAt time.Time) (bool, error) {
+	var revoked bool
+	err := p.db.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1 AND jti <> '')
+				OR EXISTS (SELECT 1 FROM revoked_sessions WHERE username = $2 AND revoked_before >= $3)
+		`, jti, username, issuedAt).Scan(&revoked)
+	return revoked, err
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRevocations(t *testing.T) {
+	ctx := context.Background()
+	r := newTokenRevocations(nil)
+	now := time.Now()
+
+	revoked, err := r.IsRevoked(ctx, "", "2110000", time.Time{})
+	require.NoError(t, err)
+	require.False(t, revoked, "an ID-less token is not revoked by an empty jti")
+
+	require.NoError(t, r.Revoke(ctx, "jti-1", "2110000", now.Add(time.Hour)))
+	revoked, _ = r.IsRevoked(ctx, "jti-1", "2110000", now)
+	require.True(t, revoked)
+	revoked, _ = r.IsRevoked(ctx, "jti-2", "2110000", now)
+	require.False(t, revoked)
+
+	require.NoError(t, r.RevokeAll(ctx, "2110000", now))
+	revoked, _ = r.IsRevoked(ctx, "jti-2", "2110000", now.Add(-time.Minute))
+	require.True(t, revoked, "issued before the cutoff")
+	revoked, _ = r.IsRevoked(ctx, "jti-2", "2110000", now.Truncate(time.Second))
+	require.True(t, revoked, "issued within the cutoff's second")
+	revoked, _ = r.IsRevoked(ctx, "jti-2", "2110000", now.Add(2*time.Second))
+	require.False(t, revoked, "issued after the cutoff")
+	revoked, _ = r.IsRevoked(ctx, "jti-2", "2110001", now.Add(-time.Minute))
+	require.False(t, revoked, "other users are unaffected")
+}
+
+func TestSpendIsSingleUse(t *testing.T) {
+	ctx := context.Background()
+	r := newTokenRevocations(nil)
+	expiresAt := time.Now().Add(time.Hour)
+
+	var wg sync.WaitGroup
+	var spent atomic.Int32
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := r.Spend(ctx, "code-1", "2110000", expiresAt)
+			require.NoError(t, err)
+			if ok {
+				spent.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(1), spent.Load(), "exactly one concurrent use spends the token")
+
+	revoked, err := r.IsRevoked(ctx, "code-1", "2110000", time.Now())
+	require.NoError(t, err)
+	require.True(t, revoked)
+}
+
+func TestRevokeSession(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+	payload := TokenPayload{username: "2110000", password: "p@ss", imaluumCookie: "cookie", apiKey: "key"}
+
+	tokens, err := s.issueTokens(context.Background(), payload)
+	require.NoError(t, err)
+	sess, err := s.DecodePasetoToken(ctx, tokens.Token, "key")
+	require.NoError(t, err)
+	refresh, err := s.parseRefreshToken(tokens.RefreshToken, "key")
+	require.NoError(t, err)
+	require.Equal(t, refresh.id, sess.refreshID, "access token is linked to its refresh token")
+
+	require.NoError(t, s.revokeSession(ctx, sess))
+	_, err = s.DecodePasetoToken(ctx, tokens.Token, "key")
+	require.Equal(t, errors.ErrTokenRevoked, err)
+	revoked, err := s.revocations.IsRevoked(ctx, refresh.id, refresh.username, refresh.issuedAt)
+	require.NoError(t, err)
+	require.True(t, revoked, "logout also revokes the refresh token")
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	s := newTestServer()
+	payload := TokenPayload{username: "2110000", password: "p@ss", imaluumCookie: "cookie", apiKey: "key"}
+	sctx := context.WithValue(context.Background(), ctxSession, &payload)
+
+	tokens, err := s.issueTokens(context.Background(), payload)
+	require.NoError(t, err)
+	feed, _, err := s.generateFeedToken(context.Background(), "2110000", "p@ss")
+	require.NoError(t, err)
+	_, err = cachedResource(sctx, s, profileResource, false, func(context.Context) (string, error) { return "cached", nil })
+	require.NoError(t, err)
+
+	require.NoError(t, s.revokeAllSessions(sctx, "2110000"))
+
+	_, err = s.DecodePasetoToken(sctx, tokens.Token, "key")
+	require.Equal(t, errors.ErrTokenRevoked, err)
+	refresh, err := s.parseRefreshToken(tokens.RefreshToken, "key")
+	require.NoError(t, err)
+	revoked, err := s.revocations.IsRevoked(sctx, refresh.id, refresh.username, refresh.issuedAt)
+	require.NoError(t, err)
+	require.True(t, revoked, "refresh token is revoked")
+	feedClaims, err := s.parseFeedToken(feed)
+	require.NoError(t, err)
+	revoked, err = s.revocations.IsRevoked(sctx, feedClaims.id, feedClaims.username, feedClaims.issuedAt)
+	require.NoError(t, err)
+	require.True(t, revoked, "feed token is revoked")
+
+	_, found, err := s.cache.Get(sctx, "2110000", profileResource)
+	require.NoError(t, err)
+	require.False(t, found, "cached data is purged")
+}
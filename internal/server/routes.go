@@ -42,6 +42,7 @@ func (s *Server) RegisterRoutes() http.Handler {
 		AllowedOrigins:   []string{"https://*", "http://*"},
 		AllowedMethods:   []string{"GET", "POST"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "x-gomaluum-key"},
+		ExposedHeaders:   []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"},
 		AllowCredentials: true,
 		// MaxAge:           300,
 	}))
@@ -66,40 +67,119 @@ func (s *Server) RegisterRoutes() http.Handler {
 		r.Get("/analytics", s.GetAnalyticsSummaryHandler)
 
 		// Backward compatibility
-		r.Post("/login", s.LoginHandler)
+		r.With(s.RateLimit).Post("/login", s.LoginHandler)
 
 		// Auth routes
 		r.Route("/auth", func(r chi.Router) {
-			r.Post("/login", s.LoginHandler)
+			r.With(s.RateLimit).Post("/login", s.LoginHandler)
+			r.With(s.RateLimit).Post("/refresh", s.RefreshTokenHandler)
+			r.Get("/keys", s.PublicKeysHandler)
 			r.Group(func(r chi.Router) {
 				// Check for PASETO token in Authorization header
 				r.Use(s.PasetoAuthenticator())
 				r.Get("/logout", s.LogoutHandler)
 			})
+			r.Group(func(r chi.Router) {
+				r.Use(s.PasetoAuthenticator(scopeAccount))
+				r.Post("/logout-all", s.LogoutAllHandler)
+			})
+		})
+
+		// Third-party app authorization
+		r.Route("/oauth", func(r chi.Router) {
+			r.With(s.RateLimit).Post("/token", s.OAuthTokenHandler)
+			r.Group(func(r chi.Router) {
+				r.Use(s.PasetoAuthenticator(scopeAccount))
+				r.Post("/clients", s.RegisterOAuthClientHandler)
+				r.Get("/authorize", s.OAuthConsentHandler)
+				r.Post("/authorize", s.AuthorizeHandler)
+			})
 		})
 
 		// API Key routes
 		r.Route("/key", func(r chi.Router) {
-			r.Post("/generate", s.GenerateAPIKeyHandler)
+			r.With(s.RateLimit).Post("/generate", s.GenerateAPIKeyHandler)
+			r.Group(func(r chi.Router) {
+				r.Use(s.PasetoAuthenticator(scopeAccount))
+				r.Get("/", s.ListAPIKeysHandler)
+				r.Post("/{id}/rotate", s.RotateAPIKeyHandler)
+				r.Post("/{id}/revoke", s.RevokeAPIKeyHandler)
+			})
 		})
 
 		r.Get("/ads", s.AdsHandler)
 		r.Get("/academic-calendar", s.AcademicCalendarHandler)
 
-		// All routes in this group require authentication
+		// Calendar subscription feeds authenticate with the token in the URL,
+		// since calendar apps cannot send an Authorization header.
+		r.Get("/feed/{token}", s.FeedHandler)
+
+		// The groups below require authentication. Each requires the scopes
+		// its routes expose; tokens issued to third-party apps only reach the
+		// groups the student granted, first-party tokens reach all of them.
+		// The authenticator also rate limits them, per API key or, for the
+		// default key, per student.
 		r.Group(func(r chi.Router) {
 			// Check for PASETO token in Authorization header
 			r.Use(s.PasetoAuthenticator())
+			r.Get("/logout", s.LogoutHandler)
+		})
 
+		r.Group(func(r chi.Router) {
+			r.Use(s.PasetoAuthenticator(scopeProfileBasic))
 			r.Get("/profile", s.ProfileHandler)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(s.PasetoAuthenticator(scopeScheduleRead))
 			r.Get("/schedule", s.ScheduleHandler)
+			r.Get("/schedule.ics", s.ScheduleICSHandler)
+			r.Get("/schedule/occurrences", s.ScheduleOccurrencesHandler)
+			r.Get("/schedule/now", s.ScheduleNowHandler)
+			r.Post("/schedule/free-slots", s.FreeSlotsHandler)
+			r.Get("/schedule/changes", s.ScheduleChangesHandler)
+		})
+
+		r.Group(func(r chi.Router) {
+			// Feed tokens are long-lived and read the schedule and exams
+			// without further checks, so only the student may mint them.
+			r.Use(s.PasetoAuthenticator(scopeAccount))
+			r.Post("/schedule/feed", s.CreateFeedHandler)
+			r.Post("/schedule/feed/revoke", s.RevokeFeedHandler)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(s.PasetoAuthenticator(scopeResultRead))
 			r.Get("/result", s.ResultHandler)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(s.PasetoAuthenticator(scopeStarpointRead))
 			r.Get("/starpoint", s.StarpointHandler)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(s.PasetoAuthenticator(scopeExamRead))
 			r.Get("/exam-timetable", s.FinalExamHandler)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(s.PasetoAuthenticator(scopeScheduleRead, scopeExamRead))
+			r.Get("/conflicts", s.ConflictsHandler)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(s.PasetoAuthenticator(scopeDisciplinaryRead))
 			r.Get("/disciplinary", s.DisciplinaryHandler)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(s.PasetoAuthenticator(scopeCarryMarkRead))
 			r.Get("/carry-mark", s.CarryMarkHandler)
-			r.Get("/logout", s.LogoutHandler)
+		})
 
+		r.Group(func(r chi.Router) {
+			r.Use(s.PasetoAuthenticator(scopeDocumentsRead))
 			r.Route("/download", func(r chi.Router) {
 				r.Get("/exam-slip", s.ExamSlipHandler)
 				r.Get("/study-plan", s.StudyPlanHandler)
@@ -16,7 +16,6 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/bytedance/sonic"
 	"github.com/gocolly/colly/v2"
-	"github.com/lucsky/cuid"
 	"github.com/nrmnqdds/gomaluum/internal/constants"
 	"github.com/nrmnqdds/gomaluum/internal/dtos"
 	"github.com/nrmnqdds/gomaluum/internal/errors"
@@ -108,8 +107,9 @@ func normalizeTime(timeStr string) (string, *int64) {
 	return trimmed, &unixTimestamp
 }
 
-// Parse table row with object pooling
-func parseTableRow(tds []string, subjects *[]dtos.ScheduleSubject, mu *sync.Mutex) {
+// Parse table row with object pooling. session is the row's session query,
+// which scopes the subject's stable ID.
+func parseTableRow(session string, tds []string, subjects *[]dtos.ScheduleSubject, mu *sync.Mutex) {
 	if len(tds) == 0 {
 		return
 	}
@@ -220,9 +220,9 @@ func parseTableRow(tds []string, subjects *[]dtos.ScheduleSubject, mu *sync.Mute
 		// Copy weekTime slice to avoid pool contamination
 		subject.Timestamps = make([]dtos.WeekTime, len(weekTimeSlice))
 		copy(subject.Timestamps, weekTimeSlice)
-		subject.ID = fmt.Sprintf("gomaluum:subject:%s", cuid.Slug())
 
 		mu.Lock()
+		subject.ID = subjectID(session, subject, subjectRow(*subjects, subject))
 		*subjects = append(*subjects, *subject)
 		mu.Unlock()
 
@@ -232,6 +232,30 @@ func parseTableRow(tds []string, subjects *[]dtos.ScheduleSubject, mu *sync.Mute
 	weekTimeSlicePool.Put(weekTimeSlice)
 }
 
+// subjectID derives a schedule subject's stable ID from its session, course,
+// section and row (see subjectRow), so a subject keeps its ID when its time or
+// venue changes, and the rows of a course split across several (one per slot)
+// still get one ID each.
+func subjectID(session string, subject *dtos.ScheduleSubject, row int) string {
+	parts := []string{session, subject.CourseCode, strconv.FormatUint(uint64(subject.Section), 10)}
+	if row > 0 {
+		parts = append(parts, strconv.Itoa(row))
+	}
+	return utils.StableID("subject", parts...)
+}
+
+// subjectRow returns how many rows of subject's course and section precede it
+// in subjects.
+func subjectRow(subjects []dtos.ScheduleSubject, subject *dtos.ScheduleSubject) int {
+	row := 0
+	for _, other := range subjects {
+		if other.CourseCode == subject.CourseCode && other.Section == subject.Section {
+			row++
+		}
+	}
+	return row
+}
+
 // Worker function for processing schedule sessions
 func (s *Server) scheduleWorker(ctx context.Context, jobs <-chan scheduleJob, results chan<- scheduleResult, cookie string, stale *atomic.Bool) {
 	for job := range jobs {
@@ -257,7 +281,7 @@ func (s *Server) scheduleWorker(ctx context.Context, jobs <-chan scheduleJob, re
 					tds = append(tds, s.Text())
 				})
 
-				parseTableRow(tds, &subjects, &mu)
+				parseTableRow(job.query, tds, &subjects, &mu)
 				stringSlicePool.Put(tds)
 			})
 
@@ -270,7 +294,7 @@ func (s *Server) scheduleWorker(ctx context.Context, jobs <-chan scheduleJob, re
 			}
 
 			response := dtos.ScheduleResponse{
-				ID:           fmt.Sprintf("gomaluum:schedule:%s", cuid.Slug()),
+				ID:           utils.StableID("schedule", job.query),
 				SessionName:  job.name,
 				SessionQuery: job.query,
 				Schedule:     subjects,
@@ -346,9 +370,13 @@ func latestSessionQuery(queries, names []string) string {
 // Otherwise it re-scrapes only the latest session plus any session missing from
 // the cache, and serves the rest from cache. It never writes the cache — the
 // handler persists the result only after a fully successful (non-stale) scrape.
-func (s *Server) resolveSchedules(ctx context.Context, username string, queries, names []string, cookie string, stale *atomic.Bool, refresh bool) ([]dtos.ScheduleResponse, error) {
-	if s.indexer == nil || refresh {
-		return s.processSchedulesWithWorkerPool(ctx, queries, names, cookie, stale)
+//
+// previous is the cached copy as it was before this scrape (nil on a miss),
+// read even on refresh so the caller can diff against it.
+func (s *Server) resolveSchedules(ctx context.Context, username string, queries, names []string, cookie string, stale *atomic.Bool, refresh bool) (schedules, previous []dtos.ScheduleResponse, err error) {
+	if s.indexer == nil {
+		schedules, err = s.processSchedulesWithWorkerPool(ctx, queries, names, cookie, stale)
+		return schedules, nil, err
 	}
 
 	cached, found, err := s.indexer.GetSchedule(ctx, username)
@@ -357,8 +385,12 @@ func (s *Server) resolveSchedules(ctx context.Context, username string, queries,
 		s.log.WarnContext(ctx, "GEI GetSchedule failed, scraping all sessions", "error", err)
 		found = false
 	}
-	if !found || len(cached) == 0 {
-		return s.processSchedulesWithWorkerPool(ctx, queries, names, cookie, stale)
+	if !found {
+		cached = nil
+	}
+	if refresh || len(cached) == 0 {
+		schedules, err = s.processSchedulesWithWorkerPool(ctx, queries, names, cookie, stale)
+		return schedules, cached, err
 	}
 
 	cachedByQuery := make(map[string]dtos.ScheduleResponse, len(cached))
@@ -380,7 +412,7 @@ func (s *Server) resolveSchedules(ctx context.Context, username string, queries,
 	if len(scrapeQueries) > 0 {
 		scraped, err = s.processSchedulesWithWorkerPool(ctx, scrapeQueries, scrapeNames, cookie, stale)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 	scrapedByQuery := make(map[string]dtos.ScheduleResponse, len(scraped))
@@ -398,240 +430,229 @@ func (s *Server) resolveSchedules(ctx context.Context, username string, queries,
 			merged = append(merged, c)
 		}
 	}
-	return merged, nil
+	return merged, cached, nil
 }
 
-// @Title ScheduleHandler
-// @Description Get schedule from i-Ma'luum
-// @Tags scraper
-// @Produce json
-// @Param x-gomaluum-key header string false "API key for additional security layer"
-// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
-// @Success 200 {object} dtos.ResponseDTO
-// @Router /api/schedule [get]
-func (s *Server) ScheduleHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	var (
-		logger         = s.log
-		cookie         = r.Context().Value(ctxToken).(string)
-		sessionQueries []string
-		sessionNames   []string
-		schedules      []dtos.ScheduleResponse
-	)
-
-	// username keys the GEI schedule cache; refresh forces a full re-scrape.
-	var username string
-	if sess, ok := r.Context().Value(ctxSession).(*TokenPayload); ok && sess != nil {
-		username = sess.username
-	}
-	refresh := r.URL.Query().Has("refresh")
-
-	// Return fake data for fake user
-	if cookie == constants.DebugUserCookie {
-		now := time.Now()
-		KLTimezone, err := time.LoadLocation("Asia/Kuala_Lumpur")
-		if err != nil {
-			logger.ErrorContext(r.Context(), "Error loading timezone", "error", err)
-			KLTimezone = time.UTC
-		}
-
-		morning9am, _ := time.ParseInLocation("2006-01-02 1504", fmt.Sprintf("%04d-%02d-%02d 0900", now.Year(), now.Month(), now.Day()), KLTimezone)
-		morning11am, _ := time.ParseInLocation("2006-01-02 1504", fmt.Sprintf("%04d-%02d-%02d 1100", now.Year(), now.Month(), now.Day()), KLTimezone)
-		afternoon2pm, _ := time.ParseInLocation("2006-01-02 1504", fmt.Sprintf("%04d-%02d-%02d 1400", now.Year(), now.Month(), now.Day()), KLTimezone)
-		afternoon4pm, _ := time.ParseInLocation("2006-01-02 1504", fmt.Sprintf("%04d-%02d-%02d 1600", now.Year(), now.Month(), now.Day()), KLTimezone)
-		evening5pm, _ := time.ParseInLocation("2006-01-02 1504", fmt.Sprintf("%04d-%02d-%02d 1700", now.Year(), now.Month(), now.Day()), KLTimezone)
-		evening7pm, _ := time.ParseInLocation("2006-01-02 1504", fmt.Sprintf("%04d-%02d-%02d 1900", now.Year(), now.Month(), now.Day()), KLTimezone)
-
-		fakeSchedule := []dtos.ScheduleResponse{
-			{
-				ID:           fmt.Sprintf("gomaluum:schedule:%s", cuid.Slug()),
-				SessionName:  "2024/2025 Semester 1",
-				SessionQuery: "?ses=2024/2025&sem=1",
-				Schedule: []dtos.ScheduleSubject{
-					{
-						ID:         fmt.Sprintf("gomaluum:subject:%s", cuid.Slug()),
-						CourseCode: "INFO4335",
-						CourseName: "Software Engineering",
-						Venue:      "E1-LT4",
-						Lecturer:   "Dr. Muhammad Ali bin Ahmad",
-						Section:    1,
-						Chr:        3.0,
-						Timestamps: []dtos.WeekTime{
-							{
-								Start:     "0900",
-								StartUnix: morning9am.Unix(),
-								End:       "1100",
-								EndUnix:   morning11am.Unix(),
-								Day:       1,
-							},
-							{
-								Start:     "0900",
-								StartUnix: morning9am.Unix(),
-								End:       "1100",
-								EndUnix:   morning11am.Unix(),
-								Day:       3,
-							},
+// fakeSchedules is the canned schedule served to the debug user. Class times
+// are stamped with today's date, like a real scrape.
+func fakeSchedules() []dtos.ScheduleResponse {
+	now := time.Now().In(klTimezone)
+
+	morning9am, _ := time.ParseInLocation("2006-01-02 1504", fmt.Sprintf("%04d-%02d-%02d 0900", now.Year(), now.Month(), now.Day()), klTimezone)
+	morning11am, _ := time.ParseInLocation("2006-01-02 1504", fmt.Sprintf("%04d-%02d-%02d 1100", now.Year(), now.Month(), now.Day()), klTimezone)
+	afternoon2pm, _ := time.ParseInLocation("2006-01-02 1504", fmt.Sprintf("%04d-%02d-%02d 1400", now.Year(), now.Month(), now.Day()), klTimezone)
+	afternoon4pm, _ := time.ParseInLocation("2006-01-02 1504", fmt.Sprintf("%04d-%02d-%02d 1600", now.Year(), now.Month(), now.Day()), klTimezone)
+	evening5pm, _ := time.ParseInLocation("2006-01-02 1504", fmt.Sprintf("%04d-%02d-%02d 1700", now.Year(), now.Month(), now.Day()), klTimezone)
+	evening7pm, _ := time.ParseInLocation("2006-01-02 1504", fmt.Sprintf("%04d-%02d-%02d 1900", now.Year(), now.Month(), now.Day()), klTimezone)
+
+	schedules := []dtos.ScheduleResponse{
+		{
+			SessionName:  "2024/2025 Semester 1",
+			SessionQuery: "?ses=2024/2025&sem=1",
+			Schedule: []dtos.ScheduleSubject{
+				{
+					CourseCode: "INFO4335",
+					CourseName: "Software Engineering",
+					Venue:      "E1-LT4",
+					Lecturer:   "Dr. Muhammad Ali bin Ahmad",
+					Section:    1,
+					Chr:        3.0,
+					Timestamps: []dtos.WeekTime{
+						{
+							Start:     "0900",
+							StartUnix: morning9am.Unix(),
+							End:       "1100",
+							EndUnix:   morning11am.Unix(),
+							Day:       1,
+						},
+						{
+							Start:     "0900",
+							StartUnix: morning9am.Unix(),
+							End:       "1100",
+							EndUnix:   morning11am.Unix(),
+							Day:       3,
 						},
 					},
-					{
-						ID:         fmt.Sprintf("gomaluum:subject:%s", cuid.Slug()),
-						CourseCode: "INFO4327",
-						CourseName: "Database Systems",
-						Venue:      "E2-LT2",
-						Lecturer:   "Prof. Dr. Siti Aminah binti Abdullah",
-						Section:    2,
-						Chr:        3.0,
-						Timestamps: []dtos.WeekTime{
-							{
-								Start:     "1400",
-								StartUnix: afternoon2pm.Unix(),
-								End:       "1600",
-								EndUnix:   afternoon4pm.Unix(),
-								Day:       2,
-							},
-							{
-								Start:     "1400",
-								StartUnix: afternoon2pm.Unix(),
-								End:       "1600",
-								EndUnix:   afternoon4pm.Unix(),
-								Day:       4,
-							},
+				},
+				{
+					CourseCode: "INFO4327",
+					CourseName: "Database Systems",
+					Venue:      "E2-LT2",
+					Lecturer:   "Prof. Dr. Siti Aminah binti Abdullah",
+					Section:    2,
+					Chr:        3.0,
+					Timestamps: []dtos.WeekTime{
+						{
+							Start:     "1400",
+							StartUnix: afternoon2pm.Unix(),
+							End:       "1600",
+							EndUnix:   afternoon4pm.Unix(),
+							Day:       2,
+						},
+						{
+							Start:     "1400",
+							StartUnix: afternoon2pm.Unix(),
+							End:       "1600",
+							EndUnix:   afternoon4pm.Unix(),
+							Day:       4,
 						},
 					},
-					{
-						ID:         fmt.Sprintf("gomaluum:subject:%s", cuid.Slug()),
-						CourseCode: "INFO4501",
-						CourseName: "Web Development",
-						Venue:      "E3-LAB1",
-						Lecturer:   "Dr. Ahmad bin Hassan",
-						Section:    1,
-						Chr:        4.0,
-						Timestamps: []dtos.WeekTime{
-							{
-								Start:     "0900",
-								StartUnix: morning9am.Unix(),
-								End:       "1100",
-								EndUnix:   morning11am.Unix(),
-								Day:       2,
-							},
-							{
-								Start:     "1400",
-								StartUnix: afternoon2pm.Unix(),
-								End:       "1600",
-								EndUnix:   afternoon4pm.Unix(),
-								Day:       5,
-							},
+				},
+				{
+					CourseCode: "INFO4501",
+					CourseName: "Web Development",
+					Venue:      "E3-LAB1",
+					Lecturer:   "Dr. Ahmad bin Hassan",
+					Section:    1,
+					Chr:        4.0,
+					Timestamps: []dtos.WeekTime{
+						{
+							Start:     "0900",
+							StartUnix: morning9am.Unix(),
+							End:       "1100",
+							EndUnix:   morning11am.Unix(),
+							Day:       2,
+						},
+						{
+							Start:     "1400",
+							StartUnix: afternoon2pm.Unix(),
+							End:       "1600",
+							EndUnix:   afternoon4pm.Unix(),
+							Day:       5,
 						},
 					},
-					{
-						ID:         fmt.Sprintf("gomaluum:subject:%s", cuid.Slug()),
-						CourseCode: "INFO4210",
-						CourseName: "Mobile Application Development",
-						Venue:      "E1-LAB2",
-						Lecturer:   "Dr. Fatimah binti Ibrahim",
-						Section:    3,
-						Chr:        3.0,
-						Timestamps: []dtos.WeekTime{
-							{
-								Start:     "1700",
-								StartUnix: evening5pm.Unix(),
-								End:       "1900",
-								EndUnix:   evening7pm.Unix(),
-								Day:       3,
-							},
+				},
+				{
+					CourseCode: "INFO4210",
+					CourseName: "Mobile Application Development",
+					Venue:      "E1-LAB2",
+					Lecturer:   "Dr. Fatimah binti Ibrahim",
+					Section:    3,
+					Chr:        3.0,
+					Timestamps: []dtos.WeekTime{
+						{
+							Start:     "1700",
+							StartUnix: evening5pm.Unix(),
+							End:       "1900",
+							EndUnix:   evening7pm.Unix(),
+							Day:       3,
 						},
 					},
-					{
-						ID:         fmt.Sprintf("gomaluum:subject:%s", cuid.Slug()),
-						CourseCode: "UNGS2040",
-						CourseName: "Tamadun Islam dan Tamadun Asia (TITAS)",
-						Venue:      "KAED-LT1",
-						Lecturer:   "Dr. Zainab binti Yusof",
-						Section:    5,
-						Chr:        2.0,
-						Timestamps: []dtos.WeekTime{
-							{
-								Start:     "0900",
-								StartUnix: morning9am.Unix(),
-								End:       "1100",
-								EndUnix:   morning11am.Unix(),
-								Day:       5,
-							},
+				},
+				{
+					CourseCode: "UNGS2040",
+					CourseName: "Tamadun Islam dan Tamadun Asia (TITAS)",
+					Venue:      "KAED-LT1",
+					Lecturer:   "Dr. Zainab binti Yusof",
+					Section:    5,
+					Chr:        2.0,
+					Timestamps: []dtos.WeekTime{
+						{
+							Start:     "0900",
+							StartUnix: morning9am.Unix(),
+							End:       "1100",
+							EndUnix:   morning11am.Unix(),
+							Day:       5,
 						},
 					},
 				},
 			},
-			{
-				ID:           fmt.Sprintf("gomaluum:schedule:%s", cuid.Slug()),
-				SessionName:  "2023/2024 Semester 2",
-				SessionQuery: "?ses=2023/2024&sem=2",
-				Schedule: []dtos.ScheduleSubject{
-					{
-						ID:         fmt.Sprintf("gomaluum:subject:%s", cuid.Slug()),
-						CourseCode: "INFO3202",
-						CourseName: "Data Structures and Algorithms",
-						Venue:      "E2-LT3",
-						Lecturer:   "Prof. Dr. Abdul Rahman bin Mohd",
-						Section:    1,
-						Chr:        3.0,
-						Timestamps: []dtos.WeekTime{
-							{
-								Start:     "1400",
-								StartUnix: afternoon2pm.Unix(),
-								End:       "1600",
-								EndUnix:   afternoon4pm.Unix(),
-								Day:       1,
-							},
-							{
-								Start:     "1400",
-								StartUnix: afternoon2pm.Unix(),
-								End:       "1600",
-								EndUnix:   afternoon4pm.Unix(),
-								Day:       3,
-							},
+		},
+		{
+			SessionName:  "2023/2024 Semester 2",
+			SessionQuery: "?ses=2023/2024&sem=2",
+			Schedule: []dtos.ScheduleSubject{
+				{
+					CourseCode: "INFO3202",
+					CourseName: "Data Structures and Algorithms",
+					Venue:      "E2-LT3",
+					Lecturer:   "Prof. Dr. Abdul Rahman bin Mohd",
+					Section:    1,
+					Chr:        3.0,
+					Timestamps: []dtos.WeekTime{
+						{
+							Start:     "1400",
+							StartUnix: afternoon2pm.Unix(),
+							End:       "1600",
+							EndUnix:   afternoon4pm.Unix(),
+							Day:       1,
+						},
+						{
+							Start:     "1400",
+							StartUnix: afternoon2pm.Unix(),
+							End:       "1600",
+							EndUnix:   afternoon4pm.Unix(),
+							Day:       3,
 						},
 					},
-					{
-						ID:         fmt.Sprintf("gomaluum:subject:%s", cuid.Slug()),
-						CourseCode: "INFO3150",
-						CourseName: "Computer Networks",
-						Venue:      "E3-LT1",
-						Lecturer:   "Dr. Nurul Huda binti Hassan",
-						Section:    2,
-						Chr:        3.0,
-						Timestamps: []dtos.WeekTime{
-							{
-								Start:     "0900",
-								StartUnix: morning9am.Unix(),
-								End:       "1100",
-								EndUnix:   morning11am.Unix(),
-								Day:       4,
-							},
+				},
+				{
+					CourseCode: "INFO3150",
+					CourseName: "Computer Networks",
+					Venue:      "E3-LT1",
+					Lecturer:   "Dr. Nurul Huda binti Hassan",
+					Section:    2,
+					Chr:        3.0,
+					Timestamps: []dtos.WeekTime{
+						{
+							Start:     "0900",
+							StartUnix: morning9am.Unix(),
+							End:       "1100",
+							EndUnix:   morning11am.Unix(),
+							Day:       4,
 						},
 					},
 				},
 			},
-		}
+		},
+	}
 
-		response := &dtos.ResponseDTO{
-			Message: "Successfully fetched schedule",
-			Data:    fakeSchedule,
+	for i := range schedules {
+		schedules[i].ID = utils.StableID("schedule", schedules[i].SessionQuery)
+		for j := range schedules[i].Schedule {
+			subject := &schedules[i].Schedule[j]
+			subject.ID = subjectID(schedules[i].SessionQuery, subject, subjectRow(schedules[i].Schedule[:j], subject))
 		}
+	}
+	return schedules
+}
 
-		if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
-			logger.ErrorContext(r.Context(), "Failed to encode response", "error", err)
-			errors.Render(w, r, errors.ErrFailedToEncodeResponse)
-		}
-		return
+// fetchSchedules returns every session's schedule for the request's user,
+// newest session first. It goes through the GEI cache (see resolveSchedules)
+// unless refresh is set, and falls back to the last known-good copy when
+// i-Ma'luum is unavailable (see cachedResource).
+func (s *Server) fetchSchedules(ctx context.Context, refresh bool) ([]dtos.ScheduleResponse, error) {
+	return cachedResource(ctx, s, schedulesResource, refresh, func(ctx context.Context) ([]dtos.ScheduleResponse, error) {
+		return s.scrapeSchedules(ctx, refresh)
+	})
+}
+
+// scrapeSchedules is fetchSchedules without the fallback. It refreshes the GEI
+// cache after a successful scrape.
+func (s *Server) scrapeSchedules(ctx context.Context, refresh bool) ([]dtos.ScheduleResponse, error) {
+	var (
+		logger         = s.log
+		cookie, _      = ctx.Value(ctxToken).(string)
+		sessionQueries []string
+		sessionNames   []string
+		schedules      []dtos.ScheduleResponse
+		previous       []dtos.ScheduleResponse
+	)
+
+	// Return fake data for fake user
+	if cookie == constants.DebugUserCookie {
+		return fakeSchedules(), nil
 	}
 
-	if err := s.scrapeWithRetry(r.Context(), func(cookie string) (bool, error) {
+	// username keys the GEI schedule cache.
+	username := requestUsername(ctx)
+
+	if err := s.scrapeWithRetry(ctx, func(cookie string) (bool, error) {
 		var stale atomic.Bool
 		sessionQueries = sessionQueries[:0]
 		sessionNames = sessionNames[:0]
 
-		c := s.newImaluumCollector(r.Context(), cookie, &stale)
+		c := s.newImaluumCollector(ctx, cookie, &stale)
 		c.OnHTML(".box.box-primary .box-header.with-border .dropdown ul.dropdown-menu", func(e *colly.HTMLElement) {
 			sessionQueries = e.ChildAttrs("li[style*='font-size:16px'] a", "href")
 			sessionNames = e.ChildTexts("li[style*='font-size:16px'] a")
@@ -652,29 +673,25 @@ func (s *Server) ScheduleHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		if len(filteredQueries) == 0 {
-			logger.ErrorContext(r.Context(), "No valid sessions found")
+			logger.ErrorContext(ctx, "No valid sessions found")
 			return false, errors.ErrScheduleIsEmpty
 		}
 
-		result, err := s.resolveSchedules(r.Context(), username, filteredQueries, filteredNames, cookie, &stale, refresh)
+		result, cached, err := s.resolveSchedules(ctx, username, filteredQueries, filteredNames, cookie, &stale, refresh)
 		if err != nil {
 			return false, err
 		}
 		if stale.Load() {
 			return true, nil
 		}
-		schedules = result
+		schedules, previous = result, cached
 		return false, nil
 	}); err != nil {
-		logger.ErrorContext(r.Context(), "Failed to scrape schedule", "error", err)
-		errors.Render(w, r, err)
-		return
+		return nil, err
 	}
 
 	if len(schedules) == 0 {
-		logger.ErrorContext(r.Context(), "Schedule is empty")
-		errors.Render(w, r, errors.ErrScheduleIsEmpty)
-		return
+		return nil, errors.ErrScheduleIsEmpty
 	}
 
 	// Sort schedules
@@ -682,17 +699,77 @@ func (s *Server) ScheduleHandler(w http.ResponseWriter, r *http.Request) {
 		return utils.SortSessionNames(schedules[i].SessionName, schedules[j].SessionName)
 	})
 
-	// Refresh the cache with the successful result (best-effort). Only reached on
-	// a non-stale scrape, so the login page can never poison the cache.
+	// Record what changed since the cached copy (best-effort), then refresh the
+	// cache with the successful result. Only reached on a non-stale scrape, so
+	// the login page can never poison the cache or fake a change.
+	if changes := diffSchedules(previous, schedules, time.Now()); len(changes) > 0 && username != "" {
+		if err := s.scheduleChanges.Record(ctx, username, changes); err != nil {
+			logger.WarnContext(ctx, "Failed to record schedule changes", "error", err)
+		}
+	}
 	if s.indexer != nil && username != "" {
-		if err := s.indexer.StoreSchedule(r.Context(), username, schedules); err != nil {
-			logger.WarnContext(r.Context(), "Failed to cache schedule in GEI", "error", err)
+		if err := s.indexer.StoreSchedule(ctx, username, schedules); err != nil {
+			logger.WarnContext(ctx, "Failed to cache schedule in GEI", "error", err)
 		}
 	}
 
+	return schedules, nil
+}
+
+// cachedSchedules is fetchSchedules for callers that poll: it serves the GEI
+// cache as-is when it holds the user's schedules and only scrapes on a miss.
+// The result is sorted newest session first either way.
+func (s *Server) cachedSchedules(ctx context.Context) ([]dtos.ScheduleResponse, error) {
+	sess, ok := ctx.Value(ctxSession).(*TokenPayload)
+	if s.indexer == nil || !ok || sess == nil || sess.imaluumCookie == constants.DebugUserCookie {
+		return s.fetchSchedules(ctx, false)
+	}
+
+	cached, found, err := s.indexer.GetSchedule(ctx, sess.username)
+	if err != nil {
+		s.log.WarnContext(ctx, "GEI GetSchedule failed, scraping schedule", "error", err)
+	}
+	if err != nil || !found || len(cached) == 0 {
+		return s.fetchSchedules(ctx, false)
+	}
+
+	sort.Slice(cached, func(i, j int) bool {
+		return utils.SortSessionNames(cached[i].SessionName, cached[j].SessionName)
+	})
+	return cached, nil
+}
+
+// @Title ScheduleHandler
+// @Description Get schedule from i-Ma'luum. Pass format=ics to get the latest session as an iCalendar file instead.
+// @Tags scraper
+// @Produce json
+// @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Param refresh query bool false "Bypass the schedule cache and re-scrape every session"
+// @Param format query string false "Set to ics for an iCalendar export"
+// @Success 200 {object} dtos.ResponseDTO
+// @Router /api/schedule [get]
+func (s *Server) ScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("format") == "ics" {
+		s.ScheduleICSHandler(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	logger := s.log
+
+	schedules, err := s.fetchSchedules(r.Context(), r.URL.Query().Has("refresh"))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Failed to scrape schedule", "error", err)
+		errors.Render(w, r, err)
+		return
+	}
+
 	response := &dtos.ResponseDTO{
 		Message: "Successfully fetched schedule",
 		Data:    schedules,
+		Stale:   staleness(r.Context(), w),
 	}
 
 	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
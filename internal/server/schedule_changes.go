@@ -0,0 +1,333 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+)
+
+// Kinds of schedule change, stored in dtos.ScheduleChange.Field.
+const (
+	changeAdded    = "added"
+	changeDropped  = "dropped"
+	changeSection  = "section"
+	changeVenue    = "venue"
+	changeLecturer = "lecturer"
+	changeTime     = "time"
+)
+
+const (
+	// maxChangesPerUser bounds the history kept for each user.
+	maxChangesPerUser = 200
+
+	defaultChangesLimit = 50
+)
+
+// courseSnapshot is everything diffSchedules compares for one course in a
+// session. i-Ma'luum splits a course across several rows (one per venue or
+// lecturer), so each field is the sorted, de-duplicated union of its rows.
+type courseSnapshot struct {
+	name      string
+	sections  string
+	venues    string
+	lecturers string
+	times     string
+}
+
+func snapshotCourses(session dtos.ScheduleResponse) map[string]courseSnapshot {
+	type acc struct {
+		name                               string
+		sections, venues, lecturers, times []string
+	}
+	byCode := make(map[string]*acc)
+	for _, subject := range session.Schedule {
+		a, ok := byCode[subject.CourseCode]
+		if !ok {
+			a = &acc{name: subject.CourseName}
+			byCode[subject.CourseCode] = a
+		}
+		a.sections = append(a.sections, strconv.FormatUint(uint64(subject.Section), 10))
+		a.venues = append(a.venues, subject.Venue)
+		a.lecturers = append(a.lecturers, subject.Lecturer)
+		for _, t := range subject.Timestamps {
+			a.times = append(a.times, formatSlot(t))
+		}
+	}
+
+	join := func(values []string) string {
+		slices.Sort(values)
+		return strings.Join(slices.Compact(values), ", ")
+	}
+	snapshots := make(map[string]courseSnapshot, len(byCode))
+	for code, a := range byCode {
+		snapshots[code] = courseSnapshot{
+			name:      a.name,
+			sections:  join(a.sections),
+			venues:    join(a.venues),
+			lecturers: join(a.lecturers),
+			times:     join(a.times),
+		}
+	}
+	return snapshots
+}
+
+// formatSlot renders a weekly slot as e.g. "1 MON 0830-0950". The leading day
+// number keeps slots in weekday order once sorted.
+func formatSlot(t dtos.WeekTime) string {
+	day := "?"
+	if t.Day <= uint8(time.Saturday) {
+		day = strings.ToUpper(time.Weekday(t.Day).String()[:3])
+	}
+	return fmt.Sprintf("%d %s %s-%s", t.Day, day, t.Start, t.End)
+}
+
+// diffSchedules compares each session in current with the same session in
+// previous and reports added and dropped courses and changed sections, venues,
+// lecturers and times, keyed by course code. Sessions absent from previous
+// are new to the cache and produce no changes.
+func diffSchedules(previous, current []dtos.ScheduleResponse, now time.Time) []dtos.ScheduleChange {
+	previousByQuery := make(map[string]dtos.ScheduleResponse, len(previous))
+	for _, p := range previous {
+		previousByQuery[p.SessionQuery] = p
+	}
+
+	var changes []dtos.ScheduleChange
+	for _, session := range current {
+		old, ok := previousByQuery[session.SessionQuery]
+		if !ok {
+			continue
+		}
+		before, after := snapshotCourses(old), snapshotCourses(session)
+
+		record := func(code, name, field, from, to string) {
+			changes = append(changes, dtos.ScheduleChange{
+				SessionName:  session.SessionName,
+				SessionQuery: session.SessionQuery,
+				CourseCode:   code,
+				CourseName:   name,
+				Field:        field,
+				Before:       from,
+				After:        to,
+				DetectedAt:   now.Unix(),
+			})
+		}
+
+		codes := make([]string, 0, len(before)+len(after))
+		for code := range before {
+			codes = append(codes, code)
+		}
+		for code := range after {
+			codes = append(codes, code)
+		}
+		slices.Sort(codes)
+
+		for _, code := range slices.Compact(codes) {
+			b, hadBefore := before[code]
+			a, hasAfter := after[code]
+			switch {
+			case !hadBefore:
+				record(code, a.name, changeAdded, "", a.sections)
+			case !hasAfter:
+				record(code, b.name, changeDropped, b.sections, "")
+			default:
+				for _, f := range []struct{ field, from, to string }{
+					{changeSection, b.sections, a.sections},
+					{changeVenue, b.venues, a.venues},
+					{changeLecturer, b.lecturers, a.lecturers},
+					{changeTime, b.times, a.times},
+				} {
+					if f.from != f.to {
+						record(code, a.name, f.field, f.from, f.to)
+					}
+				}
+			}
+		}
+	}
+	return changes
+}
+
+// scheduleChangeStore keeps each user's schedule change history.
+type scheduleChangeStore interface {
+	Record(ctx context.Context, username string, changes []dtos.ScheduleChange) error
+	// List returns up to limit changes detected after since, newest first.
+	List(ctx context.Context, username string, since time.Time, limit int) ([]dtos.ScheduleChange, error)
+}
+
+// newScheduleChangeStore returns a Postgres-backed store when a database is
+// configured, otherwise an in-memory one that lasts until restart. Both keep
+// the latest maxChangesPerUser changes per user.
+func newScheduleChangeStore(db *sql.DB) scheduleChangeStore {
+	if db != nil {
+		return &postgresScheduleChanges{db: db}
+	}
+	return &memoryScheduleChanges{changes: make(map[string][]dtos.ScheduleChange)}
+}
+
+type memoryScheduleChanges struct {
+	mu      sync.RWMutex
+	seq     int64
+	changes map[string][]dtos.ScheduleChange // oldest first
+}
+
+func (m *memoryScheduleChanges) Record(_ context.Context, username string, changes []dtos.ScheduleChange) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	history := m.changes[username]
+	for _, c := range changes {
+		m.seq++
+		c.ID = fmt.Sprintf("gomaluum:schedule-change:%d", m.seq)
+		history = append(history, c)
+	}
+	if len(history) > maxChangesPerUser {
+		history = slices.Clone(history[len(history)-maxChangesPerUser:])
+	}
+	m.changes[username] = history
+	return nil
+}
+
+func (m *memoryScheduleChanges) List(_ context.Context, username string, since time.Time, limit int) ([]dtos.ScheduleChange, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	history := m.changes[username]
+	result := []dtos.ScheduleChange{}
+	for i := len(history) - 1; i >= 0 && len(result) < limit; i-- {
+		if history[i].DetectedAt > since.Unix() {
+			result = append(result, history[i])
+		}
+	}
+	return result, nil
+}
+
+type postgresScheduleChanges struct {
+	db *sql.DB
+}
+
+func (p *postgresScheduleChanges) Record(ctx context.Context, username string, changes []dtos.ScheduleChange) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, c := range changes {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO schedule_changes
+				(username, session_query, session_name, course_code, course_name, field, old_value, new_value, detected_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`, username, c.SessionQuery, c.SessionName, c.CourseCode, c.CourseName, c.Field, c.Before, c.After, time.Unix(c.DetectedAt, 0)); err != nil {
+			return err
+		}
+	}
+
+	// Same cap as the memory store; older changes are past what List serves.
+	if len(changes) > 0 {
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM schedule_changes
+			WHERE username = $1 AND id NOT IN (
+				SELECT id FROM schedule_changes
+				WHERE username = $1
+				ORDER BY detected_at DESC, id DESC
+				LIMIT $2
+			)
+		`, username, maxChangesPerUser); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (p *postgresScheduleChanges) List(ctx context.Context, username string, since time.Time, limit int) ([]dtos.ScheduleChange, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT id, session_query, session_name, course_code, course_name, field, old_value, new_value, detected_at
+		FROM schedule_changes
+		WHERE username = $1 AND detected_at > $2
+		ORDER BY detected_at DESC, id DESC
+		LIMIT $3
+	`, username, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []dtos.ScheduleChange{}
+	for rows.Next() {
+		var (
+			c          dtos.ScheduleChange
+			id         int64
+			detectedAt time.Time
+		)
+		if err := rows.Scan(&id, &c.SessionQuery, &c.SessionName, &c.CourseCode, &c.CourseName, &c.Field, &c.Before, &c.After, &detectedAt); err != nil {
+			return nil, err
+		}
+		c.ID = fmt.Sprintf("gomaluum:schedule-change:%d", id)
+		c.DetectedAt = detectedAt.Unix()
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// @Title ScheduleChangesHandler
+// @Description Get the history of changes to your schedule (added and dropped courses, and section, venue, lecturer and time changes), newest first. Changes are detected whenever the schedule is re-scraped, e.g. by /api/schedule.
+// @Tags scraper
+// @Produce json
+// @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Param since query int false "Only return changes detected after this Unix timestamp"
+// @Param limit query int false "Maximum number of changes to return (default 50, max 200)"
+// @Success 200 {object} dtos.ResponseDTO{data=[]dtos.ScheduleChange}
+// @Router /api/schedule/changes [get]
+func (s *Server) ScheduleChangesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	logger := s.log
+	sess := r.Context().Value(ctxSession).(*TokenPayload)
+
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		unix, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			errors.Render(w, r, errors.ErrInvalidRequest)
+			return
+		}
+		since = time.Unix(unix, 0)
+	}
+
+	limit := defaultChangesLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			errors.Render(w, r, errors.ErrInvalidRequest)
+			return
+		}
+		limit = min(n, maxChangesPerUser)
+	}
+
+	changes, err := s.scheduleChanges.List(r.Context(), sess.username, since, limit)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Failed to list schedule changes", "error", err)
+		errors.Render(w, r, errors.ErrFailedToQueryDB)
+		return
+	}
+
+	response := &dtos.ResponseDTO{
+		Message: "Successfully fetched schedule changes",
+		Data:    changes,
+	}
+
+	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
+		logger.ErrorContext(r.Context(), "Failed to encode response", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+	}
+}
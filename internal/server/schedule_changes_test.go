@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffSchedules(t *testing.T) {
+	now := time.Unix(1760000000, 0)
+	previous := []dtos.ScheduleResponse{{
+		SessionQuery: "?ses=2025/2026&sem=1",
+		Schedule: []dtos.ScheduleSubject{
+			{CourseCode: "INFO4335", CourseName: "SE", Section: 1, Venue: "E1-LT4", Lecturer: "Dr. A", Timestamps: []dtos.WeekTime{{Start: "0830", End: "0950", Day: 1}}},
+			{CourseCode: "INFO4335", CourseName: "SE", Section: 1, Venue: "E1-LT4", Lecturer: "Dr. A", Timestamps: []dtos.WeekTime{{Start: "0830", End: "0950", Day: 3}}},
+			{CourseCode: "INFO3150", CourseName: "Networks", Section: 2, Venue: "E3-LT1", Lecturer: "Dr. B"},
+		},
+	}}
+	current := []dtos.ScheduleResponse{
+		{
+			SessionQuery: "?ses=2025/2026&sem=1",
+			Schedule: []dtos.ScheduleSubject{
+				// Rows arrive in a different order: not a change.
+				{CourseCode: "INFO4335", CourseName: "SE", Section: 1, Venue: "E2-LT1", Lecturer: "Dr. A", Timestamps: []dtos.WeekTime{{Start: "0830", End: "0950", Day: 3}}},
+				{CourseCode: "INFO4335", CourseName: "SE", Section: 1, Venue: "E2-LT1", Lecturer: "Dr. A", Timestamps: []dtos.WeekTime{{Start: "1000", End: "1120", Day: 1}}},
+				{CourseCode: "LE4000", CourseName: "English", Section: 5, Venue: "CELPAD", Lecturer: "Ms. C"},
+			},
+		},
+		// Not in the previous cache: nothing to compare against.
+		{SessionQuery: "?ses=2025/2026&sem=2", Schedule: []dtos.ScheduleSubject{{CourseCode: "X"}}},
+	}
+
+	changes := diffSchedules(previous, current, now)
+
+	type change struct{ code, field, before, after string }
+	var got []change
+	for _, c := range changes {
+		require.Equal(t, now.Unix(), c.DetectedAt)
+		got = append(got, change{c.CourseCode, c.Field, c.Before, c.After})
+	}
+	require.Equal(t, []change{
+		{"INFO3150", changeDropped, "2", ""},
+		{"INFO4335", changeVenue, "E1-LT4", "E2-LT1"},
+		{"INFO4335", changeTime, "1 MON 0830-0950, 3 WED 0830-0950", "1 MON 1000-1120, 3 WED 0830-0950"},
+		{"LE4000", changeAdded, "", "5"},
+	}, got)
+
+	require.Empty(t, diffSchedules(previous, previous, now))
+	require.Empty(t, diffSchedules(nil, current, now))
+}
+
+func TestMemoryScheduleChanges(t *testing.T) {
+	ctx := context.Background()
+	store := newScheduleChangeStore(nil)
+
+	require.NoError(t, store.Record(ctx, "u", []dtos.ScheduleChange{
+		{CourseCode: "A", DetectedAt: 100},
+		{CourseCode: "B", DetectedAt: 200},
+	}))
+
+	changes, err := store.List(ctx, "u", time.Unix(0, 0), 10)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	require.Equal(t, "B", changes[0].CourseCode)
+	require.NotEqual(t, changes[0].ID, changes[1].ID)
+
+	changes, err = store.List(ctx, "u", time.Unix(100, 0), 10)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+
+	changes, err = store.List(ctx, "other", time.Unix(0, 0), 10)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+}
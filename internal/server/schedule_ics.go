@@ -0,0 +1,156 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+	"github.com/nrmnqdds/gomaluum/pkg/ics"
+)
+
+const icsProdID = "-//gomaluum//Schedule//EN"
+
+// clockMinutes converts an "HHMM" wall-clock time into minutes after midnight.
+func clockMinutes(hhmm string) (int, bool) {
+	if len(hhmm) != 4 {
+		return 0, false
+	}
+	hour, err := strconv.Atoi(hhmm[:2])
+	if err != nil || hour < 0 || hour > 23 {
+		return 0, false
+	}
+	minute, err := strconv.Atoi(hhmm[2:])
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, false
+	}
+	return hour*60 + minute, true
+}
+
+// clockOn returns day's date at the "HHMM" wall-clock time in Kuala Lumpur.
+func clockOn(day time.Time, hhmm string) (time.Time, bool) {
+	minutes, ok := clockMinutes(hhmm)
+	if !ok {
+		return time.Time{}, false
+	}
+	d := day.In(klTimezone)
+	return time.Date(d.Year(), d.Month(), d.Day(), minutes/60, minutes%60, 0, 0, klTimezone), true
+}
+
+// slotUID derives a UID that stays the same across exports, so calendar apps
+// update an imported class instead of duplicating it.
+func slotUID(session dtos.ScheduleResponse, subject dtos.ScheduleSubject, slot dtos.WeekTime) string {
+	sum := sha1.Sum(fmt.Appendf(nil, "%s|%s|%d|%d|%s", session.SessionQuery, subject.CourseCode, subject.Section, slot.Day, slot.Start))
+	return hex.EncodeToString(sum[:]) + "@gomaluum"
+}
+
+// scheduleEvents turns every weekly slot of session into a VEVENT repeating
+// weekly from its first lecture day in sem until the semester's last lecture
+// day. Weeks without classes on the slot's day (mid-semester break, public
+// holidays) are excluded with EXDATEs. Slots with an unknown day or time are skipped.
+func scheduleEvents(session dtos.ScheduleResponse, sem semester) []ics.Event {
+	until := sem.End().AddDate(0, 0, 1).Add(-time.Second)
+
+	var events []ics.Event
+	for _, subject := range session.Schedule {
+		for _, slot := range subject.Timestamps {
+			if slot.Day > uint8(time.Saturday) {
+				continue
+			}
+
+			first := sem.Start()
+			for first.Weekday() != time.Weekday(slot.Day) {
+				first = first.AddDate(0, 0, 1)
+			}
+			if first.After(sem.End()) {
+				continue
+			}
+
+			start, ok := clockOn(first, slot.Start)
+			if !ok {
+				continue
+			}
+			end, ok := clockOn(first, slot.End)
+			if !ok {
+				continue
+			}
+
+			var exDates []time.Time
+			for day := first; !day.After(sem.End()); day = day.AddDate(0, 0, 7) {
+				if !sem.HasClasses(day) {
+					ex, _ := clockOn(day, slot.Start)
+					exDates = append(exDates, ex)
+				}
+			}
+
+			events = append(events, ics.Event{
+				UID:         slotUID(session, subject, slot),
+				Summary:     fmt.Sprintf("%s %s", subject.CourseCode, subject.CourseName),
+				Location:    subject.Venue,
+				Description: fmt.Sprintf("Section %d\nLecturer: %s", subject.Section, subject.Lecturer),
+				Start:       start,
+				End:         end,
+				RRule:       "FREQ=WEEKLY;UNTIL=" + ics.FormatTime(until),
+				ExDates:     exDates,
+			})
+		}
+	}
+	return events
+}
+
+// @Title ScheduleICSHandler
+// @Description Export the latest session's schedule as an iCalendar file. Classes repeat weekly across the current (or next) semester's lecture weeks from the academic calendar, skipping the mid-semester break and public holidays.
+// @Tags scraper
+// @Produce text/calendar
+// @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Param refresh query bool false "Bypass the schedule cache and re-scrape every session"
+// @Success 200 {string} string "iCalendar file"
+// @Failure 404 {object} errors.CustomError "No current or upcoming semester"
+// @Router /api/schedule.ics [get]
+func (s *Server) ScheduleICSHandler(w http.ResponseWriter, r *http.Request) {
+	logger := s.log
+
+	schedules, err := s.fetchSchedules(r.Context(), r.URL.Query().Has("refresh"))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Failed to scrape schedule", "error", err)
+		errors.Render(w, r, err)
+		return
+	}
+
+	sem, ok, err := semesterFor(time.Now())
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Failed to parse academic calendar data", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+		return
+	}
+	if !ok {
+		errors.Render(w, r, errors.ErrNoUpcomingSemester)
+		return
+	}
+
+	// fetchSchedules sorts newest first.
+	latest := schedules[0]
+	cal := &ics.Calendar{
+		ProdID: icsProdID,
+		Name:   "i-Ma'luum " + latest.SessionName,
+		Events: scheduleEvents(latest, sem),
+	}
+
+	var buf bytes.Buffer
+	if err := cal.Encode(&buf); err != nil {
+		logger.ErrorContext(r.Context(), "Failed to encode calendar", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+		return
+	}
+
+	staleness(r.Context(), w)
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="schedule.ics"`)
+	_, _ = w.Write(buf.Bytes())
+}
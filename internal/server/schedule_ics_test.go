@@ -0,0 +1,63 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleEvents(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, klTimezone) }
+
+	// Two lecture blocks with a one-week break (Nov 24-28) in between.
+	sem := semester{Lectures: []calendarPeriod{
+		{Title: calendarLectures, Start: day(2025, 10, 6), End: day(2025, 11, 21)},
+		{Title: calendarLectures, Start: day(2025, 12, 1), End: day(2026, 1, 16)},
+	}}
+
+	session := dtos.ScheduleResponse{
+		SessionQuery: "?ses=2025/2026&sem=1",
+		Schedule: []dtos.ScheduleSubject{{
+			CourseCode: "INFO4335",
+			CourseName: "Software Engineering",
+			Section:    1,
+			Timestamps: []dtos.WeekTime{
+				{Start: "0900", End: "1100", Day: 3}, // Wednesday
+				{Start: "0900", End: "1100", Day: 7}, // unknown day, skipped
+			},
+		}},
+	}
+
+	events := scheduleEvents(session, sem)
+	require.Len(t, events, 1)
+
+	e := events[0]
+	require.Equal(t, time.Date(2025, 10, 8, 9, 0, 0, 0, klTimezone), e.Start)
+	require.Equal(t, time.Date(2025, 10, 8, 11, 0, 0, 0, klTimezone), e.End)
+	require.Equal(t, "FREQ=WEEKLY;UNTIL=20260116T155959Z", e.RRule)
+	require.Equal(t, []time.Time{time.Date(2025, 11, 26, 9, 0, 0, 0, klTimezone)}, e.ExDates)
+
+	// The UID must survive a re-export.
+	require.Equal(t, e.UID, scheduleEvents(session, sem)[0].UID)
+}
+
+func TestGroupSemesters(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, klTimezone) }
+
+	semesters := groupSemesters([]calendarPeriod{
+		{Title: calendarLectures, Start: day(2025, 10, 6), End: day(2025, 11, 21)},
+		{Title: "MID SEMESTER BREAK", Start: day(2025, 11, 22), End: day(2025, 11, 30)},
+		{Title: calendarLectures, Start: day(2025, 12, 1), End: day(2026, 1, 16)},
+		{Title: "EXAMINATION PERIOD", Start: day(2026, 1, 22), End: day(2026, 2, 13)},
+		{Title: calendarInterSemester, Start: day(2026, 2, 14), End: day(2026, 3, 1)},
+		{Title: calendarLectures, Start: day(2026, 3, 2), End: day(2026, 4, 17)},
+	})
+	require.Len(t, semesters, 2)
+	require.Len(t, semesters[0].Lectures, 2)
+	require.Equal(t, day(2025, 10, 6), semesters[0].Start())
+	require.Equal(t, day(2026, 1, 16), semesters[0].End())
+	require.False(t, semesters[0].HasLectures(day(2025, 11, 25)))
+	require.True(t, semesters[0].HasLectures(day(2025, 12, 1).Add(10*time.Hour)))
+}
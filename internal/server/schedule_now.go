@@ -0,0 +1,94 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+)
+
+// nextClassLookahead is how far ahead nowAndNext searches for the next class.
+// It spans the longest gap between semesters in the academic calendar.
+const nextClassLookahead = 120 * 24 * time.Hour
+
+// nowAndNext finds, at now, the class in progress, the next class later today
+// and the next class overall (which may be today's). Any of them is nil when
+// there is none.
+func nowAndNext(session dtos.ScheduleResponse, sems []semester, now time.Time) *dtos.ScheduleNow {
+	result := &dtos.ScheduleNow{
+		SessionName: session.SessionName,
+		Timestamp:   now.Unix(),
+	}
+
+	today := calendarDate(now.In(klTimezone))
+	countdown := func(o dtos.ScheduleOccurrence) *dtos.ScheduleNowClass {
+		return &dtos.ScheduleNowClass{
+			ScheduleOccurrence: o,
+			StartsIn:           o.StartUnix - now.Unix(),
+			EndsIn:             o.EndUnix - now.Unix(),
+		}
+	}
+
+	// Occurrences are ordered by start time, so the first one that has not
+	// started is the next class, and the next class today if it is today.
+	for _, o := range scheduleOccurrences(session, sems, today, now.Add(nextClassLookahead)) {
+		if o.EndUnix <= now.Unix() {
+			continue
+		}
+		if o.StartUnix <= now.Unix() {
+			if result.Current == nil {
+				result.Current = countdown(o)
+			}
+			continue
+		}
+
+		result.Next = countdown(o)
+		if o.Date == today.Format(time.DateOnly) {
+			result.NextToday = result.Next
+		}
+		break
+	}
+
+	return result
+}
+
+// @Title ScheduleNowHandler
+// @Description Get the class in progress, the next class today and the next class overall for the latest session, with countdowns in seconds. Served from the schedule cache when possible, so it is cheap to poll.
+// @Tags scraper
+// @Produce json
+// @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Success 200 {object} dtos.ResponseDTO{data=dtos.ScheduleNow}
+// @Router /api/schedule/now [get]
+func (s *Server) ScheduleNowHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	logger := s.log
+
+	schedules, err := s.cachedSchedules(r.Context())
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Failed to load schedule", "error", err)
+		errors.Render(w, r, err)
+		return
+	}
+
+	sems, err := semesters()
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Failed to parse academic calendar data", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+		return
+	}
+
+	response := &dtos.ResponseDTO{
+		Message: "Successfully fetched current and next class",
+		Data:    nowAndNext(schedules[0], sems, time.Now()),
+		Stale:   staleness(r.Context(), w),
+	}
+
+	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
+		logger.ErrorContext(r.Context(), "Failed to encode response", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+	}
+}
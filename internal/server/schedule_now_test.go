@@ -0,0 +1,62 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNowAndNext(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, klTimezone) }
+
+	sems := []semester{{Lectures: []calendarPeriod{
+		{Title: calendarLectures, Start: day(2025, 10, 6), End: day(2025, 11, 21)},
+		{Title: calendarLectures, Start: day(2025, 12, 1), End: day(2026, 1, 16)},
+	}}}
+	session := dtos.ScheduleResponse{
+		SessionName: "2025/2026 Semester 1",
+		Schedule: []dtos.ScheduleSubject{
+			{CourseCode: "INFO4335", Timestamps: []dtos.WeekTime{{Start: "0830", End: "0950", Day: 1}}},
+			{CourseCode: "INFO3150", Timestamps: []dtos.WeekTime{{Start: "1400", End: "1520", Day: 1}}},
+			{CourseCode: "INFO4401", Timestamps: []dtos.WeekTime{{Start: "1000", End: "1120", Day: 3}}},
+		},
+	}
+
+	t.Run("in class with another later today", func(t *testing.T) {
+		now := time.Date(2025, 10, 6, 9, 0, 0, 0, klTimezone)
+		got := nowAndNext(session, sems, now)
+
+		require.NotNil(t, got.Current)
+		require.Equal(t, "INFO4335", got.Current.CourseCode)
+		require.Equal(t, int64(-30*60), got.Current.StartsIn)
+		require.Equal(t, int64(50*60), got.Current.EndsIn)
+
+		require.NotNil(t, got.NextToday)
+		require.Equal(t, "INFO3150", got.NextToday.CourseCode)
+		require.Equal(t, int64(5*60*60), got.NextToday.StartsIn)
+		require.Equal(t, got.NextToday, got.Next)
+	})
+
+	t.Run("done for the day", func(t *testing.T) {
+		got := nowAndNext(session, sems, time.Date(2025, 10, 6, 16, 0, 0, 0, klTimezone))
+		require.Nil(t, got.Current)
+		require.Nil(t, got.NextToday)
+		require.NotNil(t, got.Next)
+		require.Equal(t, "INFO4401", got.Next.CourseCode)
+		require.Equal(t, "2025-10-08", got.Next.Date)
+	})
+
+	t.Run("mid-semester break skips to the next lecture week", func(t *testing.T) {
+		got := nowAndNext(session, sems, time.Date(2025, 11, 24, 9, 0, 0, 0, klTimezone))
+		require.Nil(t, got.Current)
+		require.Equal(t, "2025-12-01", got.Next.Date)
+	})
+
+	t.Run("after the last semester", func(t *testing.T) {
+		got := nowAndNext(session, sems, time.Date(2026, 6, 1, 9, 0, 0, 0, klTimezone))
+		require.Nil(t, got.Current)
+		require.Nil(t, got.Next)
+	})
+}
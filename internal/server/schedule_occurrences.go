@@ -0,0 +1,159 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+	"github.com/nrmnqdds/gomaluum/pkg/utils"
+)
+
+// maxOccurrenceDays caps the requested range at about one semester so a
+// single request cannot expand into years of occurrences.
+const maxOccurrenceDays = 186
+
+// weekOf returns Monday and Sunday of the week containing now, at Kuala
+// Lumpur midnight.
+func weekOf(now time.Time) (monday, sunday time.Time) {
+	today := calendarDate(now.In(klTimezone))
+	offset := (int(today.Weekday()) + 6) % 7 // days since Monday
+	monday = today.AddDate(0, 0, -offset)
+	return monday, monday.AddDate(0, 0, 6)
+}
+
+// occurrenceRange reads the inclusive from/to dates (YYYY-MM-DD) from q. A
+// missing bound defaults to the current week's; a range that is reversed,
+// unparsable or longer than maxOccurrenceDays is rejected.
+func occurrenceRange(q url.Values, now time.Time) (from, to time.Time, err error) {
+	from, to = weekOf(now)
+
+	if v := q.Get("from"); v != "" {
+		if from, err = time.ParseInLocation(time.DateOnly, v, klTimezone); err != nil {
+			return time.Time{}, time.Time{}, errors.ErrInvalidDateRange
+		}
+		if q.Get("to") == "" {
+			to = from.AddDate(0, 0, 6)
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if to, err = time.ParseInLocation(time.DateOnly, v, klTimezone); err != nil {
+			return time.Time{}, time.Time{}, errors.ErrInvalidDateRange
+		}
+	}
+
+	if to.Before(from) || to.Sub(from) > maxOccurrenceDays*24*time.Hour {
+		return time.Time{}, time.Time{}, errors.ErrInvalidDateRange
+	}
+	return from, to, nil
+}
+
+// scheduleOccurrences expands session's weekly slots into dated occurrences
+// between from and to (inclusive calendar dates), ordered by start time. Days
+// without classes in any of sems (breaks, exams, vacations, public holidays)
+// produce nothing, as do slots with an unknown day or time.
+func scheduleOccurrences(session dtos.ScheduleResponse, sems []semester, from, to time.Time) []dtos.ScheduleOccurrence {
+	occurrences := []dtos.ScheduleOccurrence{}
+
+	for day := calendarDate(from.In(klTimezone)); !day.After(to); day = day.AddDate(0, 0, 1) {
+		if !slices.ContainsFunc(sems, func(s semester) bool { return s.HasClasses(day) }) {
+			continue
+		}
+
+		for _, subject := range session.Schedule {
+			for _, slot := range subject.Timestamps {
+				if slot.Day > uint8(time.Saturday) || time.Weekday(slot.Day) != day.Weekday() {
+					continue
+				}
+				start, ok := clockOn(day, slot.Start)
+				if !ok {
+					continue
+				}
+				end, ok := clockOn(day, slot.End)
+				if !ok {
+					continue
+				}
+
+				occurrences = append(occurrences, dtos.ScheduleOccurrence{
+					ID:         utils.StableID("occurrence", subject.ID, start.Format(time.RFC3339)),
+					CourseCode: subject.CourseCode,
+					CourseName: subject.CourseName,
+					Venue:      subject.Venue,
+					Lecturer:   subject.Lecturer,
+					Section:    subject.Section,
+					Day:        slot.Day,
+					Date:       day.Format(time.DateOnly),
+					Start:      start.Format(time.RFC3339),
+					End:        end.Format(time.RFC3339),
+					StartUnix:  start.Unix(),
+					EndUnix:    end.Unix(),
+				})
+			}
+		}
+	}
+
+	slices.SortStableFunc(occurrences, func(a, b dtos.ScheduleOccurrence) int {
+		return int(a.StartUnix - b.StartUnix)
+	})
+	return occurrences
+}
+
+// @Title ScheduleOccurrencesHandler
+// @Description Get the latest session's classes as dated occurrences between from and to (inclusive, Asia/Kuala_Lumpur dates). Defaults to the current week. Mid-semester breaks, exam weeks, vacations and public holidays are excluded using the academic calendar.
+// @Tags scraper
+// @Produce json
+// @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Param from query string false "First date, YYYY-MM-DD (default: Monday of this week)"
+// @Param to query string false "Last date, YYYY-MM-DD (default: six days after from)"
+// @Param refresh query bool false "Bypass the schedule cache and re-scrape every session"
+// @Success 200 {object} dtos.ResponseDTO{data=dtos.ScheduleOccurrences}
+// @Failure 400 {object} errors.CustomError "Invalid date range"
+// @Router /api/schedule/occurrences [get]
+func (s *Server) ScheduleOccurrencesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	logger := s.log
+
+	from, to, err := occurrenceRange(r.URL.Query(), time.Now())
+	if err != nil {
+		errors.Render(w, r, err)
+		return
+	}
+
+	schedules, err := s.fetchSchedules(r.Context(), r.URL.Query().Has("refresh"))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Failed to scrape schedule", "error", err)
+		errors.Render(w, r, err)
+		return
+	}
+
+	sems, err := semesters()
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Failed to parse academic calendar data", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+		return
+	}
+
+	// fetchSchedules sorts newest first.
+	latest := schedules[0]
+	response := &dtos.ResponseDTO{
+		Message: "Successfully fetched schedule occurrences",
+		Data: &dtos.ScheduleOccurrences{
+			SessionName:  latest.SessionName,
+			SessionQuery: latest.SessionQuery,
+			From:         from.Format(time.DateOnly),
+			To:           to.Format(time.DateOnly),
+			Occurrences:  scheduleOccurrences(latest, sems, from, to),
+		},
+		Stale: staleness(r.Context(), w),
+	}
+
+	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
+		logger.ErrorContext(r.Context(), "Failed to encode response", "error", err)
+		errors.Render(w, r, errors.ErrFailedToEncodeResponse)
+	}
+}
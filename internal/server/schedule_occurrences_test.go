@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleOccurrences(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, klTimezone) }
+
+	sems := []semester{{
+		Lectures: []calendarPeriod{
+			{Title: calendarLectures, Start: day(2025, 10, 6), End: day(2025, 11, 21)},
+			{Title: calendarLectures, Start: day(2025, 12, 1), End: day(2026, 1, 16)},
+		},
+		Holidays: map[string]string{"2025-10-20": "Deepavali"},
+	}}
+
+	session := dtos.ScheduleResponse{
+		SessionQuery: "?ses=2025/2026&sem=1",
+		Schedule: []dtos.ScheduleSubject{{
+			CourseCode: "INFO4335",
+			Section:    1,
+			Timestamps: []dtos.WeekTime{
+				{Start: "1400", End: "1520", Day: 1}, // Monday
+				{Start: "0830", End: "0950", Day: 3}, // Wednesday
+			},
+		}},
+	}
+
+	// Oct 20 (Mon) is a holiday; Nov 24-28 is the mid-semester break.
+	got := scheduleOccurrences(session, sems, day(2025, 10, 20), day(2025, 11, 30))
+
+	var dates []string
+	for _, o := range got {
+		dates = append(dates, o.Date)
+	}
+	require.Equal(t, []string{
+		"2025-10-22",
+		"2025-10-27", "2025-10-29",
+		"2025-11-03", "2025-11-05",
+		"2025-11-10", "2025-11-12",
+		"2025-11-17", "2025-11-19",
+	}, dates)
+
+	first := got[0]
+	require.Equal(t, "2025-10-22T08:30:00+08:00", first.Start)
+	require.Equal(t, time.Date(2025, 10, 22, 9, 50, 0, 0, klTimezone).Unix(), first.EndUnix)
+
+	// IDs are stable across calls and unique per occurrence.
+	require.Equal(t, first.ID, scheduleOccurrences(session, sems, day(2025, 10, 20), day(2025, 11, 30))[0].ID)
+	require.NotEqual(t, got[0].ID, got[1].ID)
+
+	require.Empty(t, scheduleOccurrences(session, sems, day(2026, 2, 1), day(2026, 2, 7)))
+}
+
+func TestOccurrenceRange(t *testing.T) {
+	now := time.Date(2025, 10, 23, 15, 0, 0, 0, klTimezone) // Thursday
+
+	from, to, err := occurrenceRange(url.Values{}, now)
+	require.NoError(t, err)
+	require.Equal(t, "2025-10-20", from.Format(time.DateOnly))
+	require.Equal(t, "2025-10-26", to.Format(time.DateOnly))
+
+	from, to, err = occurrenceRange(url.Values{"from": {"2025-12-01"}}, now)
+	require.NoError(t, err)
+	require.Equal(t, "2025-12-01", from.Format(time.DateOnly))
+	require.Equal(t, "2025-12-07", to.Format(time.DateOnly))
+
+	for _, q := range []url.Values{
+		{"from": {"01-12-2025"}},
+		{"from": {"2025-12-08"}, "to": {"2025-12-01"}},
+		{"from": {"2025-01-01"}, "to": {"2026-01-01"}},
+	} {
+		_, _, err := occurrenceRange(q, now)
+		require.Error(t, err, q.Encode())
+	}
+}
@@ -1,8 +1,11 @@
 package server
 
 import (
+	"slices"
+	"sync"
 	"testing"
 
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
 	"github.com/stretchr/testify/require"
 )
 
@@ -28,3 +31,42 @@ func TestLatestSessionQuery(t *testing.T) {
 		)
 	})
 }
+
+func TestParseTableRowStableIDs(t *testing.T) {
+	row := []string{"INFO4335", "Software Engineering", "1", "3", "", "MW", "830-950", "E1-LT4", "Dr. A"}
+	parse := func(session string, row ...string) dtos.ScheduleSubject {
+		var (
+			mu       sync.Mutex
+			subjects []dtos.ScheduleSubject
+		)
+		parseTableRow(session, row, &subjects, &mu)
+		require.Len(t, subjects, 1)
+		return subjects[0]
+	}
+
+	first := parse("?ses=2025/2026&sem=1", row...)
+	require.Equal(t, first.ID, parse("?ses=2025/2026&sem=1", row...).ID)
+	require.NotEqual(t, first.ID, parse("?ses=2025/2026&sem=2", row...).ID)
+
+	// A new time or venue is the same subject, changed; a new section is not.
+	moved := slices.Clone(row)
+	moved[5], moved[6], moved[7] = "TTH", "1400-1520", "E2-LT1"
+	require.Equal(t, first.ID, parse("?ses=2025/2026&sem=1", moved...).ID)
+	moved[2] = "2"
+	require.NotEqual(t, first.ID, parse("?ses=2025/2026&sem=1", moved...).ID)
+
+	// A course split across rows (a merged row per extra slot) gets one ID per
+	// row, so the IDs stay unique within the list.
+	var (
+		mu       sync.Mutex
+		subjects []dtos.ScheduleSubject
+	)
+	parseTableRow("?ses=2025/2026&sem=1", row, &subjects, &mu)
+	parseTableRow("?ses=2025/2026&sem=1", []string{"F", "1000-1120", "E1-LT4", "Dr. A"}, &subjects, &mu)
+	parseTableRow("?ses=2025/2026&sem=1", []string{"TH", "1400-1520", "E1-LT4", "Dr. A"}, &subjects, &mu)
+	require.Len(t, subjects, 3)
+	require.Equal(t, first.ID, subjects[0].ID)
+	require.NotEqual(t, subjects[0].ID, subjects[1].ID)
+	require.NotEqual(t, subjects[1].ID, subjects[2].ID)
+	require.NotEqual(t, subjects[0].ID, subjects[2].ID)
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+)
+
+// Scopes a third-party app can be granted (see oauth.go). Each guards a route
+// group in RegisterRoutes. First-party tokens, from /api/auth/login, carry no
+// scope claim and may use every route.
+const (
+	scopeProfileBasic     = "profile:basic"
+	scopeProfileFull      = "profile:full"
+	scopeScheduleRead     = "schedule:read"
+	scopeResultRead       = "result:read"
+	scopeExamRead         = "exam:read"
+	scopeStarpointRead    = "starpoint:read"
+	scopeDisciplinaryRead = "disciplinary:read"
+	scopeCarryMarkRead    = "carry-mark:read"
+	scopeDocumentsRead    = "documents:read"
+
+	// scopeAccount guards account management: calendar feeds, logging out
+	// everywhere and approving apps. It is never granted to apps.
+	scopeAccount = "account"
+)
+
+// grantableScopes maps each scope an app may request to the description shown
+// to the student on the consent screen.
+var grantableScopes = map[string]string{
+	scopeProfileBasic:     "Your name, matric number, level, kulliyyah and photo",
+	scopeProfileFull:      "Your full profile, including IC number, birthday, religion, marital status and address",
+	scopeScheduleRead:     "Your class timetables",
+	scopeResultRead:       "Your examination results",
+	scopeExamRead:         "Your final examination timetable",
+	scopeStarpointRead:    "Your star points",
+	scopeDisciplinaryRead: "Your disciplinary records",
+	scopeCarryMarkRead:    "Your carry marks",
+	scopeDocumentsRead:    "Your exam slip and study plan documents",
+}
+
+// parseScopes splits an OAuth-style space-separated scope string into sorted,
+// distinct grantable scopes. An empty or unknown scope is an error.
+func parseScopes(scope string) ([]string, error) {
+	scopes := strings.Fields(scope)
+	if len(scopes) == 0 {
+		return nil, errors.ErrInvalidScope
+	}
+	for _, sc := range scopes {
+		if _, ok := grantableScopes[sc]; !ok {
+			return nil, errors.ErrInvalidScope
+		}
+	}
+	sort.Strings(scopes)
+	return slices.Compact(scopes), nil
+}
+
+// hasScopes reports whether the token was granted all of required. A
+// first-party token (nil scopes) has every scope.
+func (p *TokenPayload) hasScopes(required ...string) bool {
+	if p.scopes == nil {
+		return true
+	}
+	for _, sc := range required {
+		if !slices.Contains(p.scopes, sc) {
+			return false
+		}
+	}
+	return true
+}
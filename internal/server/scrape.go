@@ -147,7 +147,7 @@ func (s *Server) scrapeWithRetry(ctx context.Context, fn func(cookie string) (bo
 	}
 	return runWithRetry(
 		sess.imaluumCookie,
-		func() (string, error) { return s.refreshSession(ctx, sess.username, sess.password) },
+		func() (string, error) { return s.refreshSession(ctx, sess.username, sess.password, sess.credential) },
 		fn,
 	)
 }
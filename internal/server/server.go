@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"database/sql"
@@ -9,16 +10,19 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	auth_proto "github.com/nrmnqdds/gomaluum/internal/proto"
+	"github.com/nrmnqdds/gomaluum/pkg/breaker"
 	"github.com/nrmnqdds/gomaluum/pkg/logger"
 	"github.com/nrmnqdds/gomaluum/pkg/paseto"
+	"github.com/nrmnqdds/gomaluum/pkg/ratelimit"
 	"github.com/nrmnqdds/gomaluum/pkg/sf"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 
 	_ "github.com/lib/pq"
 )
@@ -31,16 +35,26 @@ type Handlers interface {
 	ResultHandler(w http.ResponseWriter, r *http.Request)
 }
 
+// GRPCClient is the Authenticator backed by the external GAS service.
 type GRPCClient struct {
-	conn   *grpc.ClientConn
-	client auth_proto.AuthClient
+	conn       *grpc.ClientConn
+	client     auth_proto.AuthClient
+	serviceURL string
 }
 
+// NewGRPCClient connects to GAS. Its transport security is configured by the
+// GAS_TLS* variables (see grpcTransportCredentials); passwords cross this
+// connection, so use TLS outside local development.
 func NewGRPCClient(serviceURL string) (*GRPCClient, error) {
+	creds, err := grpcTransportCredentials("GAS")
+	if err != nil {
+		return nil, fmt.Errorf("invalid GAS TLS configuration: %w", err)
+	}
+
 	// Connect to the external gRPC service. The OTel stats handler creates a
 	// client span per RPC and propagates the trace context to the server.
 	conn, err := grpc.Dial(serviceURL,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(creds),
 		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
 	)
 	if err != nil {
@@ -50,11 +64,20 @@ func NewGRPCClient(serviceURL string) (*GRPCClient, error) {
 	client := auth_proto.NewAuthClient(conn)
 
 	return &GRPCClient{
-		conn:   conn,
-		client: client,
+		conn:       conn,
+		client:     client,
+		serviceURL: serviceURL,
 	}, nil
 }
 
+func (c *GRPCClient) Login(ctx context.Context, req *auth_proto.LoginRequest) (*auth_proto.LoginResponse, error) {
+	return c.client.Login(ctx, req)
+}
+
+func (c *GRPCClient) String() string {
+	return "GAS (auth service) at " + c.serviceURL
+}
+
 func (c *GRPCClient) Close() error {
 	if c.conn != nil {
 		return c.conn.Close()
@@ -63,17 +86,31 @@ func (c *GRPCClient) Close() error {
 }
 
 type Server struct {
-	log          *slog.Logger
-	paseto       *paseto.AppPaseto
-	grpc         *GRPCClient
-	indexer      *scheduleIndexer
-	httpClient   *http.Client
-	port         int
-	tokenManager *sf.TokenManager
-	db           *sql.DB
+	log             *slog.Logger
+	paseto          *paseto.AppPaseto
+	auth            Authenticator
+	authBreaker     *breaker.Breaker
+	indexer         *scheduleIndexer
+	httpClient      *http.Client
+	port            int
+	tokenManager    *sf.TokenManager
+	revocations     tokenRevocations
+	credentials     *credentialVault
+	oauthClients    oauthClientStore
+	apiKeys         *apiKeyRegistry
+	limiter         *ratelimit.Limiter
+	rateLimits      map[string]ratelimit.Limit // by tier
+	trustProxy      bool
+	legacyCutoff    time.Time // legacy tokens issued before it are refused
+	loginThrottle   *loginThrottle
+	scheduleChanges scheduleChangeStore
+	cache           resourceCache
+	revalidating    sync.Map       // username+resource -> time of last background refresh
+	revalidations   sync.WaitGroup // background refreshes in flight
+	db              *sql.DB
 }
 
-func NewServer(port int, grpc *GRPCClient) *http.Server {
+func NewServer(port int, auth Authenticator) *http.Server {
 	paseto, err := paseto.New()
 	if err != nil {
 		log.Fatalf("Failed to create paseto: %v", err)
@@ -110,6 +147,79 @@ func NewServer(port int, grpc *GRPCClient) *http.Server {
 				`CREATE INDEX IF NOT EXISTS idx_batch ON analytics(batch)`,
 				`CREATE INDEX IF NOT EXISTS idx_level ON analytics(level)`,
 				`CREATE INDEX IF NOT EXISTS idx_batch_level ON analytics(batch, level)`,
+				`CREATE TABLE IF NOT EXISTS revoked_tokens (
+					jti VARCHAR(64) NOT NULL PRIMARY KEY,
+					username VARCHAR(32) NOT NULL,
+					expires_at TIMESTAMPTZ NOT NULL,
+					revoked_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens(expires_at)`,
+				`CREATE TABLE IF NOT EXISTS credentials (
+					handle VARCHAR(64) NOT NULL PRIMARY KEY,
+					username VARCHAR(32) NOT NULL,
+					wrapped_key BYTEA NOT NULL,
+					ciphertext BYTEA NOT NULL,
+					expires_at TIMESTAMPTZ NOT NULL,
+					created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_credentials_username ON credentials(username)`,
+				`CREATE INDEX IF NOT EXISTS idx_credentials_expires_at ON credentials(expires_at)`,
+				`CREATE TABLE IF NOT EXISTS oauth_clients (
+					client_id VARCHAR(64) NOT NULL PRIMARY KEY,
+					secret_hash VARCHAR(64) NOT NULL,
+					name TEXT NOT NULL,
+					owner VARCHAR(32) NOT NULL,
+					redirect_uris TEXT[] NOT NULL,
+					scopes TEXT[] NOT NULL,
+					created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+				)`,
+				`CREATE TABLE IF NOT EXISTS api_keys (
+					id VARCHAR(32) NOT NULL PRIMARY KEY,
+					key_hash VARCHAR(64) NOT NULL UNIQUE,
+					owner VARCHAR(32) NOT NULL,
+					label TEXT NOT NULL,
+					hint VARCHAR(8) NOT NULL,
+					created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+					last_used_at TIMESTAMPTZ,
+					expires_at TIMESTAMPTZ NOT NULL,
+					revoked BOOLEAN NOT NULL DEFAULT FALSE
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_api_keys_owner ON api_keys(owner)`,
+				`ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS tier VARCHAR(16) NOT NULL DEFAULT 'standard'`,
+				`CREATE TABLE IF NOT EXISTS revoked_sessions (
+					username VARCHAR(32) NOT NULL PRIMARY KEY,
+					revoked_before TIMESTAMPTZ NOT NULL
+				)`,
+				`CREATE TABLE IF NOT EXISTS schedule_changes (
+					id BIGSERIAL PRIMARY KEY,
+					username VARCHAR(32) NOT NULL,
+					session_query TEXT NOT NULL,
+					session_name TEXT NOT NULL,
+					course_code VARCHAR(32) NOT NULL,
+					course_name TEXT NOT NULL,
+					field VARCHAR(16) NOT NULL,
+					old_value TEXT NOT NULL,
+					new_value TEXT NOT NULL,
+					detected_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_schedule_changes_username_detected_at ON schedule_changes(username, detected_at DESC)`,
+				`CREATE TABLE IF NOT EXISTS resource_cache (
+					username VARCHAR(32) NOT NULL,
+					resource VARCHAR(32) NOT NULL,
+					payload TEXT NOT NULL,
+					expires_at TIMESTAMPTZ NOT NULL,
+					updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (username, resource)
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_resource_cache_expires_at ON resource_cache(expires_at)`,
+				`CREATE TABLE IF NOT EXISTS gei_records (
+					username VARCHAR(32) NOT NULL,
+					resource VARCHAR(32) NOT NULL,
+					wrapped_key BYTEA NOT NULL,
+					ciphertext BYTEA NOT NULL,
+					updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (username, resource)
+				)`,
 			}
 
 			for _, stmt := range schema {
@@ -123,13 +233,65 @@ func NewServer(port int, grpc *GRPCClient) *http.Server {
 		}
 	}
 
-	tm := sf.NewTokenManager()
+	// Cached i-Ma'luum sessions, bounded by SESSION_CACHE_CAPACITY.
+	sessionCapacity := sf.DefaultCapacity
+	if raw := os.Getenv("SESSION_CACHE_CAPACITY"); raw != "" {
+		if sessionCapacity, err = strconv.Atoi(raw); err != nil || sessionCapacity <= 0 {
+			log.Fatalf("SESSION_CACHE_CAPACITY must be a positive integer, got %q", raw)
+			return nil
+		}
+	}
+	tm := sf.New(sessionCapacity, sf.DefaultSweepInterval)
+
+	masterKey, err := credentialMasterKey()
+	if err != nil {
+		log.Fatalf("Failed to read CREDENTIAL_MASTER_KEY: %v", err)
+		return nil
+	}
+	credentials, err := newCredentialVault(masterKey, db)
+	if err != nil {
+		log.Fatalf("Failed to create credential vault: %v", err)
+		return nil
+	}
+
+	legacyCutoff, err := legacyTokenCutoff()
+	if err != nil {
+		log.Fatalf("Failed to read LEGACY_TOKEN_CUTOFF: %v", err)
+		return nil
+	}
+
+	rateLimits, err := rateLimitTiers(os.Getenv("RATE_LIMIT_TIERS"))
+	if err != nil {
+		log.Fatalf("Failed to read RATE_LIMIT_TIERS: %v", err)
+		return nil
+	}
+
+	// Optional embedded GEI, for self-hosters without the external service.
+	geiServer, embeddedGEIAddr, embeddedGEICreds, err := serveEmbeddedGEI(db)
+	if err != nil {
+		log.Fatalf("Failed to start embedded GEI: %v", err)
+		return nil
+	}
 
 	// Optional GEI schedule cache. When GEI_SERVICE_URL is unset (or unreachable)
-	// the indexer stays nil and handlers fall back to a full scrape every time.
+	// and GEI isn't embedded, the indexer stays nil and handlers fall back to a
+	// full scrape every time.
+	// GEI_SERVICE_URL takes precedence over the embedded GEI, which is dialed
+	// with the credentials serveEmbeddedGEI picked.
 	var indexer *scheduleIndexer
-	if geiURL := os.Getenv("GEI_SERVICE_URL"); geiURL != "" {
-		idx, err := newScheduleIndexer(geiURL, os.Getenv("GEI_ADMIN_KEY"))
+	geiURL, geiCreds := embeddedGEIAddr, embeddedGEICreds
+	if url := os.Getenv("GEI_SERVICE_URL"); url != "" {
+		geiURL = url
+		// Unlike an unreachable GEI, a TLS misconfiguration is fatal: falling
+		// back would hide it.
+		geiCreds, err = grpcTransportCredentials("GEI")
+		if err != nil {
+			log.Fatalf("Invalid GEI TLS configuration: %v", err)
+			return nil
+		}
+	}
+	if geiURL != "" {
+		idx, err := newScheduleIndexer(geiURL, os.Getenv("GEI_ADMIN_KEY"), geiCreds)
 		if err != nil {
 			log.Printf("Failed to connect to GEI, schedule cache disabled: %v", err)
 		} else {
@@ -137,15 +299,29 @@ func NewServer(port int, grpc *GRPCClient) *http.Server {
 		}
 	}
 
+	guardedAuth := newGuardedAuthenticator(auth)
+
 	NewServer := &Server{
-		port:         port,
-		log:          logger.New(),
-		paseto:       paseto,
-		grpc:         grpc,
-		indexer:      indexer,
-		httpClient:   httpClient,
-		tokenManager: tm,
-		db:           db,
+		port:            port,
+		log:             logger.New(),
+		paseto:          paseto,
+		auth:            guardedAuth,
+		authBreaker:     guardedAuth.breaker,
+		indexer:         indexer,
+		httpClient:      httpClient,
+		tokenManager:    tm,
+		revocations:     newTokenRevocations(db),
+		credentials:     credentials,
+		oauthClients:    newOAuthClientStore(db),
+		apiKeys:         newAPIKeyRegistry(db, allowUnregisteredAPIKeys()),
+		limiter:         ratelimit.New(),
+		rateLimits:      rateLimits,
+		trustProxy:      trustProxyHeaders(),
+		legacyCutoff:    legacyCutoff,
+		loginThrottle:   newLoginThrottle(),
+		scheduleChanges: newScheduleChangeStore(db),
+		cache:           newResourceCache(os.Getenv("RESOURCE_CACHE"), db, indexer),
+		db:              db,
 	}
 
 	// Add cleanup for graceful shutdown
@@ -162,6 +338,9 @@ func NewServer(port int, grpc *GRPCClient) *http.Server {
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	}
+	if geiServer != nil {
+		server.RegisterOnShutdown(geiServer.GracefulStop)
+	}
 
 	return server
 }
@@ -199,14 +378,18 @@ func createHTTPClient() (*http.Client, error) {
 
 // Close closes the database connection and gRPC client connection if they exist
 func (s *Server) Close() error {
-	var dbErr, grpcErr error
+	var dbErr, authErr error
 
 	if s.db != nil {
 		dbErr = s.db.Close()
 	}
 
-	if s.grpc != nil {
-		grpcErr = s.grpc.Close()
+	if s.auth != nil {
+		authErr = s.auth.Close()
+	}
+
+	if s.tokenManager != nil {
+		s.tokenManager.Close()
 	}
 
 	if s.indexer != nil {
@@ -218,5 +401,5 @@ func (s *Server) Close() error {
 	if dbErr != nil {
 		return dbErr
 	}
-	return grpcErr
+	return authErr
 }
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nrmnqdds/gomaluum/internal/dtos"
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+	"github.com/nrmnqdds/gomaluum/pkg/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// staleRetention is how long past its expiry a cache entry is kept as the
+	// last known-good copy for when i-Ma'luum is unavailable.
+	staleRetention = 30 * 24 * time.Hour
+
+	// revalidateInterval spaces out background refreshes of the same entry, so
+	// an outage does not turn every request into another upstream hit.
+	revalidateInterval = time.Minute
+
+	// revalidateTimeout bounds a background refresh.
+	revalidateTimeout = time.Minute
+)
+
+// freshness records, for one request, the oldest last known-good copy served
+// in place of a failed scrape. A zero storedAt means everything was fresh.
+type freshness struct {
+	mu       sync.Mutex
+	storedAt time.Time
+}
+
+// withFreshness returns ctx with a fresh tracker for markStale and staleness.
+func withFreshness(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxFreshness, &freshness{})
+}
+
+// markStale records that the request is being served data scraped at
+// storedAt. It is a no-op when ctx carries no tracker.
+func markStale(ctx context.Context, storedAt time.Time) {
+	f, ok := ctx.Value(ctxFreshness).(*freshness)
+	if !ok {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.storedAt.IsZero() || storedAt.Before(f.storedAt) {
+		f.storedAt = storedAt
+	}
+}
+
+// staleness returns the stale marker for the response body, or nil when the
+// request was served fresh data. When stale it also sets the Age and Warning
+// headers, so it must be called before the body is written.
+func staleness(ctx context.Context, w http.ResponseWriter) *dtos.Staleness {
+	f, ok := ctx.Value(ctxFreshness).(*freshness)
+	if !ok {
+		return nil
+	}
+	f.mu.Lock()
+	storedAt := f.storedAt
+	f.mu.Unlock()
+	if storedAt.IsZero() {
+		return nil
+	}
+
+	age := int64(time.Since(storedAt).Seconds())
+	w.Header().Set("Age", strconv.FormatInt(age, 10))
+	w.Header().Set("Warning", `110 - "Response is Stale"`)
+	return &dtos.Staleness{
+		CachedAt: storedAt.Unix(),
+		Age:      age,
+	}
+}
+
+// isUpstreamFailure reports whether err means i-Ma'luum (or the login service
+// in front of it) is down or blocking us, as opposed to a problem with the
+// user's data or request.
+func isUpstreamFailure(err error) bool {
+	if _, ok := err.(*errors.CustomError); ok {
+		return errors.Is(err, errors.ErrUpstreamForbidden) ||
+			errors.Is(err, errors.ErrFailedToGoToURL) ||
+			errors.Is(err, errors.ErrAuthServiceUnavailable)
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// revalidateResource re-scrapes resource in the background after a stale
+// fallback and caches the result if i-Ma'luum has recovered. Attempts for the
+// same entry are at least revalidateInterval apart; s.revalidations tracks
+// those in flight.
+func revalidateResource[T any](ctx context.Context, s *Server, sess *TokenPayload, resource string, scrape func(context.Context) (T, error)) {
+	key := sess.username + "\x00" + resource
+	now := time.Now()
+	// Claim the attempt; of concurrent fallbacks, only one gets it.
+	if last, loaded := s.revalidating.LoadOrStore(key, now); loaded {
+		if now.Sub(last.(time.Time)) < revalidateInterval || !s.revalidating.CompareAndSwap(key, last, now) {
+			return
+		}
+	}
+
+	// Detach from the request, which is about to complete.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), revalidateTimeout)
+	s.revalidations.Add(1)
+	go func() {
+		defer s.revalidations.Done()
+		defer cancel()
+		defer utils.CatchPanic("revalidate " + resource)
+
+		value, err := scrape(ctx)
+		if err != nil {
+			s.log.DebugContext(ctx, "Background refresh failed", "resource", resource, "error", err)
+			return
+		}
+		if err := storeResource(ctx, s.cache, sess, resource, value, resourceTTLs[resource]); err != nil {
+			s.log.WarnContext(ctx, "Failed to write resource cache", "resource", resource, "error", err)
+			return
+		}
+		s.revalidating.CompareAndDelete(key, now)
+	}()
+}
@@ -1,7 +1,7 @@
 package server
 
 import (
-	"fmt"
+	"context"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -12,10 +12,10 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/bytedance/sonic"
 	"github.com/gocolly/colly/v2"
-	"github.com/lucsky/cuid"
 	"github.com/nrmnqdds/gomaluum/internal/constants"
 	"github.com/nrmnqdds/gomaluum/internal/dtos"
 	"github.com/nrmnqdds/gomaluum/internal/errors"
+	"github.com/nrmnqdds/gomaluum/pkg/utils"
 )
 
 // Object pools for memory reuse
@@ -132,7 +132,11 @@ func parseProgramRows(tds []string, programs *[]dtos.StarpointProgram, mu *sync.
 	}
 
 	if program != nil {
-		program.ID = fmt.Sprintf("gomaluum:program:%s", cuid.Slug())
+		programType := ""
+		if program.Type != nil {
+			programType = *program.Type
+		}
+		program.ID = utils.StableID("program", program.Session, program.EventName, programType, program.Level, strconv.FormatFloat(float64(program.Points), 'f', -1, 32))
 
 		mu.Lock()
 		*programs = append(*programs, *program)
@@ -153,17 +157,9 @@ func getFloatFromString(s string) float64 {
 	return points
 }
 
-// @Title StarpointHandler
-// @Description Get co-curricular from i-Ma'luum
-// @Tags scraper
-// @Produce json
-// @Param x-gomaluum-key header string false "API key for additional security layer"
-// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
-// @Success 200 {object} dtos.ResponseDTO
-// @Router /api/starpoint [get]
-func (s *Server) StarpointHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
+// fetchStarpoint scrapes the request user's co-curricular programmes. It
+// returns ErrNoStarpoint when i-Ma'luum lists none.
+func (s *Server) fetchStarpoint(ctx context.Context) (*dtos.Starpoint, error) {
 	var (
 		logger      = s.log
 		mu          sync.Mutex
@@ -172,7 +168,7 @@ func (s *Server) StarpointHandler(w http.ResponseWriter, r *http.Request) {
 		lastSession string
 	)
 
-	if err := s.scrapeWithRetry(r.Context(), func(cookie string) (bool, error) {
+	if err := s.scrapeWithRetry(ctx, func(cookie string) (bool, error) {
 		// Reset accumulators so a retry starts clean.
 		mu.Lock()
 		programs = programs[:0]
@@ -182,7 +178,7 @@ func (s *Server) StarpointHandler(w http.ResponseWriter, r *http.Request) {
 		mu.Unlock()
 
 		var stale atomic.Bool
-		c := s.newImaluumCollector(r.Context(), cookie, &stale)
+		c := s.newImaluumCollector(ctx, cookie, &stale)
 
 		c.OnHTML("table.table.table-hover tbody tr", func(e *colly.HTMLElement) {
 			// Get all text at once with efficient DOM traversal
@@ -228,24 +224,48 @@ func (s *Server) StarpointHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return stale.Load(), nil
 	}); err != nil {
-		logger.ErrorContext(r.Context(), "Failed to scrape starpoints", "error", err)
-		errors.Render(w, r, err)
-		return
+		return nil, err
 	}
 
 	if len(programs) == 0 {
-		logger.ErrorContext(r.Context(), "Program is empty")
-		errors.Render(w, r, errors.ErrNoStarpoint)
-		return
+		return nil, errors.ErrNoStarpoint
 	}
 
 	// Set starpoint data
 	starpoint.Programs = programs
-	starpoint.ID = fmt.Sprintf("gomaluum:starpoint:%s", cuid.Slug())
+	starpoint.ID = utils.StableID("starpoint", requestUsername(ctx))
+	return starpoint, nil
+}
+
+// @Title StarpointHandler
+// @Description Get co-curricular from i-Ma'luum. Served from cache for up to 6 hours unless refresh is set.
+// @Tags scraper
+// @Produce json
+// @Param x-gomaluum-key header string false "API key for additional security layer"
+// @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
+// @Param refresh query bool false "Bypass the cache and re-scrape"
+// @Success 200 {object} dtos.ResponseDTO
+// @Router /api/starpoint [get]
+func (s *Server) StarpointHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	logger := s.log
+
+	starpoint, err := cachedResource(r.Context(), s, starpointResource, r.URL.Query().Has("refresh"), s.fetchStarpoint)
+	if err != nil {
+		if err == errors.ErrNoStarpoint {
+			logger.ErrorContext(r.Context(), "Program is empty")
+		} else {
+			logger.ErrorContext(r.Context(), "Failed to scrape starpoints", "error", err)
+		}
+		errors.Render(w, r, err)
+		return
+	}
 
 	response := &dtos.ResponseDTO{
 		Message: "Successfully fetched starpoints programs",
 		Data:    starpoint,
+		Stale:   staleness(r.Context(), w),
 	}
 
 	if err := sonic.ConfigFastest.NewEncoder(w).Encode(response); err != nil {
@@ -116,18 +116,13 @@ func main() {
 		}
 	}()
 
-	// Get the GAS (auth service) gRPC URL from environment
-	gasServiceURL := os.Getenv("GAS_SERVICE_URL")
-	if gasServiceURL == "" {
-		log.Fatal("GAS_SERVICE_URL environment variable is required")
-	}
-
-	// Initialize gRPC client connection to external service
-	grpcClient, err := server.NewGRPCClient(gasServiceURL)
+	// Log in through GAS (auth service) or the built-in CAS login,
+	// depending on AUTHENTICATOR and GAS_SERVICE_URL
+	authenticator, err := server.NewAuthenticator()
 	if err != nil {
-		log.Fatalf("failed to connect to gRPC service: %v", err)
+		log.Fatalf("failed to set up authenticator: %v", err)
 	}
-	defer grpcClient.Close()
+	defer authenticator.Close()
 
 	port, err := strconv.Atoi(os.Getenv("PORT"))
 	if err != nil {
@@ -135,7 +130,7 @@ func main() {
 	}
 
 	server.DocsPath = DocsPath
-	httpServer := server.NewServer(port, grpcClient)
+	httpServer := server.NewServer(port, authenticator)
 
 	// Create channels to track server status
 	done := make(chan bool, 1)
@@ -155,8 +150,8 @@ func main() {
 		`))
 
 	fmt.Println(gchalk.Yellow("====================================================="))
-	fmt.Println(gchalk.Green(fmt.Sprintf("Connected to GAS (auth service) at %s", gasServiceURL)))
-	slog.Info("Connected to GAS (auth service)", "url", gasServiceURL)
+	fmt.Println(gchalk.Green(fmt.Sprintf("Logging in through %s", authenticator)))
+	slog.Info("Logging in through authenticator", "authenticator", fmt.Sprint(authenticator))
 
 	// Start HTTP server
 	go func() {
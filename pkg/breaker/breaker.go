@@ -0,0 +1,130 @@
+// Package breaker implements a circuit breaker. After Threshold consecutive
+// failures the circuit opens and calls are refused for Cooldown; then a single
+// probe call is let through (half-open), whose outcome closes the circuit or
+// opens it again. State is kept in memory, per instance.
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// Snapshot is the breaker's state at one moment.
+type Snapshot struct {
+	State    State
+	Failures int       // consecutive failures
+	Until    time.Time // when an open circuit lets a probe through; zero otherwise
+}
+
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu           sync.Mutex
+	state        State
+	failures     int
+	openedAt     time.Time
+	probeStarted time.Time // zero when no probe is in flight
+	now          func() time.Time
+}
+
+// New returns a closed breaker that opens after threshold consecutive
+// failures and stays open for cooldown.
+func New(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// Allow reports whether a call may go ahead. Every allowed call must report
+// its outcome with Success or Failure. A probe that never reports is given up
+// on after the cooldown, and another is let through.
+func (b *Breaker) Allow() bool {
+	now := b.now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = HalfOpen
+	case HalfOpen:
+		if !b.probeStarted.IsZero() && now.Sub(b.probeStarted) < b.cooldown {
+			return false
+		}
+	default:
+		return true
+	}
+	b.probeStarted = now
+	return true
+}
+
+// Success records a successful call, closing the circuit.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = Closed
+	b.failures = 0
+	b.probeStarted = time.Time{}
+}
+
+// Failure records a failed call. A failed probe opens the circuit again.
+// Failures of calls allowed before the circuit opened are counted but do not
+// extend the cooldown.
+func (b *Breaker) Failure() {
+	now := b.now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	switch b.state {
+	case HalfOpen:
+		b.open(now)
+	case Closed:
+		if b.failures >= b.threshold {
+			b.open(now)
+		}
+	}
+}
+
+func (b *Breaker) open(now time.Time) {
+	b.state = Open
+	b.openedAt = now
+	b.probeStarted = time.Time{}
+}
+
+func (b *Breaker) Snapshot() Snapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	snap := Snapshot{State: b.state, Failures: b.failures}
+	if b.state == Open {
+		snap.Until = b.openedAt.Add(b.cooldown)
+	}
+	return snap
+}
@@ -0,0 +1,45 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	b := New(3, time.Minute)
+	b.now = func() time.Time { return now }
+
+	for range 2 {
+		require.True(t, b.Allow())
+		b.Failure()
+	}
+	require.True(t, b.Allow())
+	b.Success()
+	require.Equal(t, Snapshot{State: Closed}, b.Snapshot(), "a success resets the count")
+
+	for range 3 {
+		require.True(t, b.Allow())
+		b.Failure()
+	}
+	require.Equal(t, Snapshot{State: Open, Failures: 3, Until: now.Add(time.Minute)}, b.Snapshot())
+	require.False(t, b.Allow())
+
+	now = now.Add(time.Minute)
+	require.True(t, b.Allow(), "one probe after the cooldown")
+	require.Equal(t, HalfOpen, b.Snapshot().State)
+	require.False(t, b.Allow(), "only one probe at a time")
+	b.Failure()
+	require.Equal(t, Open, b.Snapshot().State, "a failed probe reopens the circuit")
+	require.False(t, b.Allow())
+
+	now = now.Add(time.Minute)
+	require.True(t, b.Allow())
+	now = now.Add(time.Minute)
+	require.True(t, b.Allow(), "a probe that never reported is given up on")
+	b.Success()
+	require.Equal(t, Snapshot{State: Closed}, b.Snapshot())
+	require.True(t, b.Allow())
+}
@@ -0,0 +1,164 @@
+// Package ics writes RFC 5545 iCalendar documents. It covers only what
+// gomaluum publishes (VCALENDAR with VEVENTs, weekly recurrences and
+// exclusions), not the full specification.
+package ics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// utcLayout is the RFC 5545 "form #2" DATE-TIME in UTC. i-Ma'luum times are
+// Asia/Kuala_Lumpur, which has no DST, so UTC timestamps recur correctly and
+// spare us from emitting a VTIMEZONE block.
+const utcLayout = "20060102T150405Z"
+
+// maxLineOctets is the RFC 5545 content line limit, excluding the CRLF.
+const maxLineOctets = 75
+
+// Event is a single VEVENT. RRule is the raw recurrence value (without the
+// "RRULE:" prefix), e.g. "FREQ=WEEKLY;UNTIL=20260116T155959Z".
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+	RRule       string
+	ExDates     []time.Time
+}
+
+// Calendar is a VCALENDAR. Stamp is written as every event's DTSTAMP and
+// defaults to the time of encoding. A non-zero RefreshInterval tells
+// subscribing clients how often to poll the feed (RFC 7986 REFRESH-INTERVAL,
+// plus the X-PUBLISHED-TTL that Outlook and Google Calendar read).
+type Calendar struct {
+	ProdID          string
+	Name            string
+	Stamp           time.Time
+	RefreshInterval time.Duration
+	Events          []Event
+}
+
+// Encode writes c to w as an iCalendar stream with CRLF line endings and
+// folded long lines.
+func (c *Calendar) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := &encoder{w: bw}
+
+	stamp := c.Stamp
+	if stamp.IsZero() {
+		stamp = time.Now()
+	}
+
+	enc.line("BEGIN:VCALENDAR")
+	enc.line("VERSION:2.0")
+	enc.line("PRODID:" + c.ProdID)
+	enc.line("CALSCALE:GREGORIAN")
+	enc.line("METHOD:PUBLISH")
+	if c.Name != "" {
+		enc.line("X-WR-CALNAME:" + escapeText(c.Name))
+	}
+	if c.RefreshInterval > 0 {
+		enc.line("REFRESH-INTERVAL;VALUE=DURATION:" + formatDuration(c.RefreshInterval))
+		enc.line("X-PUBLISHED-TTL:" + formatDuration(c.RefreshInterval))
+	}
+
+	for _, e := range c.Events {
+		enc.line("BEGIN:VEVENT")
+		enc.line("UID:" + e.UID)
+		enc.line("DTSTAMP:" + formatTime(stamp))
+		enc.line("DTSTART:" + formatTime(e.Start))
+		enc.line("DTEND:" + formatTime(e.End))
+		enc.line("SUMMARY:" + escapeText(e.Summary))
+		if e.Location != "" {
+			enc.line("LOCATION:" + escapeText(e.Location))
+		}
+		if e.Description != "" {
+			enc.line("DESCRIPTION:" + escapeText(e.Description))
+		}
+		if e.RRule != "" {
+			enc.line("RRULE:" + e.RRule)
+		}
+		for _, ex := range e.ExDates {
+			enc.line("EXDATE:" + formatTime(ex))
+		}
+		enc.line("END:VEVENT")
+	}
+
+	enc.line("END:VCALENDAR")
+
+	if enc.err != nil {
+		return enc.err
+	}
+	return bw.Flush()
+}
+
+// FormatTime renders t as an RFC 5545 UTC DATE-TIME, for use in RRULE UNTIL.
+func FormatTime(t time.Time) string {
+	return formatTime(t)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(utcLayout)
+}
+
+// formatDuration renders d as an RFC 5545 DURATION in whole hours or minutes.
+func formatDuration(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("PT%dH", d/time.Hour)
+	}
+	return fmt.Sprintf("PT%dM", d.Round(time.Minute)/time.Minute)
+}
+
+// escapeText escapes a TEXT property value per RFC 5545 section 3.3.11.
+func escapeText(s string) string {
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	)
+	return r.Replace(s)
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line writes one content line, folding it at 75 octets with CRLF followed by
+// a single space. It never splits a multi-byte UTF-8 sequence.
+func (e *encoder) line(s string) {
+	if e.err != nil {
+		return
+	}
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(s[cut]) {
+			cut--
+		}
+		e.write(s[:cut] + "\r\n ")
+		s = s[cut:]
+		// Continuation lines lose one octet to the leading space.
+		limit = maxLineOctets - 1
+	}
+	e.write(s + "\r\n")
+}
+
+func (e *encoder) write(s string) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.WriteString(s)
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
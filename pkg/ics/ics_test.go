@@ -0,0 +1,74 @@
+package ics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	kl := time.FixedZone("MYT", 8*60*60)
+	cal := &Calendar{
+		ProdID: "-//gomaluum//schedule//EN",
+		Name:   "Schedule",
+		Stamp:  time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC),
+		Events: []Event{{
+			UID:      "abc@gomaluum",
+			Summary:  "INFO4335 Software Engineering",
+			Location: "E1-LT4, KICT",
+			Start:    time.Date(2025, 10, 6, 9, 0, 0, 0, kl),
+			End:      time.Date(2025, 10, 6, 11, 0, 0, 0, kl),
+			RRule:    "FREQ=WEEKLY;UNTIL=20260116T155959Z",
+			ExDates:  []time.Time{time.Date(2025, 11, 24, 9, 0, 0, 0, kl)},
+		}},
+	}
+
+	var b strings.Builder
+	require.NoError(t, cal.Encode(&b))
+	out := b.String()
+
+	require.True(t, strings.HasPrefix(out, "BEGIN:VCALENDAR\r\n"))
+	require.True(t, strings.HasSuffix(out, "END:VCALENDAR\r\n"))
+	require.Contains(t, out, "DTSTART:20251006T010000Z\r\n")
+	require.Contains(t, out, "DTEND:20251006T030000Z\r\n")
+	require.Contains(t, out, "DTSTAMP:20251001T000000Z\r\n")
+	require.Contains(t, out, "LOCATION:E1-LT4\\, KICT\r\n")
+	require.Contains(t, out, "RRULE:FREQ=WEEKLY;UNTIL=20260116T155959Z\r\n")
+	require.Contains(t, out, "EXDATE:20251124T010000Z\r\n")
+}
+
+func TestEncodeRefreshInterval(t *testing.T) {
+	cal := &Calendar{ProdID: "p", RefreshInterval: 6 * time.Hour}
+
+	var b strings.Builder
+	require.NoError(t, cal.Encode(&b))
+	require.Contains(t, b.String(), "REFRESH-INTERVAL;VALUE=DURATION:PT6H\r\n")
+	require.Contains(t, b.String(), "X-PUBLISHED-TTL:PT6H\r\n")
+
+	require.Equal(t, "PT90M", formatDuration(90*time.Minute))
+}
+
+func TestEscapeText(t *testing.T) {
+	require.Equal(t, `a\;b\,c\\d\ne`, escapeText("a;b,c\\d\ne"))
+}
+
+func TestLineFolding(t *testing.T) {
+	cal := &Calendar{
+		ProdID: "p",
+		Events: []Event{{
+			UID:     "u",
+			Summary: strings.Repeat("é", 60), // 120 octets
+		}},
+	}
+
+	var b strings.Builder
+	require.NoError(t, cal.Encode(&b))
+
+	for line := range strings.SplitSeq(strings.TrimSuffix(b.String(), "\r\n"), "\r\n") {
+		require.LessOrEqual(t, len(line), 75)
+		require.True(t, isRuneStart(strings.TrimPrefix(line, " ")[0]), "fold split a rune: %q", line)
+	}
+	require.Contains(t, b.String(), "\r\n ")
+}
@@ -1,19 +1,45 @@
 package paseto
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"log"
 	"os"
+	"strings"
 
 	"aidanwoods.dev/go-paseto"
+	"github.com/bytedance/sonic"
 	"github.com/nrmnqdds/gomaluum/internal/errors"
 )
 
 type AppPaseto struct {
+	// PublicKey and PrivateKey are the current signing keypair. Every signed
+	// token names it by KeyID in its footer.
 	PublicKey  *paseto.V4AsymmetricPublicKey
 	PrivateKey *paseto.V4AsymmetricSecretKey
-	Token      *paseto.Token
+	KeyID      string
+	// VerificationKeys are the public keys signed tokens are accepted from, by
+	// key ID: the current key plus retired keys whose tokens may still be live.
+	VerificationKeys map[string]paseto.V4AsymmetricPublicKey
+	// LocalKey encrypts v4.local tokens whose claims must stay confidential
+	// (refresh and feed tokens, authorization codes). It is configured
+	// separately from the signing keys, so rotating those leaves it alone.
+	LocalKey *paseto.V4SymmetricKey
 }
 
+// tokenFooter is the (unencrypted, authenticated) footer of signed tokens.
+type tokenFooter struct {
+	KeyID string `json:"kid"`
+}
+
+// New loads the keyring from the environment: the signing keypair from
+// PASETO_PUBLIC_KEY/PASETO_SECRET_KEY, retired public keys still accepted for
+// verification from PASETO_VERIFICATION_KEYS (comma-separated hex) and the
+// required PASETO_LOCAL_KEY (hex).
+//
+// To rotate, move the current public key to PASETO_VERIFICATION_KEYS, set the
+// new keypair, and drop the old key once its tokens have expired.
 func New() (*AppPaseto, error) {
 	publicKey, err := paseto.NewV4AsymmetricPublicKeyFromHex(os.Getenv("PASETO_PUBLIC_KEY"))
 	if err != nil {
@@ -27,11 +53,88 @@ func New() (*AppPaseto, error) {
 		return nil, errors.ErrFailedToCreatePASETOPrivateKey
 	}
 
-	token := paseto.NewToken()
+	if !bytes.Equal(publicKey.ExportBytes(), privateKey.Public().ExportBytes()) {
+		log.Fatalf("PASETO_PUBLIC_KEY does not match PASETO_SECRET_KEY")
+		return nil, errors.ErrFailedToCreatePASETOPublicKey
+	}
+
+	var retired []paseto.V4AsymmetricPublicKey
+	for _, hexKey := range strings.Split(os.Getenv("PASETO_VERIFICATION_KEYS"), ",") {
+		if hexKey = strings.TrimSpace(hexKey); hexKey == "" {
+			continue
+		}
+		key, err := paseto.NewV4AsymmetricPublicKeyFromHex(hexKey)
+		if err != nil {
+			log.Fatalf("Failed to create verification key: %v", err)
+			return nil, errors.ErrFailedToCreatePASETOPublicKey
+		}
+		retired = append(retired, key)
+	}
+
+	// Not derived from the signing key: that would tie refresh and feed tokens
+	// to it and end them at every rotation.
+	localKey, err := paseto.V4SymmetricKeyFromHex(os.Getenv("PASETO_LOCAL_KEY"))
+	if err != nil {
+		log.Fatalf("Failed to create local key, PASETO_LOCAL_KEY must be 64 hex characters: %v", err)
+		return nil, errors.ErrFailedToCreatePASETOPrivateKey
+	}
+
+	return NewKeyring(privateKey, localKey, retired...), nil
+}
+
+// NewKeyring returns an AppPaseto signing with secret and also accepting
+// tokens signed by the retired public keys.
+func NewKeyring(secret paseto.V4AsymmetricSecretKey, local paseto.V4SymmetricKey, retired ...paseto.V4AsymmetricPublicKey) *AppPaseto {
+	public := secret.Public()
+	keyID := KeyID(public)
+
+	keys := map[string]paseto.V4AsymmetricPublicKey{keyID: public}
+	for _, key := range retired {
+		keys[KeyID(key)] = key
+	}
 
 	return &AppPaseto{
-		PublicKey:  &publicKey,
-		PrivateKey: &privateKey,
-		Token:      &token,
-	}, nil
+		PublicKey:        &public,
+		PrivateKey:       &secret,
+		KeyID:            keyID,
+		VerificationKeys: keys,
+		LocalKey:         &local,
+	}
+}
+
+// KeyID returns the ID of a public key: a truncated SHA-256 of the key, so it
+// is stable across restarts and needs no configuration.
+func KeyID(key paseto.V4AsymmetricPublicKey) string {
+	sum := sha256.Sum256(key.ExportBytes())
+	return hex.EncodeToString(sum[:8])
+}
+
+// Footer returns the footer for tokens signed with the current key.
+func (p *AppPaseto) Footer() []byte {
+	footer, _ := sonic.ConfigFastest.Marshal(tokenFooter{KeyID: p.KeyID})
+	return footer
+}
+
+// VerificationKey returns the public key a signed token names in its footer.
+// The footer is only trusted to pick the key: verifying the signature with it
+// authenticates the footer too. Tokens without a footer predate the keyring
+// and are checked against the current key.
+func (p *AppPaseto) VerificationKey(token string) (paseto.V4AsymmetricPublicKey, error) {
+	raw, err := paseto.NewParser().UnsafeParseFooter(paseto.V4Public, token)
+	if err != nil {
+		return paseto.V4AsymmetricPublicKey{}, err
+	}
+	if len(raw) == 0 {
+		return *p.PublicKey, nil
+	}
+
+	var footer tokenFooter
+	if err := sonic.ConfigFastest.Unmarshal(raw, &footer); err != nil {
+		return paseto.V4AsymmetricPublicKey{}, errors.ErrUnknownPASETOKeyID
+	}
+	key, ok := p.VerificationKeys[footer.KeyID]
+	if !ok {
+		return paseto.V4AsymmetricPublicKey{}, errors.ErrUnknownPASETOKeyID
+	}
+	return key, nil
 }
@@ -0,0 +1,124 @@
+// Package ratelimit limits requests per subject with a token bucket and a
+// daily quota. State is kept in memory, so each instance limits on its own.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle subjects are forgotten.
+const sweepInterval = 10 * time.Minute
+
+// Limit is a subject's allowance: bursts of up to Burst requests, refilled
+// at Rate requests per second, and at most Daily requests per UTC day.
+// Daily 0 means no quota.
+type Limit struct {
+	Rate  float64
+	Burst int
+	Daily int
+}
+
+// Window is how long an empty bucket takes to refill.
+func (l Limit) Window() time.Duration {
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+// Result is the outcome of a request. Limit, Remaining and Reset describe
+// whichever of the bucket and the quota is closer to running out.
+type Result struct {
+	Allowed    bool
+	Quota      bool // the daily quota, rather than the bucket, is the constraint
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // until Remaining is back to Limit
+	RetryAfter time.Duration // until the next request is allowed; 0 if Allowed
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	day    time.Time // start of the UTC day used counts
+	used   int
+}
+
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func New() *Limiter {
+	return &Limiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes one request from subject's allowance, if there is one left.
+func (l *Limiter) Allow(subject string, limit Limit) Result {
+	now := l.now()
+	today := now.UTC().Truncate(24 * time.Hour)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[subject]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now, day: today}
+		l.buckets[subject] = b
+	}
+
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+	if b.day.Before(today) {
+		b.day, b.used = today, 0
+	}
+
+	quotaLeft := limit.Daily > 0 && b.used < limit.Daily
+	allowed := b.tokens >= 1 && (limit.Daily == 0 || quotaLeft)
+	if allowed {
+		b.tokens--
+		b.used++
+	}
+
+	untilFull := time.Duration((float64(limit.Burst) - b.tokens) / limit.Rate * float64(time.Second))
+	result := Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(b.tokens),
+		Reset:     untilFull,
+	}
+	if limit.Daily > 0 && limit.Daily-b.used <= result.Remaining {
+		result.Quota = true
+		result.Limit = limit.Daily
+		result.Remaining = limit.Daily - b.used
+		result.Reset = today.Add(24 * time.Hour).Sub(now)
+	}
+	if !allowed {
+		if result.Quota {
+			result.RetryAfter = result.Reset
+		} else {
+			result.RetryAfter = time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+		}
+	}
+	return result
+}
+
+// sweep forgets subjects idle for a day: their bucket is full again and their
+// quota has reset.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for subject, b := range l.buckets {
+		if now.Sub(b.last) > 24*time.Hour {
+			delete(l.buckets, subject)
+		}
+	}
+}
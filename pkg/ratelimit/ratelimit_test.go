@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllow(t *testing.T) {
+	now := time.Date(2025, 3, 1, 23, 59, 0, 0, time.UTC)
+	l := New()
+	l.now = func() time.Time { return now }
+	limit := Limit{Rate: 1, Burst: 2, Daily: 4}
+
+	require.True(t, l.Allow("a", limit).Allowed)
+	res := l.Allow("a", limit)
+	require.True(t, res.Allowed)
+	require.Equal(t, 0, res.Remaining)
+	require.False(t, res.Quota)
+
+	res = l.Allow("a", limit)
+	require.False(t, res.Allowed, "the burst is spent")
+	require.Equal(t, time.Second, res.RetryAfter)
+	require.True(t, l.Allow("b", limit).Allowed, "subjects are limited separately")
+
+	now = now.Add(2 * time.Second)
+	res = l.Allow("a", limit)
+	require.True(t, res.Allowed)
+	require.True(t, res.Quota, "one request left today is tighter than the bucket")
+	require.Equal(t, 4, res.Limit)
+	require.Equal(t, 1, res.Remaining)
+	require.True(t, l.Allow("a", limit).Allowed)
+
+	now = now.Add(time.Minute / 2)
+	res = l.Allow("a", limit)
+	require.False(t, res.Allowed, "the daily quota is spent")
+	require.True(t, res.Quota)
+	require.Equal(t, 28*time.Second, res.RetryAfter, "quotas reset at midnight UTC")
+
+	now = now.Add(res.RetryAfter)
+	require.True(t, l.Allow("a", limit).Allowed)
+}
+
+func TestSweep(t *testing.T) {
+	now := time.Now()
+	l := New()
+	l.now = func() time.Time { return now }
+
+	l.Allow("a", Limit{Rate: 1, Burst: 1})
+	now = now.Add(25 * time.Hour)
+	l.Allow("b", Limit{Rate: 1, Burst: 1})
+	require.NotContains(t, l.buckets, "a")
+	require.Contains(t, l.buckets, "b")
+}
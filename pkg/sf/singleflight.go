@@ -1,27 +1,95 @@
+// Package sf caches i-Ma'luum session cookies per student. Concurrent logins
+// for the same student collapse into one (singleflight). The cache holds at
+// most a fixed number of sessions, evicting the least recently used, and a
+// background sweeper drops expired ones.
 package sf
 
 import (
+	"container/list"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/sync/singleflight"
 )
 
+const (
+	// DefaultCapacity bounds the sessions NewTokenManager keeps.
+	DefaultCapacity = 10000
+
+	// DefaultSweepInterval is how often NewTokenManager drops expired sessions.
+	DefaultSweepInterval = time.Minute
+)
+
 type TokenEntry struct {
 	Token  string
 	Expiry time.Time
 }
 
+// LiveEntry describes a cached session for operators, without its token.
+type LiveEntry struct {
+	Key      string
+	Expiry   time.Time
+	LastUsed time.Time
+}
+
+// Stats are the manager's size and counters since it was created.
+type Stats struct {
+	Cached        int // including expired sessions not yet swept
+	Capacity      int
+	Hits          uint64 // GetToken calls served from the cache
+	Misses        uint64 // GetToken calls that waited on a refresh
+	Refreshes     uint64 // refreshFunc calls
+	RefreshErrors uint64
+	Evictions     uint64 // sessions dropped to stay within capacity
+	Expirations   uint64 // expired sessions dropped
+}
+
+type tokenItem struct {
+	key      string
+	entry    TokenEntry
+	lastUsed time.Time
+}
+
 type TokenManager struct {
-	mu     sync.RWMutex
-	tokens map[string]*TokenEntry
+	capacity int
+
+	mu     sync.Mutex
+	tokens map[string]*list.Element // of *tokenItem
+	lru    *list.List               // most recently used first
 	sf     singleflight.Group
+	now    func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	hits, misses, refreshes, refreshErrors, evictions, expirations atomic.Uint64
 }
 
+// NewTokenManager returns a manager with DefaultCapacity and
+// DefaultSweepInterval.
 func NewTokenManager() *TokenManager {
-	return &TokenManager{
-		tokens: make(map[string]*TokenEntry),
+	return New(DefaultCapacity, DefaultSweepInterval)
+}
+
+// New returns a manager holding at most capacity sessions (DefaultCapacity if
+// not positive) and sweeping expired ones every sweepInterval (never if not
+// positive). Close stops the sweeper.
+func New(capacity int, sweepInterval time.Duration) *TokenManager {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	tm := &TokenManager{
+		capacity: capacity,
+		tokens:   make(map[string]*list.Element),
+		lru:      list.New(),
+		now:      time.Now,
+		stop:     make(chan struct{}),
+	}
+	if sweepInterval > 0 {
+		go tm.sweepEvery(sweepInterval)
 	}
+	return tm
 }
 
 func (tm *TokenManager) GetToken(
@@ -29,39 +97,28 @@ func (tm *TokenManager) GetToken(
 	refreshFunc func() (string, time.Time, error),
 ) (string, error) {
 	// Step 1: check if we already have a valid token
-	tm.mu.RLock()
-	entry, ok := tm.tokens[matric]
-	if ok && time.Now().Before(entry.Expiry) {
-		defer tm.mu.RUnlock()
-		return entry.Token, nil
+	if token, ok := tm.lookup(matric); ok {
+		tm.hits.Add(1)
+		return token, nil
 	}
-	tm.mu.RUnlock()
+	tm.misses.Add(1)
 
 	// Step 2: collapse concurrent refresh for the SAME matric
 	v, err, _ := tm.sf.Do(matric, func() (any, error) {
 		// double-check after winning singleflight
-		tm.mu.RLock()
-		entry, ok := tm.tokens[matric]
-		if ok && time.Now().Before(entry.Expiry) {
-			defer tm.mu.RUnlock()
-			return entry.Token, nil
+		if token, ok := tm.lookup(matric); ok {
+			return token, nil
 		}
-		tm.mu.RUnlock()
 
 		// refresh
+		tm.refreshes.Add(1)
 		token, expiry, err := refreshFunc()
 		if err != nil {
+			tm.refreshErrors.Add(1)
 			return "", err
 		}
 
-		// update safely
-		tm.mu.Lock()
-		tm.tokens[matric] = &TokenEntry{
-			Token:  token,
-			Expiry: expiry,
-		}
-		tm.mu.Unlock()
-
+		tm.store(matric, TokenEntry{Token: token, Expiry: expiry})
 		return token, nil
 	})
 
@@ -71,9 +128,130 @@ func (tm *TokenManager) GetToken(
 	return v.(string), nil
 }
 
+// lookup returns matric's token if it is cached and unexpired, marking it
+// used. An expired one is dropped.
+func (tm *TokenManager) lookup(matric string) (string, bool) {
+	now := tm.now()
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	el, ok := tm.tokens[matric]
+	if !ok {
+		return "", false
+	}
+	item := el.Value.(*tokenItem)
+	if !now.Before(item.entry.Expiry) {
+		tm.remove(el)
+		tm.expirations.Add(1)
+		return "", false
+	}
+	item.lastUsed = now
+	tm.lru.MoveToFront(el)
+	return item.entry.Token, true
+}
+
+// store caches entry for matric, evicting the least recently used sessions
+// beyond capacity.
+func (tm *TokenManager) store(matric string, entry TokenEntry) {
+	now := tm.now()
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if el, ok := tm.tokens[matric]; ok {
+		item := el.Value.(*tokenItem)
+		item.entry, item.lastUsed = entry, now
+		tm.lru.MoveToFront(el)
+		return
+	}
+	tm.tokens[matric] = tm.lru.PushFront(&tokenItem{key: matric, entry: entry, lastUsed: now})
+	for tm.lru.Len() > tm.capacity {
+		tm.remove(tm.lru.Back())
+		tm.evictions.Add(1)
+	}
+}
+
+func (tm *TokenManager) remove(el *list.Element) {
+	tm.lru.Remove(el)
+	delete(tm.tokens, el.Value.(*tokenItem).key)
+}
+
 // Invalidate removes a cached token so the next GetToken re-runs refreshFunc.
 func (tm *TokenManager) Invalidate(matric string) {
 	tm.mu.Lock()
-	delete(tm.tokens, matric)
+	defer tm.mu.Unlock()
+
+	if el, ok := tm.tokens[matric]; ok {
+		tm.remove(el)
+	}
+}
+
+func (tm *TokenManager) sweepEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-tm.stop:
+			return
+		case <-ticker.C:
+			tm.sweep()
+		}
+	}
+}
+
+// sweep drops expired sessions.
+func (tm *TokenManager) sweep() {
+	now := tm.now()
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	for el := tm.lru.Front(); el != nil; {
+		next := el.Next()
+		if !now.Before(el.Value.(*tokenItem).entry.Expiry) {
+			tm.remove(el)
+			tm.expirations.Add(1)
+		}
+		el = next
+	}
+}
+
+// Entries lists the unexpired sessions, most recently used first.
+func (tm *TokenManager) Entries() []LiveEntry {
+	now := tm.now()
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	entries := make([]LiveEntry, 0, tm.lru.Len())
+	for el := tm.lru.Front(); el != nil; el = el.Next() {
+		item := el.Value.(*tokenItem)
+		if now.Before(item.entry.Expiry) {
+			entries = append(entries, LiveEntry{Key: item.key, Expiry: item.entry.Expiry, LastUsed: item.lastUsed})
+		}
+	}
+	return entries
+}
+
+func (tm *TokenManager) Stats() Stats {
+	tm.mu.Lock()
+	cached := tm.lru.Len()
 	tm.mu.Unlock()
+
+	return Stats{
+		Cached:        cached,
+		Capacity:      tm.capacity,
+		Hits:          tm.hits.Load(),
+		Misses:        tm.misses.Load(),
+		Refreshes:     tm.refreshes.Load(),
+		RefreshErrors: tm.refreshErrors.Load(),
+		Evictions:     tm.evictions.Load(),
+		Expirations:   tm.expirations.Load(),
+	}
+}
+
+// Close stops the background sweeper. The cache stays usable.
+func (tm *TokenManager) Close() {
+	tm.stopOnce.Do(func() { close(tm.stop) })
 }
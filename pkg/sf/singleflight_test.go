@@ -1,6 +1,8 @@
 package sf
 
 import (
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -33,3 +35,88 @@ func TestInvalidate_ForcesRefresh(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 2, calls)
 }
+
+func TestGetToken_CollapsesConcurrentRefreshes(t *testing.T) {
+	tm := New(10, 0)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	refresh := func() (string, time.Time, error) {
+		calls.Add(1)
+		<-release
+		return "token", time.Now().Add(time.Hour), nil
+	}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tok, err := tm.GetToken("2212345", refresh)
+			require.NoError(t, err)
+			require.Equal(t, "token", tok)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, int32(1), calls.Load())
+	stats := tm.Stats()
+	require.Equal(t, uint64(8), stats.Hits+stats.Misses)
+	require.Equal(t, uint64(1), stats.Refreshes)
+}
+
+func TestCapacityAndExpiry(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	tm := New(2, 0)
+	tm.now = func() time.Time { return now }
+	refreshFor := func(ttl time.Duration) func() (string, time.Time, error) {
+		return func() (string, time.Time, error) { return "token", now.Add(ttl), nil }
+	}
+
+	_, err := tm.GetToken("a", refreshFor(time.Hour))
+	require.NoError(t, err)
+	_, err = tm.GetToken("b", refreshFor(time.Minute))
+	require.NoError(t, err)
+	now = now.Add(time.Second)
+	_, err = tm.GetToken("a", refreshFor(time.Hour))
+	require.NoError(t, err)
+	_, err = tm.GetToken("c", refreshFor(time.Hour))
+	require.NoError(t, err)
+
+	entries := tm.Entries()
+	require.Len(t, entries, 2)
+	require.Equal(t, "c", entries[0].Key, "most recently used first")
+	require.Equal(t, "a", entries[1].Key, "b was least recently used, so it was evicted")
+	require.Equal(t, now, entries[1].LastUsed)
+
+	_, err = tm.GetToken("d", refreshFor(time.Minute))
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	require.Len(t, tm.Entries(), 1, "d expired")
+	require.Equal(t, 2, tm.Stats().Cached, "until it is swept")
+	tm.sweep()
+
+	require.Equal(t, Stats{
+		Cached:      1,
+		Capacity:    2,
+		Hits:        1,
+		Misses:      4,
+		Refreshes:   4,
+		Evictions:   2,
+		Expirations: 1,
+	}, tm.Stats())
+}
+
+func TestSweeper(t *testing.T) {
+	tm := New(10, time.Millisecond)
+	defer tm.Close()
+
+	_, err := tm.GetToken("2212345", func() (string, time.Time, error) {
+		return "token", time.Now().Add(5 * time.Millisecond), nil
+	})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return tm.Stats().Cached == 0 }, time.Second, time.Millisecond)
+	require.Equal(t, uint64(1), tm.Stats().Expirations)
+}
@@ -0,0 +1,17 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// StableID returns a deterministic ID of the form "gomaluum:<kind>:<hash>" for
+// an entity identified by parts (e.g. session, course code, section and slot).
+// The same parts always yield the same ID, so clients can cache, diff and key
+// entities across calls. Parts are joined with a separator that cannot appear
+// in scraped text, so ("ab", "c") and ("a", "bc") hash differently.
+func StableID(kind string, parts ...string) string {
+	sum := sha256.Sum256([]byte(kind + "\x00" + strings.Join(parts, "\x1f")))
+	return "gomaluum:" + kind + ":" + hex.EncodeToString(sum[:12])
+}
@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStableID(t *testing.T) {
+	id := StableID("subject", "?ses=2025/2026&sem=1", "INFO4335", "1")
+	require.Equal(t, id, StableID("subject", "?ses=2025/2026&sem=1", "INFO4335", "1"))
+	require.Regexp(t, `^gomaluum:subject:[0-9a-f]{24}$`, id)
+
+	require.NotEqual(t, id, StableID("result", "?ses=2025/2026&sem=1", "INFO4335", "1"))
+	require.NotEqual(t, StableID("x", "ab", "c"), StableID("x", "a", "bc"))
+}
@@ -0,0 +1,105 @@
+// Package vault seals secrets with envelope encryption: every record is
+// encrypted with its own random data key, and only the data key is encrypted
+// (wrapped) with the master key. Rotating the master key therefore means
+// re-wrapping data keys, never re-encrypting records.
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+
+	"github.com/nrmnqdds/gomaluum/internal/errors"
+)
+
+// KeySize is the size of the master key and of every data key (AES-256).
+const KeySize = 32
+
+// Vault seals and opens records under one master key.
+type Vault struct {
+	master cipher.AEAD
+}
+
+// Sealed is an encrypted record. Both fields are nonce-prefixed AES-GCM
+// ciphertexts and safe to store as-is.
+type Sealed struct {
+	WrappedKey []byte // data key, encrypted with the master key
+	Ciphertext []byte // plaintext, encrypted with the data key
+}
+
+// New returns a vault for the given KeySize-byte master key.
+func New(masterKey []byte) (*Vault, error) {
+	if len(masterKey) != KeySize {
+		return nil, errors.ErrInvalidVaultMasterKey
+	}
+	master, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, errors.ErrInvalidVaultMasterKey
+	}
+	return &Vault{master: master}, nil
+}
+
+// Seal encrypts plaintext under a fresh data key. aad is authenticated but not
+// encrypted; Open must be given the same aad, which binds the record to its
+// context (e.g. its ID and owner) so it cannot be swapped for another.
+func (v *Vault) Seal(plaintext, aad []byte) (Sealed, error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return Sealed{}, errors.ErrFailedToSealCredential
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return Sealed{}, errors.ErrFailedToSealCredential
+	}
+
+	ciphertext, err := seal(data, plaintext, aad)
+	if err != nil {
+		return Sealed{}, errors.ErrFailedToSealCredential
+	}
+	wrappedKey, err := seal(v.master, dataKey, aad)
+	if err != nil {
+		return Sealed{}, errors.ErrFailedToSealCredential
+	}
+	return Sealed{WrappedKey: wrappedKey, Ciphertext: ciphertext}, nil
+}
+
+// Open decrypts a record sealed with the same master key and aad.
+func (v *Vault) Open(sealed Sealed, aad []byte) ([]byte, error) {
+	dataKey, err := open(v.master, sealed.WrappedKey, aad)
+	if err != nil {
+		return nil, errors.ErrFailedToOpenCredential
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, errors.ErrFailedToOpenCredential
+	}
+	plaintext, err := open(data, sealed.Ciphertext, aad)
+	if err != nil {
+		return nil, errors.ErrFailedToOpenCredential
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, ciphertext, aad []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.ErrFailedToOpenCredential
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
@@ -0,0 +1,44 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newKey(t *testing.T) []byte {
+	key := make([]byte, KeySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return key
+}
+
+func TestSealOpen(t *testing.T) {
+	v, err := New(newKey(t))
+	require.NoError(t, err)
+
+	sealed, err := v.Seal([]byte("p@ss"), []byte("handle|2110000"))
+	require.NoError(t, err)
+	require.False(t, bytes.Contains(sealed.Ciphertext, []byte("p@ss")))
+
+	plaintext, err := v.Open(sealed, []byte("handle|2110000"))
+	require.NoError(t, err)
+	require.Equal(t, "p@ss", string(plaintext))
+
+	again, err := v.Seal([]byte("p@ss"), []byte("handle|2110000"))
+	require.NoError(t, err)
+	require.NotEqual(t, sealed.WrappedKey, again.WrappedKey, "each record has its own data key")
+
+	_, err = v.Open(sealed, []byte("handle|2110001"))
+	require.Error(t, err, "aad binds the record to its owner")
+
+	other, err := New(newKey(t))
+	require.NoError(t, err)
+	_, err = other.Open(sealed, []byte("handle|2110000"))
+	require.Error(t, err, "another master key cannot unwrap the data key")
+
+	_, err = New([]byte("short"))
+	require.Error(t, err)
+}